@@ -5,23 +5,41 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"mime"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/redis/go-redis/v9"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/artifact"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/auth"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/autoscale"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/config"
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/joblog"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/metrics"
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/scheduler"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/tracing"
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -30,52 +48,128 @@ var (
 )
 
 var (
-	sched  *scheduler.Scheduler
-	jobsMu sync.RWMutex
-	jobs   = make(map[string]*job.Job)
+	sched   *scheduler.Scheduler
+	history = scheduler.NewRuntimeHistory()
+	jobsMu  sync.RWMutex
+	jobs    = make(map[string]*job.Job)
+	// requests holds how each unfinished job was submitted, so shutdown can
+	// queue it again for the next process
+	requests = make(map[string]SubmitJobRequest)
+	parked   = make(map[string]bool) // handed to the next process, no longer watched
+	watchers sync.WaitGroup
+	draining atomic.Bool // shutting down, new jobs are refused
+
+	offloadBytes     = 1 << 20
+	maxUploadBytes   = 256 << 20
+	progressInterval = time.Second
 )
 
 var (
 	db *pgxpool.Pool
 )
 
-// Helper function to get integer from environment variable with default
-func getEnvInt(key string, defaultVal int) int {
-	if value := os.Getenv(key); value != "" {
-		if intVal, err := strconv.Atoi(value); err == nil {
-			return intVal
+var (
+	logger  = slog.Default()
+	jobLogs = joblog.NewStore(1000, slog.LevelInfo)
+)
+
+// newLogger builds the process logger, writing JSON unless the format is text
+func newLogger(cfg config.Logging) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: joblog.ParseLevel(cfg.Level)}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// requestLogger logs each request once it has been served
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		level := slog.LevelInfo
+		if path := c.Request.URL.Path; path == "/healthz" || path == "/readyz" || path == "/metrics" {
+			level = slog.LevelDebug // probes and scrapes would drown everything else
+		}
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []interface{}{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration", time.Since(start),
+			"client_ip", c.ClientIP(),
 		}
+		if p, ok := auth.FromContext(c); ok {
+			attrs = append(attrs, "principal", p.Subject)
+		}
+		logger.Log(c.Request.Context(), level, "request", attrs...)
 	}
-	return defaultVal
 }
 
 type SubmitJobRequest struct {
-	Type         string      `json:"type" binding:"required"`
-	Priority     int         `json:"priority" binding:"required"`
-	ThreadDemand int         `json:"thread_demand" binding:"required"`
-	Payload      interface{} `json:"payload" binding:"required"`
+	Type         string           `json:"type" binding:"required"`
+	Priority     int              `json:"priority" binding:"required"`
+	ThreadDemand int              `json:"thread_demand" binding:"required"`
+	Payload      interface{}      `json:"payload"`
+	PayloadRef   string           `json:"payload_ref"` // key of an uploaded artifact holding the payload
+	MemoryMB     int              `json:"memory_mb"`
+	Resources    map[string]int   `json:"resources"`
+	Constraints  *job.Constraints `json:"constraints"`
+	Tenant       string           `json:"tenant"`
+	Preemptible  bool             `json:"preemptible"`
+	Gang         bool             `json:"gang"`
 }
 
 type JobResponse struct {
-	ID           string      `json:"id"`
-	Type         string      `json:"type"`
-	Priority     int         `json:"priority"`
-	ThreadDemand int         `json:"thread_demand"`
-	Status       string      `json:"status"`
-	CreatedAt    time.Time   `json:"created_at"`
-	StartedAt    *time.Time  `json:"started_at,omitempty"`
-	CompletedAt  *time.Time  `json:"completed_at,omitempty"`
-	Result       interface{} `json:"result,omitempty"`
+	ID            string             `json:"id"`
+	Type          string             `json:"type"`
+	Priority      int                `json:"priority"`
+	ThreadDemand  int                `json:"thread_demand"`
+	Tenant        string             `json:"tenant,omitempty"`
+	Preemptible   bool               `json:"preemptible,omitempty"`
+	Gang          bool               `json:"gang,omitempty"`
+	Attempts      int                `json:"attempts"`
+	MemoryMB      int                `json:"memory_mb,omitempty"`
+	Resources     map[string]int     `json:"resources,omitempty"`
+	ThreadOutcome *job.ThreadOutcome `json:"thread_outcome,omitempty"`
+	Constraints   *job.Constraints   `json:"constraints,omitempty"`
+	Status        string             `json:"status"`
+	Progress      *job.Progress      `json:"progress,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+	StartedAt     *time.Time         `json:"started_at,omitempty"`
+	CompletedAt   *time.Time         `json:"completed_at,omitempty"`
+	Result        interface{}        `json:"result,omitempty"`
+	ResultRef     string             `json:"result_ref,omitempty"`
+	PayloadRef    string             `json:"payload_ref,omitempty"`
+	TraceID       string             `json:"trace_id,omitempty"`
+	Error         *job.JobError      `json:"error,omitempty"`
 }
 
 func jobToResponse(j *job.Job) JobResponse {
 	return JobResponse{
-		ID:           j.ID,
-		Type:         string(j.Type),
-		Priority:     j.Priority,
-		ThreadDemand: j.ThreadDemand,
-		Status:       string(j.Status),
-		CreatedAt:    j.CreatedAt,
+		ID:            j.ID,
+		Type:          string(j.Type),
+		Priority:      j.Priority,
+		ThreadDemand:  j.ThreadDemand,
+		Tenant:        j.Tenant,
+		Preemptible:   j.Preemptible,
+		Gang:          j.Gang,
+		Attempts:      j.Attempts,
+		MemoryMB:      j.MemoryMB,
+		Resources:     j.CustomResources,
+		ThreadOutcome: j.ThreadOutcome,
+		Constraints:   j.Constraints,
+		Status:        string(j.Status),
+		Progress:      j.LatestProgress(),
+		CreatedAt:     j.CreatedAt,
 		StartedAt: func() *time.Time {
 			if !j.StartedAt.IsZero() {
 				return &j.StartedAt
@@ -88,15 +182,791 @@ func jobToResponse(j *job.Job) JobResponse {
 			}
 			return nil
 		}(),
-		Result: j.Result,
+		Result:     j.Result,
+		ResultRef:  j.ResultRef,
+		PayloadRef: j.PayloadRef,
+		TraceID:    tracing.TraceID(j.TraceParent),
+		Error:      j.Error,
+	}
+}
+
+type WorkerResponse struct {
+	ID          string            `json:"id"`
+	Threads     int               `json:"threads"`
+	FreeThreads int               `json:"free_threads"`
+	Queued      int               `json:"queued"`
+	Steals      int64             `json:"steals"`
+	Stolen      int64             `json:"stolen"`
+	Crashes     int64             `json:"crashes"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	State       string            `json:"state"` // active, draining or paused
+}
+
+func workerToResponse(w *worker.Worker) WorkerResponse {
+	return WorkerResponse{
+		ID:          w.ID,
+		Threads:     w.Capacity().Threads,
+		FreeThreads: w.AvailableThreads(),
+		Queued:      w.Queued(),
+		Steals:      w.Steals(),
+		Stolen:      w.Stolen(),
+		Crashes:     w.Crashes(),
+		Labels:      w.Labels,
+		State:       w.State().String(),
 	}
 }
 
+// AddWorkerRequest describes a worker to add at runtime
+type AddWorkerRequest struct {
+	ID        string            `json:"id" binding:"required"`
+	Threads   int               `json:"threads" binding:"required,min=1"`
+	QueueSize int               `json:"queue_size" binding:"min=0"` // 0 means the default
+	MemoryMB  int               `json:"memory_mb" binding:"min=0"`
+	Resources map[string]int    `json:"resources"`
+	Labels    map[string]string `json:"labels"`
+}
+
+type ResizeWorkerRequest struct {
+	Threads int `json:"threads" binding:"required,min=1"`
+}
+
+// drainTimeout is how long drain and remove requests wait for running jobs
+// when they don't pass ?timeout
+var drainTimeout = 30 * time.Second
+
 // Registry for job types
 type JobFactory func(id string, req SubmitJobRequest) (*job.Job, error)
 
 var jobRegistry = map[string]JobFactory{}
 
+// Helper to normalize job type strings (e.g., AddNumbers -> add_numbers)
+func normalizeJobType(s string) string {
+	if s == "" {
+		return s
+	}
+	if strings.Contains(s, "_") {
+		return strings.ToLower(s)
+	}
+	var out []rune
+	for i, r := range s {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			out = append(out, '_')
+		}
+		out = append(out, r)
+	}
+	return strings.ToLower(string(out))
+}
+
+// lookupFactory finds the factory for a job type given as add_numbers,
+// AddNumbers or addnumbers
+func lookupFactory(t string) (JobFactory, bool) {
+	if f, ok := jobRegistry[t]; ok {
+		return f, true
+	}
+	nt := normalizeJobType(t)
+	if f, ok := jobRegistry[nt]; ok {
+		return f, true
+	}
+	// also try lowercasing directly
+	if f, ok := jobRegistry[strings.ToLower(t)]; ok {
+		return f, true
+	}
+	return nil, false
+}
+
+// newJobFromRequest builds a job with factory, either from the inline
+// payload or, with payload_ref, from an uploaded artifact. Referenced
+// payloads stay in the store until the job runs.
+func newJobFromRequest(factory JobFactory, id string, req SubmitJobRequest) (*job.Job, error) {
+	if req.PayloadRef == "" {
+		if req.Payload == nil {
+			return nil, errors.New("payload or payload_ref is required")
+		}
+		return factory(id, req)
+	}
+	if req.Payload != nil {
+		return nil, errors.New("payload and payload_ref are mutually exclusive")
+	}
+	if _, err := job.Artifacts.Stat(req.PayloadRef); err != nil {
+		return nil, fmt.Errorf("payload_ref: %w", err)
+	}
+	// Factories only decode inline payloads, so give them an empty one
+	req.Payload = map[string]interface{}{}
+	j, err := factory(id, req)
+	if err != nil {
+		return nil, err
+	}
+	j.Payload = nil
+	j.PayloadRef = req.PayloadRef
+	return j, nil
+}
+
+// newArtifactStore builds the configured artifact backend
+func newArtifactStore(cfg config.Artifacts) (artifact.Store, error) {
+	switch cfg.Store {
+	case "", "local":
+		if cfg.Dir == "" {
+			return job.Artifacts, nil
+		}
+		return artifact.NewLocalStore(cfg.Dir), nil
+	case "s3":
+		return artifact.NewS3Store(artifact.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Bucket:          cfg.S3.Bucket,
+			Region:          cfg.S3.Region,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown artifact store %q", cfg.Store)
+	}
+}
+
+// newWorker builds an unstarted worker from its config
+func newWorker(wc config.Worker) *worker.Worker {
+	w := worker.NewWorkerWithResources(wc.ID, job.Resources{
+		Threads:  wc.Threads,
+		MemoryMB: wc.MemoryMB,
+		Custom:   wc.Resources,
+	}, wc.QueueSize)
+	w.Labels = wc.Labels
+	return w
+}
+
+// startAutoscaler grows and shrinks the pool in the background until the
+// returned func is called, which waits for it to stop
+func startAutoscaler(cfg config.Autoscale) func() {
+	template := cfg.Worker
+	scaler := autoscale.New(autoscale.Config{
+		MinWorkers:      cfg.MinWorkers,
+		MaxWorkers:      cfg.MaxWorkers,
+		Interval:        cfg.Interval.D(),
+		UpCooldown:      cfg.ScaleUpCooldown.D(),
+		DownCooldown:    cfg.ScaleDownCooldown.D(),
+		QueuePerThread:  cfg.QueuePerThread,
+		TargetWait:      cfg.TargetWait.D(),
+		MaxCPU:          cfg.MaxCPU,
+		IdleUtilization: cfg.IdleUtilization,
+		DrainTimeout:    cfg.DrainTimeout.D(),
+		WorkerThreads:   template.Threads,
+	}, sched, template.ID, func(id string) *worker.Worker {
+		wc := template
+		wc.ID = id
+		return newWorker(wc)
+	}, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scaler.Run(ctx)
+	}()
+	logger.Info("autoscaling workers", "min", cfg.MinWorkers, "max", cfg.MaxWorkers, "interval", cfg.Interval.D())
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// corsMethods and corsHeaders are what browsers may send cross-origin
+const (
+	corsMethods = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsHeaders = "Authorization, Content-Type, X-API-Key, traceparent, tracestate"
+)
+
+// corsMiddleware lets browsers on origins call the API and answers their
+// preflights; "*" allows any origin. Credentials aren't allowed since keys
+// and tokens travel in headers, not cookies.
+func corsMiddleware(origins []string) gin.HandlerFunc {
+	anyOrigin := slices.Contains(origins, "*")
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		origin := c.GetHeader("Origin")
+		ok := origin != "" && (anyOrigin || allowed[origin])
+		if !anyOrigin {
+			h.Add("Vary", "Origin")
+		}
+		if ok {
+			if anyOrigin {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			h.Set("Access-Control-Expose-Headers", "traceparent")
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			if !ok {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			h.Set("Access-Control-Allow-Methods", corsMethods)
+			h.Set("Access-Control-Allow-Headers", corsHeaders)
+			h.Set("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// requiredScope is the scope a request needs: none for the probes, admin to
+// manage keys or change the worker pool, read to look and write otherwise
+func requiredScope(c *gin.Context) string {
+	route, method := c.FullPath(), c.Request.Method
+	switch {
+	case route == "/healthz" || route == "/readyz":
+		return ""
+	case strings.HasPrefix(route, "/keys"):
+		return auth.ScopeAdmin
+	case strings.HasPrefix(route, "/workers") && method != http.MethodGet:
+		return auth.ScopeAdmin
+	case method == http.MethodGet || method == http.MethodHead:
+		return auth.ScopeRead
+	}
+	return auth.ScopeWrite
+}
+
+type CreateKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresIn is a duration like 720h, empty means the key never expires
+	ExpiresIn string `json:"expires_in"`
+}
+
+// CreatedKeyResponse is the only time the full key is returned
+type CreatedKeyResponse struct {
+	auth.Key
+	APIKey string `json:"api_key"`
+}
+
+// registerKeyRoutes adds the API key management endpoints
+func registerKeyRoutes(r *gin.Engine, a *auth.Authenticator) {
+	r.POST("/keys", func(c *gin.Context) {
+		var req CreateKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for _, s := range req.Scopes {
+			if !auth.ValidScope(s) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown scope %q (want read, write or admin)", s)})
+				return
+			}
+		}
+		var ttl time.Duration
+		if req.ExpiresIn != "" {
+			d, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || d <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expires_in %q is not a positive duration like 720h", req.ExpiresIn)})
+				return
+			}
+			ttl = d
+		}
+		key, token, err := a.CreateKey(c.Request.Context(), req.Name, req.Scopes, ttl)
+		if err != nil {
+			logger.Error("creating API key failed", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		by, _ := auth.FromContext(c)
+		logger.Info("API key created", "key_id", key.ID, "name", key.Name, "scopes", key.Scopes, "by", by.Subject)
+		c.JSON(http.StatusCreated, CreatedKeyResponse{Key: key, APIKey: token})
+	})
+
+	r.GET("/keys", func(c *gin.Context) {
+		keys, err := a.Keys(c.Request.Context())
+		if err != nil {
+			logger.Error("listing API keys failed", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if keys == nil {
+			keys = []auth.Key{}
+		}
+		c.JSON(http.StatusOK, keys)
+	})
+
+	r.DELETE("/keys/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		if err := a.RevokeKey(c.Request.Context(), id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, auth.ErrKeyNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		by, _ := auth.FromContext(c)
+		logger.Info("API key revoked", "key_id", id, "by", by.Subject)
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// registerArtifactRoutes adds the upload and download endpoints for the
+// artifact store
+func registerArtifactRoutes(r *gin.Engine) {
+	// Upload a blob, e.g. a large payload to submit by reference
+	r.POST("/artifacts", func(c *gin.Context) {
+		key := "uploads/" + uuid.New().String()
+		if name := c.Query("name"); name != "" {
+			key += "/" + name
+		}
+		if err := artifact.ValidKey(key); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		tooLarge := gin.H{"error": fmt.Sprintf("artifact larger than %d bytes", maxUploadBytes)}
+		if c.Request.ContentLength > int64(maxUploadBytes) {
+			c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxUploadBytes))
+		a, err := job.Artifacts.Put(key, body)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		if err != nil {
+			logger.Error("storing artifact failed", "key", key, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, a)
+	})
+
+	r.GET("/artifacts/*key", func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		if err := artifact.ValidKey(key); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		a, err := job.Artifacts.Stat(key)
+		if err != nil {
+			if errors.Is(err, artifact.ErrNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "artifact not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		body, err := job.Artifacts.Get(key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer body.Close()
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.DataFromReader(http.StatusOK, a.Size, contentType, body, nil)
+	})
+}
+
+// registerWorkerRoutes lists the worker pool and lets it be changed at
+// runtime. Changes apply to placement immediately but aren't saved to the
+// config, so a restart goes back to the configured workers.
+func registerWorkerRoutes(r *gin.Engine) {
+	// Current load and work stealing counters for each worker
+	r.GET("/workers", func(c *gin.Context) {
+		workers := sched.Workers()
+		resp := make([]WorkerResponse, 0, len(workers))
+		for _, w := range workers {
+			resp = append(resp, workerToResponse(w))
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	r.POST("/workers", func(c *gin.Context) {
+		var req AddWorkerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.QueueSize == 0 {
+			req.QueueSize = config.DefaultQueueSize
+		}
+		w := newWorker(config.Worker{
+			ID:        req.ID,
+			Threads:   req.Threads,
+			QueueSize: req.QueueSize,
+			MemoryMB:  req.MemoryMB,
+			Resources: req.Resources,
+			Labels:    req.Labels,
+		})
+		w.Start()
+		if err := sched.AddWorker(w); err != nil {
+			w.Stop()
+			c.JSON(workerStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("worker added", "worker", w.ID, "threads", req.Threads)
+		c.JSON(http.StatusCreated, workerToResponse(w))
+	})
+
+	// Change a worker's thread count; running jobs keep what they hold
+	r.PATCH("/workers/:id", func(c *gin.Context) {
+		var req ResizeWorkerRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		id := c.Param("id")
+		if err := sched.ResizeWorker(id, req.Threads); err != nil {
+			c.JSON(workerStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("worker resized", "worker", id, "threads", req.Threads)
+		c.JSON(http.StatusOK, workerToResponse(sched.Worker(id)))
+	})
+
+	// Pause stops a worker starting jobs and hands its queued ones back to
+	// the scheduler; resume undoes pause and drain
+	r.POST("/workers/:id/pause", func(c *gin.Context) {
+		workerAction(c, "worker paused", sched.PauseWorker)
+	})
+	r.POST("/workers/:id/resume", func(c *gin.Context) {
+		workerAction(c, "worker resumed", sched.ResumeWorker)
+	})
+
+	// Drain places nothing more on a worker and waits up to ?timeout for
+	// its jobs to finish; remove drains first
+	r.POST("/workers/:id/drain", func(c *gin.Context) {
+		workerAction(c, "worker drained", func(id string) error {
+			return drainWorker(c, id)
+		})
+	})
+	r.DELETE("/workers/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		err := drainWorker(c, id)
+		if err == nil {
+			err = sched.RemoveWorker(id)
+		}
+		if err != nil {
+			c.JSON(workerStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		logger.Info("worker removed", "worker", id)
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// workerAction runs fn on the worker in the path and responds with its state
+func workerAction(c *gin.Context, msg string, fn func(id string) error) {
+	id := c.Param("id")
+	if err := fn(id); err != nil {
+		c.JSON(workerStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	logger.Info(msg, "worker", id)
+	c.JSON(http.StatusOK, workerToResponse(sched.Worker(id)))
+}
+
+// drainWorker drains the worker in the path for at most ?timeout
+func drainWorker(c *gin.Context, id string) error {
+	timeout := drainTimeout
+	if v := c.Query("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", v, err)
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	return sched.DrainWorker(ctx, id)
+}
+
+// workerStatus is the HTTP status to report a worker pool error with
+func workerStatus(err error) int {
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownWorker):
+		return http.StatusNotFound
+	case errors.Is(err, scheduler.ErrWorkerExists),
+		errors.Is(err, scheduler.ErrWorkerBusy),
+		errors.Is(err, scheduler.ErrStranded),
+		errors.Is(err, context.DeadlineExceeded):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// registerJobLogRoutes serves a job's captured log: from memory while this
+// process holds it, from job_logs once the job has finished
+func registerJobLogRoutes(r *gin.Engine) {
+	// ?tail=N returns the last N lines; ?follow=true streams NDJSON until
+	// the job finishes or the client goes away
+	r.GET("/jobs/:id/logs", func(c *gin.Context) {
+		id := c.Param("id")
+		tail, _ := strconv.Atoi(c.Query("tail"))
+		follow := c.Query("follow") == "true"
+		buf, ok := jobLogs.Get(id)
+		if !ok {
+			lines, err := loadJobLogs(c.Request.Context(), id, tail)
+			if err != nil {
+				logger.Error("loading job logs failed", "job_id", id, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if len(lines) == 0 {
+				jobsMu.RLock()
+				_, known := jobs[id]
+				jobsMu.RUnlock()
+				if !known {
+					c.JSON(http.StatusNotFound, gin.H{"error": "no logs for job"})
+					return
+				}
+			}
+			if follow {
+				c.Header("Content-Type", "application/x-ndjson")
+				c.Status(http.StatusOK)
+				writeLogLines(c, lines)
+				return
+			}
+			c.JSON(http.StatusOK, lines)
+			return
+		}
+
+		lines, next := buf.Tail(tail)
+		if !follow {
+			c.JSON(http.StatusOK, lines)
+			return
+		}
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		for {
+			if err := writeLogLines(c, lines); err != nil {
+				return
+			}
+			var wait <-chan struct{}
+			var done bool
+			lines, next, wait, done = buf.Since(next)
+			if done {
+				return
+			}
+			if len(lines) == 0 {
+				select {
+				case <-wait:
+				case <-c.Request.Context().Done():
+					return
+				}
+			}
+		}
+	})
+}
+
+// writeLogLines writes one JSON line per entry and flushes
+func writeLogLines(c *gin.Context, lines []joblog.Entry) error {
+	enc := json.NewEncoder(c.Writer)
+	for _, e := range lines {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	c.Writer.Flush()
+	return nil
+}
+
+// persistJobLogs writes job id's captured log to job_logs and drops the
+// in-memory copy. The jobs row must exist first.
+func persistJobLogs(id string) error {
+	buf, ok := jobLogs.Get(id)
+	if !ok {
+		return nil
+	}
+	buf.Close()
+	batch := &pgx.Batch{}
+	for _, e := range buf.Unflushed() {
+		var attrs []byte
+		if len(e.Attrs) > 0 {
+			var err error
+			if attrs, err = json.Marshal(e.Attrs); err != nil {
+				return err
+			}
+		}
+		batch.Queue("INSERT INTO job_logs (job_id, timestamp, message, level, attrs) VALUES ($1, $2, $3, $4, $5)",
+			id, e.Time.UTC(), e.Message, e.Level, attrs)
+	}
+	if batch.Len() > 0 {
+		if err := db.SendBatch(context.Background(), batch).Close(); err != nil {
+			return err
+		}
+	}
+	jobLogs.Remove(id)
+	return nil
+}
+
+// loadJobLogs reads job id's persisted log, only the last tail lines if
+// tail > 0
+func loadJobLogs(ctx context.Context, id string, tail int) ([]joblog.Entry, error) {
+	var limit interface{}
+	if tail > 0 {
+		limit = tail
+	}
+	rows, err := db.Query(ctx, `
+	       SELECT seq, timestamp, level, message, attrs FROM (
+		       SELECT id, ROW_NUMBER() OVER (ORDER BY id) - 1 AS seq, timestamp, level, message, attrs
+		       FROM job_logs WHERE job_id = $1
+		       ORDER BY id DESC LIMIT $2
+	       ) t ORDER BY id
+	       `, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	lines := []joblog.Entry{}
+	for rows.Next() {
+		var e joblog.Entry
+		var level sql.NullString
+		var attrs []byte
+		if err := rows.Scan(&e.Seq, &e.Time, &level, &e.Message, &attrs); err != nil {
+			return nil, err
+		}
+		e.Level = level.String
+		if len(attrs) > 0 {
+			if err := json.Unmarshal(attrs, &e.Attrs); err != nil {
+				return nil, err
+			}
+		}
+		lines = append(lines, e)
+	}
+	return lines, rows.Err()
+}
+
+// healthCheck returns why a dependency or component is unhealthy, nil if
+// it's fine
+type healthCheck func(ctx context.Context) error
+
+// CheckResponse is the outcome of one health check
+type CheckResponse struct {
+	Status  string `json:"status"` // "ok" or "fail"
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency"`
+}
+
+type HealthResponse struct {
+	Status string                   `json:"status"` // "ok" or "unavailable"
+	Checks map[string]CheckResponse `json:"checks"`
+}
+
+// runHealthChecks runs checks concurrently, each with timeout
+func runHealthChecks(ctx context.Context, checks map[string]healthCheck, timeout time.Duration) (HealthResponse, bool) {
+	resp := HealthResponse{Status: "ok", Checks: make(map[string]CheckResponse, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check healthCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx)
+			c := CheckResponse{Status: "ok", Latency: time.Since(start).Round(time.Microsecond).String()}
+			if err != nil {
+				c.Status = "fail"
+				c.Error = err.Error()
+			}
+			mu.Lock()
+			resp.Checks[name] = c
+			if err != nil {
+				resp.Status = "unavailable"
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return resp, resp.Status == "ok"
+}
+
+// registerHealthRoutes adds /healthz, which fails only when the process
+// should be restarted, and /readyz, which fails while it can't serve jobs
+func registerHealthRoutes(r *gin.Engine, live, ready map[string]healthCheck, timeout time.Duration) {
+	serve := func(checks map[string]healthCheck) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			resp, ok := runHealthChecks(c.Request.Context(), checks, timeout)
+			status := http.StatusOK
+			if !ok {
+				status = http.StatusServiceUnavailable
+			}
+			c.JSON(status, resp)
+		}
+	}
+	r.GET("/healthz", serve(live))
+	r.GET("/readyz", serve(ready))
+}
+
+// schedulerCheck fails if the scheduler has stopped or its lock is stuck,
+// or a worker loop has been blocked handing over a job for longer than
+// maxDispatch (0 to ignore)
+func schedulerCheck(maxDispatch time.Duration) healthCheck {
+	return func(ctx context.Context) error {
+		timeout := time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		return sched.Health(timeout).Check(maxDispatch)
+	}
+}
+
+// workersCheck fails if any worker hasn't shown it's running within maxAge
+func workersCheck(maxAge time.Duration) healthCheck {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, w := range sched.Workers() {
+			if err := heartbeatCheck(w, maxAge)(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("worker %s: %w", w.ID, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// heartbeatCheck fails if w hasn't shown it's running within maxAge
+func heartbeatCheck(w *worker.Worker, maxAge time.Duration) healthCheck {
+	return func(ctx context.Context) error {
+		last := w.LastHeartbeat()
+		if last.IsZero() {
+			return errors.New("worker not running")
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("last heartbeat %s ago", age.Round(time.Millisecond))
+		}
+		return nil
+	}
+}
+
+// retry calls connect until it succeeds, backing off exponentially up to
+// 30s between attempts. It gives up after timeout, 0 means never.
+func retry(name string, timeout time.Duration, connect func(ctx context.Context) error) error {
+	backoff := 500 * time.Millisecond
+	deadline := time.Now().Add(timeout)
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := connect(ctx)
+		cancel()
+		if err == nil {
+			if attempt > 1 {
+				logger.Info("dependency available", "dependency", name, "attempts", attempt)
+			}
+			return nil
+		}
+		if timeout > 0 && time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("%s unavailable after %d attempts: %w", name, attempt, err)
+		}
+		logger.Warn("dependency unavailable, retrying", "dependency", name, "attempt", attempt, "retry_in", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
 // Helper: convert map[string]interface{} to struct
 func mapToStruct(m map[string]interface{}, out interface{}) error {
 	b, err := json.Marshal(m)
@@ -107,30 +977,87 @@ func mapToStruct(m map[string]interface{}, out interface{}) error {
 }
 
 func main() {
-	// Create Gin router
-	r := gin.Default()
-
-	// Add CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "*")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		logger.Warn(".env file not found")
+	}
+	// Settings come from the defaults, then the config file, then the
+	// environment
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+	logger = newLogger(cfg.Logging)
+	slog.SetDefault(logger)
+	jobLogs = joblog.NewStore(cfg.Logging.JobMaxLines, joblog.ParseLevel(cfg.Logging.JobLevel))
 
-		c.Next()
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("unable to set up tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Initialize PostgreSQL connection
+	dbConfig, err := pgxpool.ParseConfig(cfg.Postgres.URL())
+	if err != nil {
+		fatal("invalid PostgreSQL config", err)
+	}
+	dbConfig.ConnConfig.Tracer = metrics.PgxTracer{}
+	db, err = pgxpool.NewWithConfig(context.Background(), dbConfig)
+	if err != nil {
+		fatal("unable to connect to PostgreSQL", err)
+	}
+	// Wait for Postgres and Redis rather than crash while they start
+	startupTimeout := cfg.API.StartupRetryTimeout.D()
+	if err := retry("postgres", startupTimeout, db.Ping); err != nil {
+		fatal("unable to reach PostgreSQL", err)
+	}
+
+	// Initialize Redis client
+	redisClient = redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr(),
+		Password: cfg.Redis.Password,
+		DB:       0,
 	})
+	redisClient.AddHook(metrics.RedisHook{})
+	pingRedis := func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }
+	if err := retry("redis", startupTimeout, pingRedis); err != nil {
+		fatal("unable to reach Redis", err)
+	}
+
+	// Create Gin router
+	r := gin.New()
+	r.Use(gin.Recovery(), requestLogger(), tracing.Gin(), metrics.Gin())
+	// Browsers may only call from the configured origins
+	r.Use(corsMiddleware(cfg.API.CORSOrigins))
+	// Once enabled, everything but the probes needs an API key or token
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authenticator = auth.New(auth.Config{
+			JWTSecret:    cfg.Auth.JWTSecret,
+			JWTIssuer:    cfg.Auth.JWTIssuer,
+			JWTAudience:  cfg.Auth.JWTAudience,
+			BootstrapKey: cfg.Auth.BootstrapKey,
+		}, auth.NewPostgresKeyStore(db))
+		r.Use(auth.Gin(authenticator, requiredScope))
+	} else {
+		logger.Warn("authentication is disabled, every endpoint is open")
+	}
 
-	// API endpoint: GET /db/jobs - fetch all jobs from PostgreSQL
+	// API endpoint: GET /db/jobs - fetch all jobs from PostgreSQL, optionally
+	// only those submitted in one trace (?trace_id=)
 	r.GET("/db/jobs", func(c *gin.Context) {
-		rows, err := db.Query(context.Background(), "SELECT id, type, priority, thread_demand, status, created_at, started_at, completed_at, result FROM jobs ORDER BY created_at DESC")
+		query := "SELECT id, type, priority, thread_demand, status, created_at, started_at, completed_at, result, error, thread_outcome, tenant, preemptible, attempts, payload_ref, result_ref, trace_id FROM jobs"
+		var args []interface{}
+		if traceID := c.Query("trace_id"); traceID != "" {
+			query += " WHERE trace_id = $1"
+			args = append(args, traceID)
+		}
+		rows, err := db.Query(c.Request.Context(), query+" ORDER BY created_at DESC", args...)
 		if err != nil {
-			log.Printf("Error querying database: %v", err)
+			logger.Error("querying jobs failed", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -139,14 +1066,26 @@ func main() {
 		for rows.Next() {
 			var j JobResponse
 			var resultRaw []byte
+			var errorRaw []byte
+			var outcomeRaw []byte
+			var tenant sql.NullString
+			var preemptible sql.NullBool
+			var attempts sql.NullInt32
+			var payloadRef, resultRef, traceID sql.NullString
 			var startedAt sql.NullTime
 			var completedAt sql.NullTime
-			err := rows.Scan(&j.ID, &j.Type, &j.Priority, &j.ThreadDemand, &j.Status, &j.CreatedAt, &startedAt, &completedAt, &resultRaw)
+			err := rows.Scan(&j.ID, &j.Type, &j.Priority, &j.ThreadDemand, &j.Status, &j.CreatedAt, &startedAt, &completedAt, &resultRaw, &errorRaw, &outcomeRaw, &tenant, &preemptible, &attempts, &payloadRef, &resultRef, &traceID)
 			if err != nil {
-				log.Printf("Error scanning row: %v", err)
+				logger.Error("scanning job row failed", "error", err)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			j.Tenant = tenant.String
+			j.Preemptible = preemptible.Bool
+			j.Attempts = int(attempts.Int32)
+			j.PayloadRef = payloadRef.String
+			j.ResultRef = resultRef.String
+			j.TraceID = traceID.String
 			if startedAt.Valid {
 				t := startedAt.Time
 				j.StartedAt = &t
@@ -161,7 +1100,17 @@ func main() {
 			}
 			if len(resultRaw) > 0 {
 				if err := json.Unmarshal(resultRaw, &j.Result); err != nil {
-					log.Printf("Error unmarshaling result: %v", err)
+					logger.Warn("unmarshaling result failed", "job_id", j.ID, "error", err)
+				}
+			}
+			if len(errorRaw) > 0 {
+				if err := json.Unmarshal(errorRaw, &j.Error); err != nil {
+					logger.Warn("unmarshaling job error failed", "job_id", j.ID, "error", err)
+				}
+			}
+			if len(outcomeRaw) > 0 {
+				if err := json.Unmarshal(outcomeRaw, &j.ThreadOutcome); err != nil {
+					logger.Warn("unmarshaling thread outcome failed", "job_id", j.ID, "error", err)
 				}
 			}
 			jobs = append(jobs, j)
@@ -174,9 +1123,21 @@ func main() {
 		id := c.Param("id")
 		var j JobResponse
 		var resultRaw []byte
+		var errorRaw []byte
+		var outcomeRaw []byte
+		var tenant sql.NullString
+		var preemptible sql.NullBool
+		var attempts sql.NullInt32
+		var payloadRef, resultRef, traceID sql.NullString
 		var startedAt time.Time
-		err := db.QueryRow(context.Background(), "SELECT id, type, priority, thread_demand, status, created_at, started_at, completed_at, result FROM jobs WHERE id=$1", id).Scan(
-			&j.ID, &j.Type, &j.Priority, &j.ThreadDemand, &j.Status, &j.CreatedAt, &startedAt, &j.CompletedAt, &resultRaw)
+		err := db.QueryRow(context.Background(), "SELECT id, type, priority, thread_demand, status, created_at, started_at, completed_at, result, error, thread_outcome, tenant, preemptible, attempts, payload_ref, result_ref, trace_id FROM jobs WHERE id=$1", id).Scan(
+			&j.ID, &j.Type, &j.Priority, &j.ThreadDemand, &j.Status, &j.CreatedAt, &startedAt, &j.CompletedAt, &resultRaw, &errorRaw, &outcomeRaw, &tenant, &preemptible, &attempts, &payloadRef, &resultRef, &traceID)
+		j.Tenant = tenant.String
+		j.Preemptible = preemptible.Bool
+		j.Attempts = int(attempts.Int32)
+		j.PayloadRef = payloadRef.String
+		j.ResultRef = resultRef.String
+		j.TraceID = traceID.String
 		if !startedAt.IsZero() {
 			j.StartedAt = &startedAt
 		} else {
@@ -189,41 +1150,15 @@ func main() {
 		if len(resultRaw) > 0 {
 			json.Unmarshal(resultRaw, &j.Result)
 		}
+		if len(errorRaw) > 0 {
+			json.Unmarshal(errorRaw, &j.Error)
+		}
+		if len(outcomeRaw) > 0 {
+			json.Unmarshal(outcomeRaw, &j.ThreadOutcome)
+		}
 		c.JSON(http.StatusOK, j)
 	})
 
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
-	}
-
-	// Initialize PostgreSQL connection
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-		os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PASSWORD"),
-		os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"),
-		os.Getenv("POSTGRES_DB"),
-		os.Getenv("POSTGRES_SSL_MODE"),
-	)
-	var err error
-	db, err = pgxpool.New(context.Background(), dbURL)
-	if err != nil {
-		log.Fatalf("Unable to connect to PostgreSQL: %v", err)
-	}
-	if err := db.Ping(context.Background()); err != nil {
-		log.Fatalf("Unable to ping PostgreSQL: %v", err)
-	}
-
-	// Initialize Redis client
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
-		Password: os.Getenv("REDIS_PASSWORD"),
-		DB:       0,
-	})
-	if err := redisClient.Ping(redisCtx).Err(); err != nil {
-		panic("Could not connect to Redis: " + err.Error())
-	}
 	// Register job types
 	jobRegistry["add_numbers"] = func(id string, req SubmitJobRequest) (*job.Job, error) {
 		var payload job.AddNumbersPayload
@@ -275,112 +1210,81 @@ func main() {
 		return job.NewJob(id, "resize_image", job.ResizeImageJob, req.Priority, payload), nil
 	}
 
-	// Helper to normalize job type strings (e.g., AddNumbers -> add_numbers)
-	normalizeJobType := func(s string) string {
-		if s == "" {
-			return s
-		}
-		if strings.Contains(s, "_") {
-			return strings.ToLower(s)
-		}
-		var out []rune
-		for i, r := range s {
-			if i > 0 && r >= 'A' && r <= 'Z' {
-				out = append(out, '_')
-			}
-			out = append(out, r)
-		}
-		return strings.ToLower(string(out))
-	}
-
-	lookupFactory := func(t string) (JobFactory, bool) {
-		if f, ok := jobRegistry[t]; ok {
-			return f, true
-		}
-		nt := normalizeJobType(t)
-		if f, ok := jobRegistry[nt]; ok {
-			return f, true
-		}
-		// also try lowercasing directly
-		if f, ok := jobRegistry[strings.ToLower(t)]; ok {
-			return f, true
-		}
-		return nil, false
-	}
 	// Create workers
-	queueSize, err := strconv.Atoi(os.Getenv("WORKER_QUEUE_SIZE"))
+	workers := make([]*worker.Worker, 0, len(cfg.Workers))
+	for _, wc := range cfg.Workers {
+		workers = append(workers, newWorker(wc))
+	}
+	// Resized images, other job outputs and offloaded payloads/results
+	store, err := newArtifactStore(cfg.Artifacts)
 	if err != nil {
-		queueSize = 100 // default
+		fatal("invalid artifact store config", err)
 	}
+	job.Artifacts = store
+	offloadBytes = cfg.Artifacts.OffloadBytes
+	maxUploadBytes = cfg.Artifacts.MaxUploadBytes
+	progressInterval = cfg.API.ProgressCacheInterval.D()
 
-	workers := []*worker.Worker{
-		worker.NewWorkerWithQueueSize(
-			os.Getenv("WORKER_1_ID"),
-			getEnvInt("WORKER_1_THREADS", 8),
-			queueSize,
-		),
-		worker.NewWorkerWithQueueSize(
-			os.Getenv("WORKER_2_ID"),
-			getEnvInt("WORKER_2_THREADS", 2),
-			queueSize,
-		),
-	}
 	for _, w := range workers {
 		w.Start()
 	}
 
 	// Create scheduler
-	sched = scheduler.NewScheduler(workers)
+	oversizePolicy, err := scheduler.ParseOversizePolicy(cfg.Scheduler.OversizePolicy)
+	if err != nil {
+		fatal("invalid oversize policy", err)
+	}
+	if averages, err := loadRuntimeHistory(); err != nil {
+		logger.Warn("could not load runtime history", "error", err)
+	} else {
+		history.Seed(averages)
+	}
+	policy, err := scheduler.NewPolicy(cfg.Scheduler.Policy, history)
+	if err != nil {
+		fatal("invalid scheduling policy", err)
+	}
+	tenants := make(map[string]scheduler.TenantConfig, len(cfg.Scheduler.Tenants))
+	for name, t := range cfg.Scheduler.Tenants {
+		tenants[name] = t.TenantConfig()
+	}
+	sched = scheduler.NewSchedulerWithConfig(workers, scheduler.Config{
+		OversizePolicy:        oversizePolicy,
+		Policy:                policy,
+		Tenants:               tenants,
+		DefaultTenant:         cfg.Scheduler.DefaultTenant.TenantConfig(),
+		Preemption:            cfg.Scheduler.Preemption,
+		PreemptionGracePeriod: cfg.Scheduler.PreemptionGracePeriod.D(),
+		Backfill:              cfg.Scheduler.Backfill,
+		// Idle workers take queued jobs their peers can't start yet
+		WorkStealing: cfg.Scheduler.WorkStealing,
+		History:      history,
+	})
 	sched.Run()
-	defer sched.Stop()
+	metrics.RegisterState(sched)
+	if err := restoreQueuedJobs(); err != nil {
+		logger.Error("restoring queued jobs failed", "error", err)
+	}
+	// Grow and shrink the pool with the queue
+	stopScaling := func() {}
+	if cfg.Autoscale.Enabled {
+		stopScaling = startAutoscaler(cfg.Autoscale)
+	}
 
 	r.POST("/jobs", func(c *gin.Context) {
+		if draining.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "shutting down, not accepting jobs"})
+			return
+		}
 		var req SubmitJobRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		factory, ok := lookupFactory(req.Type)
-		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported job type"})
-			return
-		}
-
-		id := uuid.New().String()
-		created := time.Now()
-		j, err := factory(id, req)
+		j, status, err := enqueueJob(c.Request.Context(), uuid.New().String(), req, time.Now())
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
-
-		j.ThreadDemand = req.ThreadDemand
-		j.CreatedAt = created
-
-		jobsMu.Lock()
-		jobs[j.ID] = j
-		jobsMu.Unlock()
-
-		// Write job state to Redis
-		jobJSON, _ := json.Marshal(j)
-		redisClient.Set(redisCtx, "job:"+j.ID, jobJSON, 0)
-
-		sched.Submit(j)
-
-		// Wait for job completion and insert into DB
-		go func(jobPtr *job.Job) {
-			for {
-				time.Sleep(50 * time.Millisecond)
-				if jobPtr.Status == "Completed" {
-					if err := insertJobToDB(jobPtr); err != nil {
-						log.Printf("Failed to insert job %s to DB: %v", jobPtr.ID, err)
-					}
-					break
-				}
-			}
-		}(j)
-
 		c.JSON(http.StatusAccepted, jobToResponse(j))
 	})
 
@@ -416,28 +1320,423 @@ func main() {
 		c.JSON(http.StatusOK, jobToResponse(j))
 	})
 
-	port := os.Getenv("API_PORT")
-	if port == "" {
-		port = "8080"
+	// Explain why a queued job hasn't been placed yet: per worker reason, "" if it fits now
+	r.GET("/jobs/:id/placement", func(c *gin.Context) {
+		id := c.Param("id")
+		jobsMu.RLock()
+		j, ok := jobs[id]
+		jobsMu.RUnlock()
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"id":      j.ID,
+			"status":  j.Status,
+			"workers": sched.Explain(j),
+		})
+	})
+
+	registerArtifactRoutes(r)
+	registerJobLogRoutes(r)
+	registerWorkerRoutes(r)
+	if authenticator != nil {
+		registerKeyRoutes(r, authenticator)
 	}
-	r.Run(":" + port)
+
+	// Liveness only looks at this process; readiness adds its dependencies
+	// and workers
+	live := map[string]healthCheck{"scheduler": schedulerCheck(0)}
+	ready := map[string]healthCheck{
+		"postgres":  db.Ping,
+		"redis":     pingRedis,
+		"scheduler": schedulerCheck(cfg.Health.MaxDispatchWait.D()),
+		"accepting": func(ctx context.Context) error {
+			if draining.Load() {
+				return errors.New("shutting down")
+			}
+			return nil
+		},
+	}
+	ready["workers"] = workersCheck(3 * worker.HeartbeatInterval)
+	registerHealthRoutes(r, live, ready, cfg.Health.CheckTimeout.D())
+
+	// Prometheus scrape endpoint
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	srv := &http.Server{Addr: ":" + cfg.API.Port, Handler: r}
+	go func() {
+		logger.Info("API listening", "port", cfg.API.Port, "workers", len(workers))
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("server stopped", err)
+		}
+	}()
+
+	// Finish up on SIGINT/SIGTERM instead of dropping in-flight jobs
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	// Stop resizing the pool before it drains
+	stopScaling()
+	shutdown(srv, cfg.API.ShutdownTimeout.D())
 }
 
-// insertJobToDB inserts a completed job into the jobs table
-func insertJobToDB(j *job.Job) error {
-	resultJSON, err := json.Marshal(j.Result)
+// enqueueJob builds job id from req and hands it to the scheduler. On error
+// status is the HTTP status to report it with.
+func enqueueJob(ctx context.Context, id string, req SubmitJobRequest, created time.Time) (j *job.Job, status int, err error) {
+	factory, ok := lookupFactory(req.Type)
+	if !ok {
+		return nil, http.StatusBadRequest, errors.New("unsupported job type")
+	}
+
+	_, decodeSpan := tracing.Tracer.Start(ctx, "job.decode", trace.WithAttributes(attribute.String("job.type", req.Type)))
+	j, err = newJobFromRequest(factory, id, req)
 	if err != nil {
+		decodeSpan.SetStatus(codes.Error, err.Error())
+		decodeSpan.End()
+		return nil, http.StatusBadRequest, err
+	}
+	decodeSpan.End()
+	// Scheduling and execution spans join the request's trace
+	j.TraceParent = tracing.Inject(ctx)
+	// Everything logged about the job from here on is kept for
+	// GET /jobs/:id/logs
+	j.SetLogger(jobLogs.Logger(id, logger))
+	// Progress reaches GET /jobs/:id through the Redis copy
+	j.OnProgress(progressInterval, cacheJob)
+	j.ThreadDemand = req.ThreadDemand
+	j.MemoryMB = req.MemoryMB
+	j.CustomResources = req.Resources
+	j.Constraints = req.Constraints
+	j.Tenant = req.Tenant
+	j.Preemptible = req.Preemptible
+	j.Gang = req.Gang
+	j.CreatedAt = created
+
+	if err := j.Constraints.Validate(); err != nil {
+		jobLogs.Remove(id)
+		return nil, http.StatusBadRequest, err
+	}
+	if err := (job.Resources{Threads: j.ThreadDemand, MemoryMB: j.MemoryMB, Custom: j.CustomResources}).Validate(); err != nil {
+		jobLogs.Remove(id)
+		return nil, http.StatusBadRequest, err
+	}
+	// Keep big payloads out of memory, Redis and Postgres until the job runs
+	if err := j.OffloadPayload(offloadBytes); err != nil {
+		j.Log().Error("offloading payload failed", "error", err)
+		jobLogs.Remove(id)
+		return nil, http.StatusInternalServerError, err
+	}
+
+	// Reject jobs that no worker could ever run, or that would put the
+	// tenant over its queue quota
+	if err := sched.Submit(j); err != nil {
+		status := http.StatusUnprocessableEntity
+		switch {
+		case errors.Is(err, scheduler.ErrQuotaExceeded):
+			status = http.StatusTooManyRequests
+		case errors.Is(err, scheduler.ErrDraining):
+			status = http.StatusServiceUnavailable
+		}
+		jobLogs.Remove(id)
+		dropOffloadedPayload(j, req)
+		return nil, status, err
+	}
+
+	metrics.JobSubmitted(j)
+	j.Log().Info("job submitted", "type", j.Type, "priority", j.Priority, "threads", j.ThreadDemand, "tenant", j.Tenant)
+
+	// An offloaded payload is resubmitted by reference
+	if j.PayloadRef != "" {
+		req.Payload = nil
+		req.PayloadRef = j.PayloadRef
+	}
+	jobsMu.Lock()
+	jobs[j.ID] = j
+	requests[j.ID] = req
+	jobsMu.Unlock()
+
+	// Write job state to Redis
+	cacheJob(j)
+	watchJob(j)
+	return j, http.StatusAccepted, nil
+}
+
+// dropOffloadedPayload deletes the artifact OffloadPayload wrote for a job
+// that was then rejected. A payload_ref the client uploaded is theirs.
+func dropOffloadedPayload(j *job.Job, req SubmitJobRequest) {
+	if j.PayloadRef == "" || j.PayloadRef == req.PayloadRef {
+		return
+	}
+	if err := job.Artifacts.Delete(j.PayloadRef); err != nil {
+		logger.Warn("deleting offloaded payload failed", "key", j.PayloadRef, "error", err)
+	}
+}
+
+// watchJob waits in the background for j to finish (successfully or not)
+// and records it in Postgres, unless j is parked for the next process first
+func watchJob(j *job.Job) {
+	watchers.Add(1)
+	go func() {
+		defer watchers.Done()
+		for {
+			time.Sleep(50 * time.Millisecond)
+			if st := j.CurrentStatus(); st == job.Completed || st == job.Failed {
+				break
+			}
+			jobsMu.RLock()
+			gone := parked[j.ID]
+			jobsMu.RUnlock()
+			if gone {
+				return
+			}
+		}
+		jobsMu.Lock()
+		delete(requests, j.ID)
+		jobsMu.Unlock()
+		metrics.JobFinished(j)
+		if started, completed := j.Times(); j.CurrentStatus() == job.Completed && !started.IsZero() {
+			history.Observe(j.Type, completed.Sub(started))
+		}
+		if err := j.OffloadResult(offloadBytes); err != nil {
+			j.Log().Error("offloading result failed", "error", err)
+		}
+		if err := insertJobToDB(j); err != nil {
+			j.Log().Error("inserting job into DB failed", "error", err)
+		} else if err := persistJobLogs(j.ID); err != nil {
+			logger.Error("persisting job logs failed", "job_id", j.ID, "error", err)
+		}
+		// Refresh the cached copy so GET /jobs/:id sees the final state
+		cacheJob(j)
+	}()
+}
+
+// shutdown stops taking jobs, gives running ones until timeout to finish,
+// queues the rest for the next process and stops the HTTP server
+func shutdown(srv *http.Server, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	draining.Store(true)
+	queued := sched.Drain()
+	logger.Info("shutting down", "queued", len(queued), "running", len(sched.Running()), "timeout", timeout)
+	for len(sched.Running()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Jobs preempted meanwhile are queued again; jobs still running start
+	// over in the next process
+	queued = append(queued, sched.Drain()...)
+	running := sched.Running()
+	if len(running) > 0 {
+		logger.Warn("shutdown timeout reached, running jobs will start over", "running", len(running))
+	}
+	leftover := dedupeJobs(append(queued, running...))
+	park(leftover)
+	if err := persistQueuedJobs(leftover); err != nil {
+		logger.Error("persisting queued jobs failed", "jobs", len(leftover), "error", err)
+	} else if len(leftover) > 0 {
+		logger.Info("queued jobs for the next process", "jobs", len(leftover))
+	}
+
+	// Stop the placement loops and workers. Jobs that are still running hold
+	// Stop up until they finish, so don't wait for them past the deadline.
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), max(time.Until(deadline), time.Second))
+	defer stopCancel()
+	stopped := make(chan struct{})
+	go func() {
+		sched.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-stopCtx.Done():
+		logger.Warn("scheduler still stopping, exiting with jobs running", "running", len(sched.Running()))
+	}
+
+	// Let jobs that finished reach Postgres, then close connections
+	waitTimeout(&watchers, max(time.Until(deadline), time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), max(time.Until(deadline), 5*time.Second))
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warn("closing open connections", "error", err)
+		srv.Close()
+	}
+	redisClient.Close()
+	db.Close()
+	logger.Info("shutdown complete")
+}
+
+// dedupeJobs drops repeats of a job from js, keeping the first
+func dedupeJobs(js []*job.Job) []*job.Job {
+	seen := make(map[string]bool, len(js))
+	out := js[:0]
+	for _, j := range js {
+		if !seen[j.ID] {
+			seen[j.ID] = true
+			out = append(out, j)
+		}
+	}
+	return out
+}
+
+// park stops watching js; the next process runs them
+func park(js []*job.Job) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	for _, j := range js {
+		parked[j.ID] = true
+	}
+}
+
+// waitTimeout waits for wg, at most d. It reports whether wg finished.
+func waitTimeout(wg *sync.WaitGroup, d time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+// persistQueuedJobs stores how js were submitted in queued_jobs, for
+// restoreQueuedJobs in the next process
+func persistQueuedJobs(js []*job.Job) error {
+	batch := &pgx.Batch{}
+	jobsMu.RLock()
+	for _, j := range js {
+		req, ok := requests[j.ID]
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(req)
+		if err != nil {
+			jobsMu.RUnlock()
+			return err
+		}
+		batch.Queue(`
+		       INSERT INTO queued_jobs (id, request, created_at) VALUES ($1, $2, $3)
+		       ON CONFLICT (id) DO UPDATE SET request = EXCLUDED.request
+		       `, j.ID, data, j.CreatedAt)
+	}
+	jobsMu.RUnlock()
+	if batch.Len() == 0 {
+		return nil
+	}
+	return db.SendBatch(context.Background(), batch).Close()
+}
+
+// restoreQueuedJobs submits the jobs a previous process queued on shutdown,
+// oldest first, keeping their IDs. Jobs that finished before that process
+// exited are skipped.
+func restoreQueuedJobs() error {
+	rows, err := db.Query(context.Background(), `
+	       SELECT q.id::text, q.request, q.created_at FROM queued_jobs q
+	       WHERE NOT EXISTS (SELECT 1 FROM jobs j WHERE j.id = q.id)
+	       ORDER BY q.created_at
+	       `)
+	if err != nil {
+		return err
+	}
+	type queued struct {
+		id      string
+		req     SubmitJobRequest
+		created time.Time
+	}
+	var restore []queued
+	var ids []string
+	for rows.Next() {
+		var q queued
+		var data []byte
+		if err := rows.Scan(&q.id, &data, &q.created); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, q.id)
+		if err := json.Unmarshal(data, &q.req); err != nil {
+			logger.Warn("skipping unreadable queued job", "job_id", q.id, "error", err)
+			continue
+		}
+		restore = append(restore, q)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
 		return err
 	}
+
+	for _, q := range restore {
+		if _, _, err := enqueueJob(context.Background(), q.id, q.req, q.created); err != nil {
+			logger.Warn("dropping queued job", "job_id", q.id, "error", err)
+		}
+	}
+	if len(restore) > 0 {
+		logger.Info("restored queued jobs", "jobs", len(restore))
+	}
 	_, err = db.Exec(context.Background(), `
-	       INSERT INTO jobs (id, type, priority, thread_demand, status, created_at, started_at, completed_at, result, worker_id)
-	       VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	       DELETE FROM queued_jobs
+	       WHERE id::text = ANY($1) OR id IN (SELECT id FROM jobs)
+	       `, ids)
+	return err
+}
+
+// cacheJob writes j's current state to Redis for GET /jobs/:id
+func cacheJob(j *job.Job) {
+	ctx, span := tracing.Tracer.Start(j.TraceContext(), "redis.cache_job", trace.WithAttributes(attribute.String("job.id", j.ID)))
+	defer span.End()
+	data, err := json.Marshal(j)
+	if err == nil {
+		err = redisClient.Set(ctx, "job:"+j.ID, data, 0).Err()
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		logger.Error("caching job in Redis failed", "job_id", j.ID, "error", err)
+	}
+}
+
+// insertJobToDB inserts a finished (completed or failed) job into the jobs table
+func insertJobToDB(j *job.Job) (err error) {
+	ctx, span := tracing.Tracer.Start(j.TraceContext(), "postgres.insert_job", trace.WithAttributes(attribute.String("job.id", j.ID)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+	resultJSON, err := json.Marshal(j.Result)
+	if err != nil {
+		return err
+	}
+	var errorJSON []byte
+	if j.Error != nil {
+		if errorJSON, err = json.Marshal(j.Error); err != nil {
+			return err
+		}
+	}
+	var outcomeJSON []byte
+	if j.ThreadOutcome != nil {
+		if outcomeJSON, err = json.Marshal(j.ThreadOutcome); err != nil {
+			return err
+		}
+	}
+	_, err = db.Exec(ctx, `
+	       INSERT INTO jobs (id, type, priority, thread_demand, status, created_at, started_at, completed_at, result, worker_id, error, thread_outcome, tenant, preemptible, attempts, payload_ref, result_ref, trace_id)
+	       VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 	       ON CONFLICT (id) DO UPDATE SET
 		       status = EXCLUDED.status,
 		       started_at = EXCLUDED.started_at,
 		       completed_at = EXCLUDED.completed_at,
 		       result = EXCLUDED.result,
-		       worker_id = EXCLUDED.worker_id
+		       worker_id = EXCLUDED.worker_id,
+		       error = EXCLUDED.error,
+		       thread_outcome = EXCLUDED.thread_outcome,
+		       tenant = EXCLUDED.tenant,
+		       attempts = EXCLUDED.attempts,
+		       result_ref = EXCLUDED.result_ref
 	       `,
 		j.ID,
 		j.Type,
@@ -449,6 +1748,14 @@ func insertJobToDB(j *job.Job) error {
 		j.CompletedAt,
 		resultJSON,
 		nil, // worker_id
+		errorJSON,
+		outcomeJSON,
+		j.Tenant,
+		j.Preemptible,
+		j.Attempts,
+		nullIfEmpty(j.PayloadRef),
+		nullIfEmpty(j.ResultRef),
+		nullIfEmpty(tracing.TraceID(j.TraceParent)),
 	)
 
 	// Log performance metrics if job is completed
@@ -456,7 +1763,7 @@ func insertJobToDB(j *job.Job) error {
 		queueTime := j.StartedAt.Sub(j.CreatedAt).Seconds()
 		execTime := j.CompletedAt.Sub(j.StartedAt).Seconds()
 		totalTime := j.CompletedAt.Sub(j.CreatedAt).Seconds()
-		_, _ = db.Exec(context.Background(), `
+		_, _ = db.Exec(ctx, `
 		       INSERT INTO job_metrics (job_id, metric_name, metric_value)
 		       VALUES ($1, $2, $3), ($1, $4, $5), ($1, $6, $7), ($1, $8, $9)
 	       `,
@@ -468,3 +1775,34 @@ func insertJobToDB(j *job.Job) error {
 	}
 	return err
 }
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// loadRuntimeHistory averages the recorded execution_time metric per job type
+func loadRuntimeHistory() (map[job.JobType]time.Duration, error) {
+	rows, err := db.Query(context.Background(), `
+	       SELECT j.type, AVG(m.metric_value)
+	       FROM job_metrics m JOIN jobs j ON j.id = m.job_id
+	       WHERE m.metric_name = 'execution_time'
+	       GROUP BY j.type
+	       `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	averages := make(map[job.JobType]time.Duration)
+	for rows.Next() {
+		var jobType string
+		var seconds float64
+		if err := rows.Scan(&jobType, &seconds); err != nil {
+			return nil, err
+		}
+		averages[job.JobType(jobType)] = time.Duration(seconds * float64(time.Second))
+	}
+	return averages, rows.Err()
+}
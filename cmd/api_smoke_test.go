@@ -14,6 +14,9 @@ import (
 	"time"
 )
 
+// onePixelPNG is a 1x1 red PNG so resize_image needs no network
+const onePixelPNG = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR4nGP4z8DwHwAFAAH/iZk9HQAAAABJRU5ErkJggg=="
+
 type submitReq struct {
 	Type         string      `json:"type"`
 	Priority     int         `json:"priority"`
@@ -119,7 +122,7 @@ func TestAPI_Smoke_AllJobs(t *testing.T) {
 	}
 
 	// ResizeImage
-	ir := submitJob(t, submitReq{Type: "resize_image", Priority: 1, ThreadDemand: 1, Payload: map[string]interface{}{"url": "http://ex/1.png", "width": 10, "height": 20}})
+	ir := submitJob(t, submitReq{Type: "resize_image", Priority: 1, ThreadDemand: 1, Payload: map[string]interface{}{"url": onePixelPNG, "width": 10, "height": 20}})
 	irr := pollJob(t, ir.ID, 10*time.Second)
 	if irr.Status != "Completed" {
 		t.Fatalf("resize_image not completed: %v", irr)
@@ -5,9 +5,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -18,7 +21,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/artifact"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/auth"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/config"
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/joblog"
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/scheduler"
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
 )
@@ -40,19 +47,16 @@ func setupRouter() *gin.Engine {
 
 	// Reset globals for each test
 	jobs = make(map[string]*job.Job)
+	jobLogs = joblog.NewStore(0, slog.LevelDebug)
+	job.Artifacts = artifact.NewLocalStore(os.TempDir() + "/api-test-artifacts")
 
-	queueSize := getEnvInt("WORKER_QUEUE_SIZE", 10)
-	workers := []*worker.Worker{
-		worker.NewWorkerWithQueueSize(
-			os.Getenv("WORKER_1_ID"),
-			getEnvInt("WORKER_1_THREADS", 4),
-			queueSize,
-		),
-		worker.NewWorkerWithQueueSize(
-			os.Getenv("WORKER_2_ID"),
-			getEnvInt("WORKER_2_THREADS", 2),
-			queueSize,
-		),
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatalf("invalid test config: %v", err)
+	}
+	workers := make([]*worker.Worker, 0, len(cfg.Workers))
+	for _, wc := range cfg.Workers {
+		workers = append(workers, newWorker(wc))
 	}
 	for _, w := range workers {
 		w.Start()
@@ -103,20 +107,35 @@ func setupRouter() *gin.Engine {
 
 		id := uuid.New().String()
 		created := time.Now()
-		j, err := factory(id, req)
+		j, err := newJobFromRequest(factory, id, req)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		j.SetLogger(jobLogs.Logger(id, logger))
 
 		j.ThreadDemand = req.ThreadDemand
+		j.MemoryMB = req.MemoryMB
+		j.CustomResources = req.Resources
+		j.Constraints = req.Constraints
+		j.Tenant = req.Tenant
+		j.Preemptible = req.Preemptible
+		j.Gang = req.Gang
 		j.CreatedAt = created
 
+		if err := sched.Submit(j); err != nil {
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, scheduler.ErrQuotaExceeded) {
+				status = http.StatusTooManyRequests
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
 		jobsMu.Lock()
 		jobs[j.ID] = j
 		jobsMu.Unlock()
 
-		sched.Submit(j)
 		c.JSON(http.StatusAccepted, jobToResponse(j))
 	})
 
@@ -142,6 +161,9 @@ func setupRouter() *gin.Engine {
 		c.JSON(http.StatusOK, jobToResponse(j))
 	})
 
+	registerArtifactRoutes(r)
+	registerJobLogRoutes(r)
+
 	return r
 }
 
@@ -289,6 +311,236 @@ func TestLargeArraySumJob(t *testing.T) {
 	if sumResult.Sum != 15 {
 		t.Errorf("expected sum 15, got %d", sumResult.Sum)
 	}
+
+	// The finished job reports all of its chunks done
+	req, _ = http.NewRequest(http.MethodGet, "/jobs/"+resp.ID, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if p := resp.Progress; p == nil || p.Percent != 100 || p.Processed != p.Total {
+		t.Errorf("expected complete progress, got %+v", p)
+	}
+}
+
+func TestSubmitJobWithUploadedPayload(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	r := setupRouter()
+	defer sched.Stop()
+
+	// Upload the payload, then submit the job by reference
+	req, _ := http.NewRequest(http.MethodPost, "/artifacts?name=payload.json", strings.NewReader(`{"array":[1,2,3,4,5]}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload failed: %d %s", w.Code, w.Body.String())
+	}
+	var a artifact.Artifact
+	if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+
+	jobData := `{"type":"large_array_sum","priority":1,"thread_demand":2,"payload_ref":"` + a.Key + `"}`
+	req, _ = http.NewRequest(http.MethodPost, "/jobs", strings.NewReader(jobData))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp JobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if resp.PayloadRef != a.Key {
+		t.Fatalf("expected payload_ref %s, got %+v", a.Key, resp)
+	}
+
+	j := waitForJobCompletion(resp.ID, 1*time.Second)
+	if j == nil {
+		t.Fatalf("job did not complete within timeout")
+	}
+	if sum, ok := j.Result.(job.LargeArraySumResult); !ok || sum.Sum != 15 {
+		t.Errorf("expected sum 15, got %v", j.Result)
+	}
+
+	// The uploaded artifact can be downloaded again
+	req, _ = http.NewRequest(http.MethodGet, "/artifacts/"+a.Key, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"array":[1,2,3,4,5]}` {
+		t.Errorf("download failed: %d %s", w.Code, w.Body.String())
+	}
+
+	// Unknown references are rejected up front
+	req, _ = http.NewRequest(http.MethodPost, "/jobs", strings.NewReader(`{"type":"large_array_sum","priority":1,"thread_demand":1,"payload_ref":"uploads/missing"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing payload_ref, got %d", w.Code)
+	}
+}
+
+func TestUploadRejectsOversizedArtifact(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	r := setupRouter()
+	defer sched.Stop()
+	prev := maxUploadBytes
+	maxUploadBytes = 8
+	defer func() { maxUploadBytes = prev }()
+
+	// Once with a Content-Length, once streamed without one
+	for _, body := range []io.Reader{strings.NewReader("too many bytes"), io.MultiReader(strings.NewReader("too many bytes"))} {
+		req, _ := http.NewRequest(http.MethodPost, "/artifacts", body)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected 413, got %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "/artifacts", strings.NewReader("fits"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected a small upload to be stored, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestJobLogs(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	r := setupRouter()
+	defer sched.Stop()
+
+	jobData := `{"type":"add_numbers","priority":1,"thread_demand":1,"payload":{"x":1,"y":2}}`
+	req, _ := http.NewRequest(http.MethodPost, "/jobs", strings.NewReader(jobData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp JobResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if waitForJobCompletion(resp.ID, 1*time.Second) == nil {
+		t.Fatalf("job did not complete within timeout")
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/jobs/"+resp.ID+"/logs", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var lines []joblog.Entry
+	if err := json.Unmarshal(w.Body.Bytes(), &lines); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	var messages []string
+	for _, l := range lines {
+		messages = append(messages, l.Message)
+	}
+	if !strings.Contains(strings.Join(messages, "|"), "job started") || messages[len(messages)-1] != "job finished" {
+		t.Errorf("unexpected job log: %v", messages)
+	}
+
+	// Following a finished job's log streams what's there and ends
+	buf, _ := jobLogs.Get(resp.ID)
+	buf.Close()
+	req, _ = http.NewRequest(http.MethodGet, "/jobs/"+resp.ID+"/logs?tail=1&follow=true", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("expected NDJSON, got %q", w.Header().Get("Content-Type"))
+	}
+	var last joblog.Entry
+	if err := json.Unmarshal(bytes.TrimSpace(w.Body.Bytes()), &last); err != nil || last.Message != "job finished" {
+		t.Errorf("expected only the last line, got %q", w.Body.String())
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	r := setupRouter()
+	defer sched.Stop()
+	registerHealthRoutes(r,
+		map[string]healthCheck{"scheduler": schedulerCheck(0)},
+		map[string]healthCheck{
+			"scheduler": schedulerCheck(time.Second),
+			"postgres":  func(ctx context.Context) error { return errors.New("connection refused") },
+		},
+		time.Second)
+
+	req, _ := http.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected a live scheduler to pass /healthz, got %d %s", w.Code, w.Body.String())
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/readyz", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp HealthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable || resp.Checks["postgres"].Status != "fail" || resp.Checks["scheduler"].Status != "ok" {
+		t.Errorf("expected /readyz to fail on postgres only, got %d %+v", w.Code, resp)
+	}
+}
+
+func TestWorkerAdmin(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	r := setupRouter()
+	defer sched.Stop()
+	registerWorkerRoutes(r)
+
+	do := func(method, path, body string) (*httptest.ResponseRecorder, WorkerResponse) {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp WorkerResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	w, resp := do(http.MethodPost, "/workers", `{"id":"w3","threads":2,"labels":{"tier":"gpu"}}`)
+	if w.Code != http.StatusCreated || resp.Threads != 2 || resp.State != "active" {
+		t.Fatalf("expected the worker to be added, got %d %s", w.Code, w.Body.String())
+	}
+	if w, _ = do(http.MethodPost, "/workers", `{"id":"w3","threads":1}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a duplicate ID, got %d", w.Code)
+	}
+	if w, _ = do(http.MethodPost, "/workers", `{"id":"w4","threads":0}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for 0 threads, got %d", w.Code)
+	}
+
+	if w, resp = do(http.MethodPatch, "/workers/w3", `{"threads":4}`); w.Code != http.StatusOK || resp.Threads != 4 || resp.FreeThreads != 4 {
+		t.Errorf("expected the worker resized to 4 threads, got %d %s", w.Code, w.Body.String())
+	}
+	if _, resp = do(http.MethodPost, "/workers/w3/pause", ""); resp.State != "paused" {
+		t.Errorf("expected paused, got %q", resp.State)
+	}
+	if _, resp = do(http.MethodPost, "/workers/w3/resume", ""); resp.State != "active" {
+		t.Errorf("expected active, got %q", resp.State)
+	}
+
+	if w, _ = do(http.MethodDelete, "/workers/w3?timeout=1s", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected the worker to be removed, got %d %s", w.Code, w.Body.String())
+	}
+	if w, _ = do(http.MethodDelete, "/workers/w3", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a removed worker, got %d", w.Code)
+	}
+	if n := len(sched.Workers()); n != 2 {
+		t.Errorf("expected the two configured workers left, got %d", n)
+	}
 }
 
 func TestUnsupportedJobType(t *testing.T) {
@@ -340,22 +592,8 @@ func TestJobThreadDemandTooHigh(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	var resp JobResponse
-	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
-		t.Fatalf("invalid JSON response: %v", err)
-	}
-
-	j := waitForJobCompletion(resp.ID, 1*time.Second)
-	if j == nil {
-		t.Fatalf("job did not complete within timeout")
-	}
-
-	addResult, ok := j.Result.(job.AddNumbersResult)
-	if !ok {
-		t.Fatalf("expected result to be AddNumbersResult, got %T", j.Result)
-	}
-	if addResult.Sum != 3 {
-		t.Errorf("expected sum 3, got %d", addResult.Sum)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for thread demand no worker can satisfy, got %d", w.Code)
 	}
 }
 
@@ -420,3 +658,137 @@ func TestMultipleJobsConcurrency(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthAndCORS(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	setupRouter()
+	defer sched.Stop()
+	const bootstrap = "bootstrap-key-for-tests-0123456789"
+	a := auth.New(auth.Config{BootstrapKey: bootstrap}, auth.NewMemoryKeyStore())
+	r := gin.New()
+	r.Use(corsMiddleware([]string{"https://app.example.com"}), auth.Gin(a, requiredScope))
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/jobs", func(c *gin.Context) { c.JSON(http.StatusOK, []JobResponse{}) })
+	registerWorkerRoutes(r)
+	registerKeyRoutes(r, a)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/healthz", "", ""); w.Code != http.StatusOK {
+		t.Errorf("expected the probe open, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/jobs", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a key, got %d", w.Code)
+	}
+
+	w := do(http.MethodPost, "/keys", bootstrap, `{"name":"dashboard","scopes":["read"],"expires_in":"24h"}`)
+	var created CreatedKeyResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if w.Code != http.StatusCreated || !strings.HasPrefix(created.APIKey, "djs_") || created.ExpiresAt == nil {
+		t.Fatalf("expected a key created, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/keys", bootstrap, `{"name":"x","scopes":["root"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown scope, got %d", w.Code)
+	}
+
+	if w := do(http.MethodGet, "/jobs", created.APIKey, ""); w.Code != http.StatusOK {
+		t.Errorf("expected the read key to list jobs, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/workers", created.APIKey, ""); w.Code != http.StatusOK {
+		t.Errorf("expected the read key to list workers, got %d", w.Code)
+	}
+	for _, path := range []string{"/workers/w1/pause", "/keys"} {
+		if w := do(http.MethodPost, path, created.APIKey, `{}`); w.Code != http.StatusForbidden {
+			t.Errorf("expected 403 for POST %s with a read key, got %d", path, w.Code)
+		}
+	}
+
+	w = do(http.MethodGet, "/keys", bootstrap, "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.APIKey) || !strings.Contains(w.Body.String(), created.ID) {
+		t.Errorf("expected the key listed without its secret, got %s", w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/keys/"+created.ID, bootstrap, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected the key revoked, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/jobs", created.APIKey, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a revoked key, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/keys/nope", bootstrap, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown key, got %d", w.Code)
+	}
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodOptions, "/jobs", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	w = preflight("https://app.example.com")
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		!strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("expected the allowed origin's preflight to pass, got %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Error("expected credentials not to be allowed")
+	}
+	w = preflight("https://evil.example.com")
+	if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected other origins refused, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestSubmitRejectsNegativeDemand(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	setupRouter()
+	defer sched.Stop()
+
+	for name, req := range map[string]SubmitJobRequest{
+		"memory":   {MemoryMB: -512},
+		"resource": {Resources: map[string]int{"gpu-license": -1}},
+	} {
+		req.Type, req.Priority, req.ThreadDemand = "add_numbers", 1, 1
+		req.Payload = map[string]interface{}{"x": 1, "y": 2}
+		_, status, err := enqueueJob(context.Background(), uuid.New().String(), req, time.Now())
+		if status != http.StatusBadRequest || err == nil {
+			t.Errorf("%s: expected 400 for a negative demand, got %d %v", name, status, err)
+		}
+	}
+}
+
+func TestRejectedJobLeavesNoOffloadedPayload(t *testing.T) {
+	if os.Getenv("RUN_INTEGRATION") != "1" {
+		t.Skip("integration tests disabled; set RUN_INTEGRATION=1 to enable")
+	}
+	setupRouter()
+	defer sched.Stop()
+	prev := offloadBytes
+	offloadBytes = 1
+	defer func() { offloadBytes = prev }()
+
+	// Big enough to offload, but no worker has a thousand threads
+	id := uuid.New().String()
+	req := SubmitJobRequest{Type: "large_array_sum", Priority: 1, ThreadDemand: 1000}
+	req.Payload = map[string]interface{}{"array": []int{1, 2, 3}}
+	_, status, err := enqueueJob(context.Background(), id, req, time.Now())
+	if status != http.StatusUnprocessableEntity || err == nil {
+		t.Fatalf("expected 422, got %d %v", status, err)
+	}
+	if _, err := job.Artifacts.Stat("payloads/" + id + ".json"); !errors.Is(err, artifact.ErrNotFound) {
+		t.Errorf("expected the offloaded payload to be deleted, got %v", err)
+	}
+}
@@ -9,9 +9,33 @@ CREATE TABLE IF NOT EXISTS jobs (
     started_at TIMESTAMP,
     completed_at TIMESTAMP,
     result JSONB,
-    worker_id TEXT
+    worker_id TEXT,
+    error JSONB,
+    thread_outcome JSONB,
+    tenant TEXT,
+    preemptible BOOLEAN NOT NULL DEFAULT FALSE,
+    attempts INT NOT NULL DEFAULT 0,
+    payload_ref TEXT,
+    result_ref TEXT,
+    trace_id TEXT
 );
 
+-- structured failure details (code, message, retryable, stack) for Failed jobs
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS error JSONB;
+-- how an oversized thread_demand was handled (policy, requested, granted)
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS thread_outcome JSONB;
+-- who submitted the job, for per-tenant fair share and quotas
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tenant TEXT;
+-- whether the job may be preempted, and how many times a worker picked it up
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS preemptible BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+-- artifact store keys of payloads and results too large to keep inline
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS payload_ref TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result_ref TEXT;
+-- W3C trace the job was submitted in, to find a job from a trace and back
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS trace_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_jobs_trace_id ON jobs(trace_id);
+
 CREATE TABLE IF NOT EXISTS workers (
     id TEXT PRIMARY KEY,
     name TEXT NOT NULL,
@@ -27,6 +51,30 @@ CREATE TABLE IF NOT EXISTS job_logs (
     message TEXT NOT NULL,
     level TEXT
 );
+-- structured attributes of the log line
+ALTER TABLE job_logs ADD COLUMN IF NOT EXISTS attrs JSONB;
+CREATE INDEX IF NOT EXISTS idx_job_logs_job_id ON job_logs(job_id);
+
+-- Jobs a shutting-down process didn't get to, as submitted; the next
+-- process submits them again
+CREATE TABLE IF NOT EXISTS queued_jobs (
+    id UUID PRIMARY KEY,
+    request JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    queued_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- API keys; only a SHA-256 hash of each key's secret is kept
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
 
 CREATE TABLE IF NOT EXISTS job_metrics (
     id SERIAL PRIMARY KEY,
@@ -9,9 +9,31 @@ CREATE TABLE IF NOT EXISTS jobs (
     started_at TIMESTAMP,
     completed_at TIMESTAMP,
     result JSONB,
-    worker_id VARCHAR(255)
+    worker_id VARCHAR(255),
+    error JSONB,
+    thread_outcome JSONB,
+    tenant TEXT,
+    preemptible BOOLEAN NOT NULL DEFAULT FALSE,
+    attempts INT NOT NULL DEFAULT 0,
+    payload_ref TEXT,
+    result_ref TEXT,
+    trace_id TEXT
 );
 
+-- Add columns to existing jobs tables
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS error JSONB;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS thread_outcome JSONB;
+-- who submitted the job, for per-tenant fair share and quotas
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS tenant TEXT;
+-- whether the job may be preempted, and how many times a worker picked it up
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS preemptible BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+-- artifact store keys of payloads and results too large to keep inline
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS payload_ref TEXT;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS result_ref TEXT;
+-- W3C trace the job was submitted in, to find a job from a trace and back
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS trace_id TEXT;
+
 -- Create metrics table
 CREATE TABLE IF NOT EXISTS job_metrics (
     id SERIAL PRIMARY KEY,
@@ -21,8 +43,41 @@ CREATE TABLE IF NOT EXISTS job_metrics (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create job log table, filled from each job's captured log when it finishes
+CREATE TABLE IF NOT EXISTS job_logs (
+    id SERIAL PRIMARY KEY,
+    job_id VARCHAR(255) REFERENCES jobs(id),
+    timestamp TIMESTAMP NOT NULL DEFAULT NOW(),
+    message TEXT NOT NULL,
+    level TEXT,
+    attrs JSONB
+);
+
+-- Jobs a shutting-down process didn't get to, as submitted; the next
+-- process submits them again
+CREATE TABLE IF NOT EXISTS queued_jobs (
+    id VARCHAR(255) PRIMARY KEY,
+    request JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    queued_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- API keys; only a SHA-256 hash of each key's secret is kept
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
 -- Create indexes
 CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
 CREATE INDEX IF NOT EXISTS idx_jobs_created_at ON jobs(created_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_trace_id ON jobs(trace_id);
 CREATE INDEX IF NOT EXISTS idx_job_metrics_job_id ON job_metrics(job_id);
+CREATE INDEX IF NOT EXISTS idx_job_logs_job_id ON job_logs(job_id);
 CREATE INDEX IF NOT EXISTS idx_job_metrics_name ON job_metrics(metric_name);
@@ -4,6 +4,7 @@ import JobList from "./components/JobList";
 import Stats from "./components/Stats";
 import JobSubmitForm from "./components/JobSubmitForm";
 import Analytics from "./pages/Analytics";
+import JobDetail from "./pages/JobDetail";
 import { BrowserRouter as Router, Routes, Route } from "react-router-dom";
 import { useState } from "react";
 import "./index.css";
@@ -34,6 +35,7 @@ function App() {
         <Routes>
           <Route path="/" element={<DashboardHome />} />
           <Route path="/analytics" element={<Analytics />} />
+          <Route path="/jobs/:id" element={<JobDetail />} />
         </Routes>
       </DashboardLayout>
     </Router>
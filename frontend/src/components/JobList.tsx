@@ -1,6 +1,7 @@
 import { useEffect, useMemo, useState } from 'react';
 import { FixedSizeList as List } from 'react-window';
-import { fetchJobs, type Job } from '../services/api';
+import { artifactUrl, fetchJobs, type Job } from '../services/api';
+import { Link } from 'react-router-dom';
 import { useUi } from '../contexts/UiContext';
 
 const STATUS_OPTIONS = ['All', 'Pending', 'Running', 'Completed', 'Failed'];
@@ -243,6 +244,7 @@ const JobList = () => {
                       <span className="truncate max-w-[18rem]">{job.id}</span>
                       <button onClick={() => copyToClipboard(job.id)} className="text-xs text-gray-500 hover:text-gray-700">Copy</button>
                       <button onClick={() => setExpandedId(expandedId === job.id ? null : job.id)} className="text-xs text-gray-500 hover:text-gray-700">Details</button>
+                      <Link to={`/jobs/${job.id}`} className="text-xs text-gray-500 hover:text-gray-700">Open</Link>
                     </div>
                   </td>
                   <td className={`py-${compact? '1':'3'} px-4 text-gray-900`}>{job.type}</td>
@@ -250,6 +252,11 @@ const JobList = () => {
                     <span className={`px-2 text-sm font-medium ${job.status === 'Failed' ? 'text-red-700' : 'text-gray-800'}`}>
                       {job.status}
                     </span>
+                    {job.status === 'Running' && job.progress && (
+                      <span className="text-xs text-gray-500">
+                        {Math.round(job.progress.percent)}%{job.progress.stage ? ` · ${job.progress.stage}` : ''}
+                      </span>
+                    )}
                   </td>
                   <td className={`py-${compact? '1':'3'} px-4 text-gray-900`}>{job.priority}</td>
                   <td className={`py-${compact? '1':'3'} px-4 text-gray-900`}>{job.thread_demand}</td>
@@ -262,12 +269,24 @@ const JobList = () => {
                     <td colSpan={8} className="p-4 text-sm text-gray-700">
                       <div className="mb-2 font-medium">Payload</div>
                       <pre className="whitespace-pre-wrap bg-white border border-gray-200 p-2 text-xs overflow-auto">{JSON.stringify(job.payload, null, 2)}</pre>
+                      {job.result_ref && (
+                        <>
+                          <div className="mt-3 mb-2 font-medium">Result</div>
+                          <a className="text-blue-600 hover:underline text-xs" href={artifactUrl(job.result_ref)} target="_blank" rel="noreferrer">{job.result_ref}</a>
+                        </>
+                      )}
                       {job.result && (
                         <>
                           <div className="mt-3 mb-2 font-medium">Result</div>
                           <pre className="whitespace-pre-wrap bg-white border border-gray-200 p-2 text-xs overflow-auto">{JSON.stringify(job.result, null, 2)}</pre>
                         </>
                       )}
+                      {job.error && (
+                        <>
+                          <div className="mt-3 mb-2 font-medium text-red-700">Error</div>
+                          <div className="bg-red-50 border border-red-200 p-2 text-xs text-red-800">{job.error.code}: {job.error.message}</div>
+                        </>
+                      )}
                     </td>
                   </tr>
                 )}
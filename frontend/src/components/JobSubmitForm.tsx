@@ -20,7 +20,7 @@ export default function JobSubmitForm({ onSubmit }: { onSubmit?: (data: JobFormD
   const [payload, setPayload] = useState<any>({
     add_numbers: { x: 0, y: 0 },
     reverse_string: { text: '' },
-    resize_image: { url: '', width: 800, height: 600 },
+    resize_image: { url: '', width: 800, height: 600, filter: 'bilinear', keep_aspect: true, format: '' },
     large_array_sum: { array: [1, 2, 3, 4, 5] }
   });
 
@@ -136,6 +136,50 @@ export default function JobSubmitForm({ onSubmit }: { onSubmit?: (data: JobFormD
                 />
               </div>
             </div>
+            <div className="grid grid-cols-2 gap-4">
+              <div>
+                <label className="block text-sm font-medium text-gray-700">Filter</label>
+                <select
+                  className={inputClasses}
+                  value={payload.resize_image.filter}
+                  onChange={(e) => setPayload({
+                    ...payload,
+                    resize_image: { ...payload.resize_image, filter: e.target.value }
+                  })}
+                >
+                  <option value="nearest">Nearest</option>
+                  <option value="bilinear">Bilinear</option>
+                  <option value="lanczos">Lanczos</option>
+                </select>
+              </div>
+              <div>
+                <label className="block text-sm font-medium text-gray-700">Format</label>
+                <select
+                  className={inputClasses}
+                  value={payload.resize_image.format}
+                  onChange={(e) => setPayload({
+                    ...payload,
+                    resize_image: { ...payload.resize_image, format: e.target.value }
+                  })}
+                >
+                  <option value="">Same as source</option>
+                  <option value="png">PNG</option>
+                  <option value="jpeg">JPEG</option>
+                  <option value="gif">GIF</option>
+                </select>
+              </div>
+            </div>
+            <label className="flex items-center gap-2 text-sm text-gray-700">
+              <input
+                type="checkbox"
+                checked={payload.resize_image.keep_aspect}
+                onChange={(e) => setPayload({
+                  ...payload,
+                  resize_image: { ...payload.resize_image, keep_aspect: e.target.checked }
+                })}
+              />
+              Keep aspect ratio
+            </label>
           </>
         );
 
@@ -0,0 +1,87 @@
+import { useEffect, useState } from 'react';
+import { Link, useParams } from 'react-router-dom';
+import { fetchJob, type Job } from '../services/api';
+
+export default function JobDetail() {
+  const { id } = useParams<{ id: string }>();
+  const [job, setJob] = useState<Job | null>(null);
+  const [error, setError] = useState<string | null>(null);
+
+  useEffect(() => {
+    if (!id) return;
+    const loadJob = async () => {
+      try {
+        const data = await fetchJob(id);
+        setJob(data);
+        setError(null);
+      } catch (err) {
+        setError('Failed to fetch job. Please try again later.');
+      }
+    };
+
+    loadJob();
+    const interval = setInterval(loadJob, 5000);
+    return () => clearInterval(interval);
+  }, [id]);
+
+  if (error) {
+    return (
+      <div className="p-4">
+        <div className="bg-red-50 text-red-700 p-4">{error}</div>
+      </div>
+    );
+  }
+
+  if (!job) {
+    return <div className="p-4 text-gray-600">Loading...</div>;
+  }
+
+  return (
+    <div className="max-w-4xl mx-auto w-full p-4">
+      <div className="mb-4">
+        <Link to="/" className="text-sm text-gray-500 hover:text-gray-700">&larr; Back to jobs</Link>
+      </div>
+      <h2 className="text-xl font-semibold text-gray-800 mb-4">Job <span className="font-mono text-base">{job.id}</span></h2>
+
+      <div className="grid grid-cols-2 gap-4 bg-white shadow-sm border border-gray-200 p-4 text-sm">
+        <div><span className="text-gray-500">Type:</span> {job.type}</div>
+        <div>
+          <span className="text-gray-500">Status:</span>{' '}
+          <span className={job.status === 'Failed' ? 'text-red-700 font-medium' : 'text-gray-800'}>{job.status}</span>
+        </div>
+        <div><span className="text-gray-500">Priority:</span> {job.priority}</div>
+        <div>
+          <span className="text-gray-500">Threads:</span> {job.thread_demand}
+          {job.thread_outcome && (
+            <span className="ml-2 text-xs text-gray-500">
+              ({job.thread_outcome.policy}: requested {job.thread_outcome.requested_threads})
+            </span>
+          )}
+        </div>
+        <div><span className="text-gray-500">Created:</span> {new Date(job.created_at).toLocaleString()}</div>
+        <div><span className="text-gray-500">Started:</span> {job.started_at ? new Date(job.started_at).toLocaleString() : '-'}</div>
+        <div><span className="text-gray-500">Completed:</span> {job.completed_at ? new Date(job.completed_at).toLocaleString() : '-'}</div>
+      </div>
+
+      {job.error && (
+        <div className="mt-4 bg-red-50 border border-red-200 p-4 text-sm text-red-800">
+          <div className="font-medium mb-1">
+            {job.error.code}
+            {job.error.retryable && <span className="ml-2 text-xs text-red-600">(retryable)</span>}
+          </div>
+          <div>{job.error.message}</div>
+          {job.error.stack && (
+            <pre className="mt-3 whitespace-pre-wrap bg-white border border-red-200 p-2 text-xs overflow-auto">{job.error.stack}</pre>
+          )}
+        </div>
+      )}
+
+      {job.result && (
+        <>
+          <div className="mt-4 mb-2 font-medium text-gray-700">Result</div>
+          <pre className="whitespace-pre-wrap bg-white border border-gray-200 p-2 text-xs overflow-auto">{JSON.stringify(job.result, null, 2)}</pre>
+        </>
+      )}
+    </div>
+  );
+}
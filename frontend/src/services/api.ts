@@ -1,15 +1,46 @@
 type JobType = 'AddNumbers' | 'ReverseString' | 'ResizeImage' | 'LargeArraySum';
 type JobStatus = 'Pending' | 'Running' | 'Completed' | 'Failed';
 
+interface JobError {
+  code: string;
+  message: string;
+  retryable: boolean;
+  stack?: string;
+}
+
+interface ThreadOutcome {
+  policy: 'clamp' | 'downgrade';
+  requested_threads: number;
+  granted_threads: number;
+}
+
+interface JobProgress {
+  percent: number;
+  processed: number;
+  total: number;
+  stage?: string;
+  updated_at: string;
+}
+
 interface Job {
   id: string;
   name: string;
   type: JobType;
   status: JobStatus;
+  progress?: JobProgress;
   priority: number;
   thread_demand: number;
+  thread_outcome?: ThreadOutcome;
+  tenant?: string;
+  preemptible?: boolean;
+  gang?: boolean;
+  attempts?: number;
   payload: any;
+  payload_ref?: string;
   result?: any;
+  result_ref?: string;
+  trace_id?: string;
+  error?: JobError;
   created_at: string;
   started_at?: string;
   completed_at?: string;
@@ -22,12 +53,19 @@ interface JobStats {
 }
 
 const API_URL = import.meta.env.VITE_API_URL;
+// Sent as a bearer token when the API has authentication enabled
+const API_KEY = import.meta.env.VITE_API_KEY;
+
+function authHeaders(): Record<string, string> {
+  return API_KEY ? { 'Authorization': `Bearer ${API_KEY}` } : {};
+}
 
 async function fetchCurrentJobs(): Promise<Job[]> {
   const response = await fetch(`${API_URL}/jobs`, {
     headers: {
       'Accept': 'application/json',
-      'Content-Type': 'application/json'
+      'Content-Type': 'application/json',
+      ...authHeaders(),
     },
   });
   if (!response.ok) {
@@ -40,7 +78,8 @@ async function fetchHistoricalJobs(): Promise<Job[]> {
   const response = await fetch(`${API_URL}/db/jobs`, {
     headers: {
       'Accept': 'application/json',
-      'Content-Type': 'application/json'
+      'Content-Type': 'application/json',
+      ...authHeaders(),
     },
   });
   if (!response.ok) {
@@ -84,7 +123,7 @@ export async function fetchJobs(): Promise<Job[]> {
 }
 
 export async function fetchJob(id: string): Promise<Job> {
-  const response = await fetch(`${API_URL}/jobs/${id}`);
+  const response = await fetch(`${API_URL}/jobs/${id}`, { headers: authHeaders() });
   if (!response.ok) {
     throw new Error('Failed to fetch job');
   }
@@ -95,12 +134,17 @@ export async function submitJob(job: {
   type: string;
   priority: number;
   thread_demand: number;
+  thread_outcome?: ThreadOutcome;
+  tenant?: string;
+  preemptible?: boolean;
+  gang?: boolean;
   payload: any;
 }): Promise<Job> {
   const response = await fetch(`${API_URL}/jobs`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
+      ...authHeaders(),
     },
     body: JSON.stringify(job),
   });
@@ -119,4 +163,9 @@ export async function fetchJobStats(): Promise<JobStats> {
   };
 }
 
-export type { Job, JobStats };
+// Download link for an artifact such as an offloaded result
+export function artifactUrl(key: string): string {
+  return `${API_URL}/artifacts/${key}`;
+}
+
+export type { Job, JobError, JobStats };
@@ -0,0 +1,124 @@
+package artifact
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for keys that were never stored
+var ErrNotFound = errors.New("artifact not found")
+
+// Artifact is a stored blob. Key is what the store knows it by; URL is where
+// a client can read it from.
+type Artifact struct {
+	Key  string `json:"key"`
+	URL  string `json:"url"`
+	Size int64  `json:"size"`
+}
+
+// Store keeps job inputs and outputs that are too big or too binary to
+// travel inline
+type Store interface {
+	Put(key string, r io.Reader) (Artifact, error)
+	Get(key string) (io.ReadCloser, error)
+	Stat(key string) (Artifact, error)
+	// Delete removes key; deleting a missing key is not an error
+	Delete(key string) error
+}
+
+// LocalStore keeps artifacts as files under Dir
+type LocalStore struct {
+	Dir string
+}
+
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{Dir: dir}
+}
+
+func (s *LocalStore) Put(key string, r io.Reader) (Artifact, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Artifact{}, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return Artifact{}, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return Artifact{}, err
+	}
+	abs, _ := filepath.Abs(path)
+	return Artifact{Key: key, URL: "file://" + filepath.ToSlash(abs), Size: n}, nil
+}
+
+func (s *LocalStore) Get(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return f, err
+}
+
+func (s *LocalStore) Stat(key string) (Artifact, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return Artifact{}, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Artifact{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return Artifact{}, err
+	}
+	abs, _ := filepath.Abs(path)
+	return Artifact{Key: key, URL: "file://" + filepath.ToSlash(abs), Size: info.Size()}, nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps key into Dir, refusing keys that would escape it
+func (s *LocalStore) path(key string) (string, error) {
+	if err := ValidKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// ValidKey checks that key is a relative, slash-separated path without ".."
+func ValidKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid artifact key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid artifact key %q", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package artifact
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	s := NewLocalStore(t.TempDir())
+
+	a, err := s.Put("resized/img.png", strings.NewReader("pixels"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Size != 6 || !strings.HasPrefix(a.URL, "file://") {
+		t.Errorf("unexpected artifact %+v", a)
+	}
+
+	r, err := s.Get("resized/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, _ := io.ReadAll(r)
+	if string(b) != "pixels" {
+		t.Errorf("expected stored bytes back, got %q", b)
+	}
+
+	if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := s.Delete("resized/img.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Stat("resized/img.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the deleted file to be gone, got %v", err)
+	}
+	if err := s.Delete("missing"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	s := NewLocalStore(t.TempDir())
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b"} {
+		if _, err := s.Put(key, strings.NewReader("x")); err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
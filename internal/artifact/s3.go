@@ -0,0 +1,238 @@
+package artifact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config points an S3Store at a bucket. Any S3-compatible service works
+// (AWS, MinIO, R2...); objects are addressed path-style as
+// <Endpoint>/<Bucket>/<key>.
+type S3Config struct {
+	Endpoint        string
+	Bucket          string
+	Region          string // defaults to us-east-1
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Store keeps artifacts as objects in an S3 bucket, signing requests with
+// AWS Signature Version 4
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	Client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	u, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3Store{
+		cfg:      cfg,
+		endpoint: u,
+		Client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}, nil
+}
+
+// Put spools r to a temporary file first: SigV4 needs the payload hash and
+// S3 needs the length before the upload starts
+func (s *S3Store) Put(key string, r io.Reader) (Artifact, error) {
+	if err := ValidKey(key); err != nil {
+		return Artifact{}, err
+	}
+	tmp, err := os.CreateTemp("", "artifact-*")
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return Artifact{}, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return Artifact{}, err
+	}
+
+	req, err := s.request(http.MethodPut, key, io.NopCloser(tmp), hex.EncodeToString(h.Sum(nil)))
+	if err != nil {
+		return Artifact{}, err
+	}
+	req.ContentLength = n
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Artifact{}, s3Error(req, resp)
+	}
+	return Artifact{Key: key, URL: s.objectURL(key).String(), Size: n}, nil
+}
+
+func (s *S3Store) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Store) Stat(key string) (Artifact, error) {
+	resp, err := s.do(http.MethodHead, key)
+	if err != nil {
+		return Artifact{}, err
+	}
+	resp.Body.Close()
+	return Artifact{Key: key, URL: s.objectURL(key).String(), Size: resp.ContentLength}, nil
+}
+
+// Delete removes key. S3 answers 204 whether or not it existed.
+func (s *S3Store) Delete(key string) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	req, err := s.request(http.MethodDelete, key, nil, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(req, resp)
+	}
+	return nil
+}
+
+// do sends a bodyless request, mapping 404 to ErrNotFound. The caller owns
+// the body on success.
+func (s *S3Store) do(method, key string) (*http.Response, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	req, err := s.request(method, key, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error(req, resp)
+	}
+}
+
+func (s *S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.cfg.Bucket + "/" + key
+	return &u
+}
+
+func (s *S3Store) request(method, key string, body io.ReadCloser, payloadHash string) (*http.Request, error) {
+	req, err := http.NewRequest(method, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Body = body
+	}
+	s.sign(req, payloadHash, s.now())
+	return req, nil
+}
+
+func s3Error(req *http.Request, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3: %s %s: %s %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// sha256 of an empty body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds SigV4 headers to req, signing the host and every header already
+// set on it
+func (s *S3Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
@@ -0,0 +1,195 @@
+// Package auth authenticates API requests with API keys or JWT bearer tokens
+// and checks the scopes they carry
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes, each including the ones before it
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var scopeRank = map[string]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+// ValidScope reports whether s is one of the scopes above
+func ValidScope(s string) bool {
+	return scopeRank[s] > 0
+}
+
+var (
+	// ErrNoCredentials is returned for requests without an API key or token
+	ErrNoCredentials = errors.New("missing API key or bearer token")
+	// ErrInvalidCredentials is returned for unknown, revoked or expired keys
+	// and tokens that don't verify
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is who a request was authenticated as
+type Principal struct {
+	Subject string // key ID or the token's sub
+	Method  string // api_key, bootstrap_key or jwt
+	Scopes  []string
+}
+
+// Can reports whether p has scope or one that includes it
+func (p Principal) Can(scope string) bool {
+	for _, s := range p.Scopes {
+		if scopeRank[s] >= scopeRank[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+type Config struct {
+	// JWTSecret verifies HS256 bearer tokens, empty disables JWTs
+	JWTSecret string
+	// JWTIssuer and JWTAudience, if set, must match the iss and aud claims
+	JWTIssuer   string
+	JWTAudience string
+	// BootstrapKey is an admin key accepted besides the stored ones, for
+	// creating the first keys. Empty disables it.
+	BootstrapKey string
+}
+
+// cacheTTL is how long a verified key is trusted before it is read from the
+// store again; revoking through the Authenticator takes effect at once
+var cacheTTL = 30 * time.Second
+
+type cachedKey struct {
+	key Key
+	at  time.Time
+}
+
+// Authenticator checks request credentials against a key store and the JWT
+// settings
+type Authenticator struct {
+	cfg  Config
+	keys KeyStore
+	now  func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+func New(cfg Config, keys KeyStore) *Authenticator {
+	return &Authenticator{cfg: cfg, keys: keys, now: time.Now, cache: make(map[string]cachedKey)}
+}
+
+// Authenticate finds who r is from its Authorization: Bearer or X-API-Key
+// header. Bearer values that look like API keys are checked as keys, the
+// rest as JWTs.
+func (a *Authenticator) Authenticate(ctx context.Context, r *http.Request) (Principal, error) {
+	token := r.Header.Get("X-API-Key")
+	if token == "" {
+		scheme, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(value)
+		}
+	}
+	if token == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	if a.cfg.BootstrapKey != "" && equalHashed(token, a.cfg.BootstrapKey) {
+		return Principal{Subject: "bootstrap", Method: "bootstrap_key", Scopes: []string{ScopeAdmin}}, nil
+	}
+	if strings.HasPrefix(token, keyPrefix) {
+		return a.authenticateKey(ctx, token)
+	}
+	if a.cfg.JWTSecret == "" {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return verifyJWT(token, a.cfg, a.now())
+}
+
+func (a *Authenticator) authenticateKey(ctx context.Context, token string) (Principal, error) {
+	id, secret, ok := parseKey(token)
+	if !ok {
+		return Principal{}, ErrInvalidCredentials
+	}
+	now := a.now()
+	a.mu.Lock()
+	cached, hit := a.cache[id]
+	a.mu.Unlock()
+	key := cached.key
+	if !hit || now.Sub(cached.at) > cacheTTL {
+		var err error
+		key, err = a.keys.Get(ctx, id)
+		if errors.Is(err, ErrKeyNotFound) {
+			return Principal{}, ErrInvalidCredentials
+		}
+		if err != nil {
+			return Principal{}, fmt.Errorf("looking up API key: %w", err)
+		}
+		// last_used_at is only as fresh as the cache
+		if err := a.keys.Touch(ctx, id, now); err != nil {
+			slog.Default().Warn("recording API key use failed", "key_id", id, "error", err)
+		}
+		a.mu.Lock()
+		a.cache[id] = cachedKey{key: key, at: now}
+		a.mu.Unlock()
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 || !key.Usable(now) {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Subject: key.ID, Method: "api_key", Scopes: key.Scopes}, nil
+}
+
+// CreateKey stores a new key and returns it with the token to hand out,
+// which can't be recovered later. ttl 0 means it never expires.
+func (a *Authenticator) CreateKey(ctx context.Context, name string, scopes []string, ttl time.Duration) (Key, string, error) {
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return Key{}, "", fmt.Errorf("unknown scope %q (want read, write or admin)", s)
+		}
+	}
+	if len(scopes) == 0 {
+		return Key{}, "", errors.New("at least one scope is required")
+	}
+	key, token, err := NewKey(name, slices.Compact(slices.Sorted(slices.Values(scopes))), ttl, a.now())
+	if err != nil {
+		return Key{}, "", err
+	}
+	if err := a.keys.Create(ctx, key); err != nil {
+		return Key{}, "", err
+	}
+	return key, token, nil
+}
+
+// Keys lists the stored keys, revoked ones included
+func (a *Authenticator) Keys(ctx context.Context) ([]Key, error) {
+	return a.keys.List(ctx)
+}
+
+// RevokeKey stops key id from authenticating, here at once
+func (a *Authenticator) RevokeKey(ctx context.Context, id string) error {
+	if err := a.keys.Revoke(ctx, id, a.now()); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	delete(a.cache, id)
+	a.mu.Unlock()
+	return nil
+}
+
+// equalHashed compares a and b in constant time whatever their lengths
+func equalHashed(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
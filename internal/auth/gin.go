@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "auth.principal"
+
+// Gin authenticates each request and checks it has the scope scopeFor
+// returns. Requests scopeFor gives "" are let through unauthenticated, as
+// are CORS preflights. Failures are 401 with the reason, or 403 for a
+// missing scope.
+func Gin(a *Authenticator, scopeFor func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := scopeFor(c)
+		if scope == "" || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		p, err := a.Authenticate(c.Request.Context(), c.Request)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, ErrNoCredentials) && !errors.Is(err, ErrInvalidCredentials) {
+				status = http.StatusInternalServerError // the key store failed
+			} else {
+				c.Header("WWW-Authenticate", `Bearer realm="job-scheduler"`)
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(principalKey, p)
+		if !p.Can(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "requires the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// FromContext returns who Gin authenticated the request as
+func FromContext(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
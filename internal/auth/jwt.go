@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jwtLeeway allows for clock skew between the issuer and this process
+const jwtLeeway = 30 * time.Second
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string      `json:"sub"`
+	Issuer    string      `json:"iss"`
+	Audience  jwtAudience `json:"aud"`
+	ExpiresAt *int64      `json:"exp"`
+	NotBefore *int64      `json:"nbf"`
+	// Scope is space-separated as in OAuth 2.0, Scopes a list
+	Scope  string   `json:"scope"`
+	Scopes []string `json:"scopes"`
+}
+
+// jwtAudience is the aud claim, a string or a list of them
+type jwtAudience []string
+
+func (a *jwtAudience) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*a = jwtAudience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return fmt.Errorf("aud is not a string or a list of strings")
+	}
+	*a = many
+	return nil
+}
+
+// verifyJWT checks an HS256 token signed with cfg.JWTSecret. Tokens must
+// carry sub and exp; unknown scopes are ignored.
+func verifyJWT(token string, cfg Config, now time.Time) (Principal, error) {
+	invalid := func(why string) (Principal, error) {
+		return Principal{}, fmt.Errorf("%w: %s", ErrInvalidCredentials, why)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return invalid("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return invalid("malformed header")
+	}
+	// Only HS256, so "none" and algorithm confusion are rejected
+	if header.Alg != "HS256" {
+		return invalid(fmt.Sprintf("unsupported alg %q", header.Alg))
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return invalid("malformed signature")
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return invalid("bad signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return invalid("malformed claims")
+	}
+	switch {
+	case claims.Subject == "":
+		return invalid("no sub")
+	case claims.ExpiresAt == nil:
+		return invalid("no exp")
+	case now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtLeeway)):
+		return invalid("expired")
+	case claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.NotBefore, 0)):
+		return invalid("not valid yet")
+	case cfg.JWTIssuer != "" && claims.Issuer != cfg.JWTIssuer:
+		return invalid("wrong issuer")
+	case cfg.JWTAudience != "" && !slices.Contains(claims.Audience, cfg.JWTAudience):
+		return invalid("wrong audience")
+	}
+
+	var scopes []string
+	for _, s := range append(strings.Fields(claims.Scope), claims.Scopes...) {
+		if ValidScope(s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return Principal{Subject: claims.Subject, Method: "jwt", Scopes: scopes}, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func sign(t *testing.T, alg string, claims map[string]interface{}, secret string) string {
+	t.Helper()
+	enc := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	unsigned := enc(map[string]string{"alg": alg, "typ": "JWT"}) + "." + enc(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWT(t *testing.T) {
+	now := time.Now()
+	cfg := Config{JWTSecret: testSecret, JWTIssuer: "https://issuer.example", JWTAudience: "job-scheduler"}
+	a := New(cfg, NewMemoryKeyStore())
+	claims := func(change map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":   "alice",
+			"iss":   "https://issuer.example",
+			"aud":   []string{"other", "job-scheduler"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"scope": "read write profile",
+		}
+		for k, v := range change {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	p, err := a.Authenticate(context.Background(), request("Authorization", "Bearer "+sign(t, "HS256", claims(nil), testSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "alice" || p.Method != "jwt" || len(p.Scopes) != 2 || !p.Can(ScopeWrite) || p.Can(ScopeAdmin) {
+		t.Errorf("unexpected principal %+v", p)
+	}
+
+	for name, token := range map[string]string{
+		"wrong secret":  sign(t, "HS256", claims(nil), "another-secret-another-secret-00"),
+		"alg none":      sign(t, "none", claims(nil), testSecret),
+		"expired":       sign(t, "HS256", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()}), testSecret),
+		"no exp":        sign(t, "HS256", claims(map[string]interface{}{"exp": nil}), testSecret),
+		"not yet":       sign(t, "HS256", claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()}), testSecret),
+		"wrong issuer":  sign(t, "HS256", claims(map[string]interface{}{"iss": "someone"}), testSecret),
+		"wrong aud":     sign(t, "HS256", claims(map[string]interface{}{"aud": "other"}), testSecret),
+		"no sub":        sign(t, "HS256", claims(map[string]interface{}{"sub": nil}), testSecret),
+		"not a JWT":     "abc.def",
+		"bad signature": sign(t, "HS256", claims(nil), testSecret) + "x",
+	} {
+		if _, err := a.Authenticate(context.Background(), request("Authorization", "Bearer "+token)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+
+	noJWT := New(Config{}, NewMemoryKeyStore())
+	if _, err := noJWT.Authenticate(context.Background(), request("Authorization", "Bearer "+sign(t, "HS256", claims(nil), ""))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected JWTs refused without a secret, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyPrefix starts every API key, which reads djs_<id>_<secret>
+const keyPrefix = "djs_"
+
+// ErrKeyNotFound is returned for key IDs the store doesn't have
+var ErrKeyNotFound = errors.New("API key not found")
+
+// Key is a stored API key. Only a SHA-256 hash of its secret is kept.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Usable reports whether k is neither revoked nor expired at now
+func (k Key) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// NewKey generates a key and the token that authenticates as it
+func NewKey(name string, scopes []string, ttl time.Duration, now time.Time) (Key, string, error) {
+	id, err := randomHex(6)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return Key{}, "", err
+	}
+	k := Key{ID: id, Name: name, Hash: hashSecret(secret), Scopes: scopes, CreatedAt: now}
+	if ttl > 0 {
+		exp := now.Add(ttl)
+		k.ExpiresAt = &exp
+	}
+	return k, keyPrefix + id + "_" + secret, nil
+}
+
+func parseKey(token string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(token, keyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(rest, "_")
+	return id, secret, ok && id != "" && secret != ""
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// KeyStore keeps API keys
+type KeyStore interface {
+	Create(ctx context.Context, k Key) error
+	Get(ctx context.Context, id string) (Key, error)
+	List(ctx context.Context) ([]Key, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// Touch records that key id was used at
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+// MemoryKeyStore keeps keys in memory, for tests and single runs
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]Key
+}
+
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: make(map[string]Key)}
+}
+
+func (s *MemoryKeyStore) Create(_ context.Context, k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+	return nil
+}
+
+func (s *MemoryKeyStore) Get(_ context.Context, id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return k, nil
+}
+
+func (s *MemoryKeyStore) List(_ context.Context) ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b Key) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return keys, nil
+}
+
+func (s *MemoryKeyStore) Revoke(_ context.Context, id string, at time.Time) error {
+	return s.update(id, func(k *Key) {
+		if k.RevokedAt == nil {
+			k.RevokedAt = &at
+		}
+	})
+}
+
+func (s *MemoryKeyStore) Touch(_ context.Context, id string, at time.Time) error {
+	return s.update(id, func(k *Key) { k.LastUsedAt = &at })
+}
+
+func (s *MemoryKeyStore) update(id string, fn func(*Key)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	fn(&k)
+	s.keys[id] = k
+	return nil
+}
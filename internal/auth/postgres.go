@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresKeyStore keeps keys in the api_keys table
+type PostgresKeyStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresKeyStore(db *pgxpool.Pool) *PostgresKeyStore {
+	return &PostgresKeyStore{db: db}
+}
+
+const keyColumns = "id, name, hash, scopes, created_at, expires_at, last_used_at, revoked_at"
+
+func (s *PostgresKeyStore) Create(ctx context.Context, k Key) error {
+	_, err := s.db.Exec(ctx, "INSERT INTO api_keys ("+keyColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		k.ID, k.Name, k.Hash, k.Scopes, k.CreatedAt, k.ExpiresAt, k.LastUsedAt, k.RevokedAt)
+	return err
+}
+
+func (s *PostgresKeyStore) Get(ctx context.Context, id string) (Key, error) {
+	k, err := scanKey(s.db.QueryRow(ctx, "SELECT "+keyColumns+" FROM api_keys WHERE id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Key{}, ErrKeyNotFound
+	}
+	return k, err
+}
+
+func (s *PostgresKeyStore) List(ctx context.Context) ([]Key, error) {
+	rows, err := s.db.Query(ctx, "SELECT "+keyColumns+" FROM api_keys ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []Key
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresKeyStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	// Revoking twice keeps the first time
+	return s.exec(ctx, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1", id, at)
+}
+
+func (s *PostgresKeyStore) Touch(ctx context.Context, id string, at time.Time) error {
+	return s.exec(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1", id, at)
+}
+
+func (s *PostgresKeyStore) exec(ctx context.Context, sql string, id string, at time.Time) error {
+	tag, err := s.db.Exec(ctx, sql, id, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func scanKey(row pgx.Row) (Key, error) {
+	var k Key
+	err := row.Scan(&k.ID, &k.Name, &k.Hash, &k.Scopes, &k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt)
+	return k, err
+}
@@ -0,0 +1,275 @@
+// Package autoscale grows and shrinks the scheduler's in-process worker pool
+// with queue depth, queue wait and CPU use, within bounds and cooldowns
+package autoscale
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/metrics"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/scheduler"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+type Config struct {
+	// MinWorkers and MaxWorkers bound the whole pool, configured workers
+	// included. Only workers the autoscaler added are ever removed.
+	MinWorkers int
+	MaxWorkers int
+	// Interval is how often the pool is checked
+	Interval time.Duration
+	// UpCooldown and DownCooldown are how long after any scaling before the
+	// pool is grown or shrunk again
+	UpCooldown   time.Duration
+	DownCooldown time.Duration
+	// QueuePerThread scales up once more jobs than this per active thread
+	// are queued, 0 disables
+	QueuePerThread float64
+	// TargetWait scales up once the oldest queued job has waited longer,
+	// 0 disables
+	TargetWait time.Duration
+	// MaxCPU holds back scaling up while the process uses this fraction of
+	// its CPUs or more, 0 disables
+	MaxCPU float64
+	// IdleUtilization scales down when nothing is queued and fewer than this
+	// fraction of active threads are busy
+	IdleUtilization float64
+	// DrainTimeout bounds how long a worker being removed gets to finish
+	// its jobs before the removal is given up
+	DrainTimeout time.Duration
+	// WorkerThreads is the size of each added worker, used to work out how
+	// many to add for a deep queue
+	WorkerThreads int
+}
+
+type Action string
+
+const (
+	Up   Action = "up"
+	Down Action = "down"
+	Hold Action = "hold"
+)
+
+// Sample is what a scaling decision is made on
+type Sample struct {
+	Workers    int // in the pool
+	Autoscaled int // of those, added by the autoscaler
+	Threads    int // on active workers
+	Busy       int // of those, held by placed jobs
+	Queued     int
+	OldestWait time.Duration
+	CPU        float64 // fraction of GOMAXPROCS, below 0 if unknown
+}
+
+// Decision is how many workers to add or remove and why. A Hold with a
+// reason means scaling was wanted but held back.
+type Decision struct {
+	Action  Action
+	Workers int
+	Reason  string
+}
+
+// Decide works out what to do about s. lastScale is when the pool last grew
+// or shrank.
+func Decide(cfg Config, s Sample, now, lastScale time.Time) Decision {
+	if s.Workers < cfg.MinWorkers {
+		return Decision{Up, cfg.MinWorkers - s.Workers, "below_min"}
+	}
+	if s.Workers > cfg.MaxWorkers && s.Autoscaled > 0 {
+		return Decision{Down, min(s.Workers-cfg.MaxWorkers, s.Autoscaled), "above_max"}
+	}
+
+	pressure := ""
+	switch {
+	case cfg.QueuePerThread > 0 && float64(s.Queued) > cfg.QueuePerThread*float64(s.Threads):
+		pressure = "queue_depth"
+	case cfg.TargetWait > 0 && s.OldestWait > cfg.TargetWait:
+		pressure = "wait_time"
+	}
+	if pressure != "" {
+		switch {
+		case s.Workers >= cfg.MaxWorkers:
+			return Decision{Hold, 0, "at_max"}
+		case cfg.MaxCPU > 0 && s.CPU >= cfg.MaxCPU:
+			return Decision{Hold, 0, "cpu_saturated"}
+		case now.Sub(lastScale) < cfg.UpCooldown:
+			return Decision{Hold, 0, "cooldown"}
+		}
+		n := 1
+		if pressure == "queue_depth" && cfg.WorkerThreads > 0 {
+			// Enough workers to bring the queue back under target
+			short := math.Ceil(float64(s.Queued)/cfg.QueuePerThread) - float64(s.Threads)
+			n = max(1, int(math.Ceil(short/float64(cfg.WorkerThreads))))
+		}
+		return Decision{Up, min(n, cfg.MaxWorkers-s.Workers), pressure}
+	}
+
+	idle := s.Threads == 0 || float64(s.Busy) < cfg.IdleUtilization*float64(s.Threads)
+	if s.Queued == 0 && idle && s.Autoscaled > 0 && s.Workers > cfg.MinWorkers {
+		if now.Sub(lastScale) < cfg.DownCooldown {
+			return Decision{Hold, 0, "cooldown"}
+		}
+		return Decision{Down, 1, "idle"}
+	}
+	return Decision{Hold, 0, ""}
+}
+
+// Autoscaler applies Decide to a scheduler's pool
+type Autoscaler struct {
+	cfg       Config
+	sched     *scheduler.Scheduler
+	newWorker func(id string) *worker.Worker
+	log       *slog.Logger
+	cpu       cpuMeter
+
+	prefix    string
+	seq       int
+	added     []*worker.Worker
+	lastScale time.Time
+}
+
+// New returns an autoscaler for s. newWorker builds an unstarted worker with
+// the given ID; IDs are prefix-1, prefix-2 and so on.
+func New(cfg Config, s *scheduler.Scheduler, prefix string, newWorker func(id string) *worker.Worker, log *slog.Logger) *Autoscaler {
+	if log == nil {
+		log = slog.Default()
+	}
+	return &Autoscaler{cfg: cfg, sched: s, prefix: prefix, newWorker: newWorker, log: log}
+}
+
+// Run checks the pool every Interval until ctx is done
+func (a *Autoscaler) Run(ctx context.Context) {
+	t := time.NewTicker(a.cfg.Interval)
+	defer t.Stop()
+	for {
+		a.Step(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Step checks the pool once and scales it if needed
+func (a *Autoscaler) Step(ctx context.Context, now time.Time) Decision {
+	s := a.sample(now)
+	metrics.AutoscaleObserved(s.Autoscaled, s.CPU, s.OldestWait)
+	d := Decide(a.cfg, s, now, a.lastScale)
+	attrs := []interface{}{
+		"reason", d.Reason, "workers", s.Workers, "autoscaled", s.Autoscaled,
+		"threads", s.Threads, "busy", s.Busy, "queued", s.Queued,
+		"oldest_wait", s.OldestWait.String(), "cpu", s.CPU,
+	}
+
+	switch d.Action {
+	case Hold:
+		if d.Reason != "" {
+			metrics.AutoscaleDecision(string(d.Action), d.Reason)
+			a.log.Debug("autoscaler holding", attrs...)
+		}
+		return d
+	case Up:
+		d.Workers = a.scaleUp(d.Workers)
+	case Down:
+		d.Workers = a.scaleDown(ctx, d.Workers)
+	}
+	if d.Workers == 0 {
+		return d
+	}
+	a.lastScale = now
+	metrics.AutoscaleDecision(string(d.Action), d.Reason)
+	metrics.AutoscaleObserved(len(a.added), s.CPU, s.OldestWait)
+	a.log.Info("autoscaled workers", append(attrs, "action", string(d.Action), "changed", d.Workers)...)
+	return d
+}
+
+// sample reads the pool and queue, forgetting added workers that were
+// removed some other way
+func (a *Autoscaler) sample(now time.Time) Sample {
+	workers := a.sched.Workers()
+	s := Sample{Workers: len(workers), CPU: a.cpu.sample(now)}
+	inPool := make(map[*worker.Worker]bool, len(workers))
+	for _, w := range workers {
+		inPool[w] = true
+		if w.State() != worker.Active {
+			continue
+		}
+		s.Threads += w.Capacity().Threads
+		s.Busy += busy(w)
+	}
+	kept := a.added[:0]
+	for _, w := range a.added {
+		if inPool[w] {
+			kept = append(kept, w)
+		}
+	}
+	a.added = kept
+	s.Autoscaled = len(a.added)
+
+	var oldest time.Time
+	s.Queued, oldest = a.sched.Backlog()
+	if !oldest.IsZero() {
+		s.OldestWait = now.Sub(oldest)
+	}
+	return s
+}
+
+// scaleUp adds up to n workers and returns how many it added
+func (a *Autoscaler) scaleUp(n int) int {
+	for i := 0; i < n; i++ {
+		a.seq++
+		id := fmt.Sprintf("%s-%d", a.prefix, a.seq)
+		w := a.newWorker(id)
+		w.Start()
+		if err := a.sched.AddWorker(w); err != nil {
+			w.Stop()
+			a.log.Error("autoscaler could not add worker", "worker", id, "error", err)
+			return i
+		}
+		a.added = append(a.added, w)
+	}
+	return n
+}
+
+// scaleDown drains and removes up to n of the workers it added, idlest
+// first, and returns how many it removed. A worker that doesn't drain in
+// time is put back to work.
+func (a *Autoscaler) scaleDown(ctx context.Context, n int) int {
+	for i := 0; i < n; i++ {
+		if len(a.added) == 0 {
+			return i
+		}
+		idx := 0
+		for j, w := range a.added {
+			if busy(w) < busy(a.added[idx]) {
+				idx = j
+			}
+		}
+		w := a.added[idx]
+
+		drainCtx, cancel := context.WithTimeout(ctx, a.cfg.DrainTimeout)
+		err := a.sched.DrainWorker(drainCtx, w.ID)
+		cancel()
+		if err == nil {
+			err = a.sched.RemoveWorker(w.ID)
+		}
+		if err != nil {
+			if rerr := a.sched.ResumeWorker(w.ID); rerr != nil {
+				err = fmt.Errorf("%w; resuming: %v", err, rerr)
+			}
+			a.log.Warn("autoscaler could not remove worker", "worker", w.ID, "error", err)
+			return i
+		}
+		a.added = append(a.added[:idx], a.added[idx+1:]...)
+	}
+	return n
+}
+
+// busy is how many of w's threads placed jobs hold
+func busy(w *worker.Worker) int {
+	return w.Capacity().Threads - w.AvailableThreads()
+}
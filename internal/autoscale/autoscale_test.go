@@ -0,0 +1,139 @@
+package autoscale
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/scheduler"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+var testConfig = Config{
+	MinWorkers:      1,
+	MaxWorkers:      4,
+	Interval:        time.Second,
+	UpCooldown:      time.Minute,
+	DownCooldown:    5 * time.Minute,
+	QueuePerThread:  2,
+	TargetWait:      10 * time.Second,
+	MaxCPU:          0.9,
+	IdleUtilization: 0.25,
+	DrainTimeout:    time.Second,
+	WorkerThreads:   4,
+}
+
+func TestDecide(t *testing.T) {
+	now := time.Now()
+	long := now.Add(-time.Hour)
+	tests := []struct {
+		name      string
+		s         Sample
+		lastScale time.Time
+		want      Decision
+	}{
+		{"below min", Sample{Workers: 0}, now, Decision{Up, 1, "below_min"}},
+		{"above max", Sample{Workers: 6, Autoscaled: 3}, now, Decision{Down, 2, "above_max"}},
+		{"queue under target", Sample{Workers: 1, Threads: 4, Busy: 4, Queued: 8}, long, Decision{Hold, 0, ""}},
+		// 20 queued needs 10 threads, 6 more than there are: 2 workers of 4
+		{"deep queue", Sample{Workers: 1, Threads: 4, Busy: 4, Queued: 20}, long, Decision{Up, 2, "queue_depth"}},
+		{"deep queue capped at max", Sample{Workers: 3, Threads: 4, Queued: 100}, long, Decision{Up, 1, "queue_depth"}},
+		{"long wait", Sample{Workers: 1, Threads: 4, Queued: 1, OldestWait: time.Minute}, long, Decision{Up, 1, "wait_time"}},
+		{"at max", Sample{Workers: 4, Threads: 4, Queued: 100}, long, Decision{Hold, 0, "at_max"}},
+		{"cpu saturated", Sample{Workers: 1, Threads: 4, Queued: 100, CPU: 0.95}, long, Decision{Hold, 0, "cpu_saturated"}},
+		{"cpu unknown", Sample{Workers: 1, Threads: 4, Queued: 9, CPU: -1}, long, Decision{Up, 1, "queue_depth"}},
+		{"up cooldown", Sample{Workers: 1, Threads: 4, Queued: 100}, now.Add(-30 * time.Second), Decision{Hold, 0, "cooldown"}},
+		{"idle", Sample{Workers: 2, Autoscaled: 1, Threads: 8}, long, Decision{Down, 1, "idle"}},
+		{"down cooldown", Sample{Workers: 2, Autoscaled: 1, Threads: 8}, now.Add(-2 * time.Minute), Decision{Hold, 0, "cooldown"}},
+		{"busy", Sample{Workers: 2, Autoscaled: 1, Threads: 8, Busy: 2}, long, Decision{Hold, 0, ""}},
+		{"only configured workers", Sample{Workers: 2, Threads: 8}, long, Decision{Hold, 0, ""}},
+		{"at min", Sample{Workers: 1, Autoscaled: 1, Threads: 4}, long, Decision{Hold, 0, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Decide(testConfig, tt.s, now, tt.lastScale); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStepScalesUpForTheQueue(t *testing.T) {
+	w := worker.NewWorker("w1", 1) // the scheduler isn't run, jobs stay queued
+	s := scheduler.NewScheduler([]*worker.Worker{w})
+	for i := 0; i < 10; i++ {
+		if err := s.Submit(job.NewJob("q", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := testConfig
+	cfg.MaxWorkers, cfg.WorkerThreads = 3, 2
+	a := New(cfg, s, "auto", func(id string) *worker.Worker { return worker.NewWorker(id, 2) }, nil)
+	defer func() {
+		for _, w := range a.added {
+			w.Stop()
+		}
+	}()
+
+	now := time.Now()
+	if d := a.Step(context.Background(), now); d != (Decision{Up, 2, "queue_depth"}) {
+		t.Fatalf("expected to add 2 workers, got %+v", d)
+	}
+	ws := s.Workers()
+	if len(ws) != 3 || ws[1].ID != "auto-1" || ws[2].ID != "auto-2" {
+		t.Fatalf("expected auto-1 and auto-2 added, got %v", ws)
+	}
+	if d := a.Step(context.Background(), now.Add(time.Second)); d.Action != Hold {
+		t.Errorf("expected to hold at max workers, got %+v", d)
+	}
+}
+
+func TestStepScalesDownIdleWorkers(t *testing.T) {
+	s := scheduler.NewScheduler([]*worker.Worker{worker.NewWorker("w1", 1)})
+	cfg := testConfig
+	cfg.MinWorkers = 2
+	a := New(cfg, s, "auto", func(id string) *worker.Worker { return worker.NewWorker(id, 2) }, nil)
+
+	now := time.Now()
+	if d := a.Step(context.Background(), now); d != (Decision{Up, 1, "below_min"}) {
+		t.Fatalf("expected to fill up to the minimum, got %+v", d)
+	}
+	added := s.Worker("auto-1")
+	if added == nil {
+		t.Fatal("expected auto-1 in the pool")
+	}
+
+	a.cfg.MinWorkers = 1
+	if d := a.Step(context.Background(), now.Add(time.Minute)); d != (Decision{Hold, 0, "cooldown"}) {
+		t.Fatalf("expected the down cooldown to hold, got %+v", d)
+	}
+	if d := a.Step(context.Background(), now.Add(10*time.Minute)); d != (Decision{Down, 1, "idle"}) {
+		t.Fatalf("expected to remove the idle worker, got %+v", d)
+	}
+	if s.Worker("auto-1") != nil || len(a.added) != 0 {
+		t.Error("expected auto-1 gone")
+	}
+	if d := a.Step(context.Background(), now.Add(time.Hour)); d.Action != Hold {
+		t.Errorf("expected the configured worker to stay, got %+v", d)
+	}
+}
+
+func TestStepForgetsWorkersRemovedElsewhere(t *testing.T) {
+	s := scheduler.NewScheduler([]*worker.Worker{worker.NewWorker("w1", 1)})
+	cfg := testConfig
+	cfg.MinWorkers = 2
+	a := New(cfg, s, "auto", func(id string) *worker.Worker { return worker.NewWorker(id, 2) }, nil)
+	a.Step(context.Background(), time.Now())
+
+	if err := s.RemoveWorker("auto-1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := a.Step(context.Background(), time.Now()); d != (Decision{Up, 1, "below_min"}) || s.Worker("auto-2") == nil {
+		t.Fatalf("expected auto-2 to replace the removed worker, got %+v", d)
+	}
+	if len(a.added) != 1 {
+		t.Errorf("expected only auto-2 tracked, got %d", len(a.added))
+	}
+	s.RemoveWorker("auto-2")
+}
@@ -0,0 +1,28 @@
+package autoscale
+
+import (
+	"runtime"
+	"time"
+)
+
+// cpuMeter measures the process's CPU use between samples
+type cpuMeter struct {
+	used time.Duration
+	at   time.Time
+}
+
+// sample returns the fraction of GOMAXPROCS used since the last sample, or
+// -1 on the first sample and where process CPU time isn't available
+func (m *cpuMeter) sample(now time.Time) float64 {
+	used, ok := processCPU()
+	if !ok {
+		return -1
+	}
+	prevUsed, prevAt := m.used, m.at
+	m.used, m.at = used, now
+	wall := now.Sub(prevAt)
+	if prevAt.IsZero() || wall <= 0 {
+		return -1
+	}
+	return float64(used-prevUsed) / (float64(wall) * float64(runtime.GOMAXPROCS(0)))
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package autoscale
+
+import "time"
+
+// processCPU isn't measured here, so MaxCPU never holds back scaling
+func processCPU() (time.Duration, bool) {
+	return 0, false
+}
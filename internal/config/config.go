@@ -0,0 +1,455 @@
+// Package config loads the API's settings: defaults, then a YAML or TOML
+// file, then environment variables, validated as a whole
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/scheduler"
+)
+
+type Config struct {
+	API       API       `yaml:"api" toml:"api"`
+	Postgres  Postgres  `yaml:"postgres" toml:"postgres"`
+	Redis     Redis     `yaml:"redis" toml:"redis"`
+	Logging   Logging   `yaml:"logging" toml:"logging"`
+	Workers   []Worker  `yaml:"workers" toml:"workers"`
+	Scheduler Scheduler `yaml:"scheduler" toml:"scheduler"`
+	Artifacts Artifacts `yaml:"artifacts" toml:"artifacts"`
+	Health    Health    `yaml:"health" toml:"health"`
+	Autoscale Autoscale `yaml:"autoscale" toml:"autoscale"`
+	Auth      Auth      `yaml:"auth" toml:"auth"`
+}
+
+type API struct {
+	Port string `yaml:"port" toml:"port"`
+	// ShutdownTimeout is how long running jobs get to finish on SIGTERM
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// StartupRetryTimeout bounds how long Postgres and Redis are retried at
+	// startup, 0 means forever
+	StartupRetryTimeout   Duration `yaml:"startup_retry_timeout" toml:"startup_retry_timeout"`
+	ProgressCacheInterval Duration `yaml:"progress_cache_interval" toml:"progress_cache_interval"`
+	// CORSOrigins are the browser origins allowed to call the API, "*"
+	// allows any
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
+}
+
+type Postgres struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	DB       string `yaml:"db" toml:"db"`
+	SSLMode  string `yaml:"ssl_mode" toml:"ssl_mode"`
+}
+
+// URL is the connection string for pgx
+func (p Postgres) URL() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", p.User, p.Password, p.Host, p.Port, p.DB, p.SSLMode)
+}
+
+type Redis struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+func (r Redis) Addr() string {
+	return r.Host + ":" + r.Port
+}
+
+type Logging struct {
+	Level  string `yaml:"level" toml:"level"`   // debug, info, warn or error
+	Format string `yaml:"format" toml:"format"` // json or text
+	// JobLevel and JobMaxLines control what GET /jobs/:id/logs keeps
+	JobLevel    string `yaml:"job_level" toml:"job_level"`
+	JobMaxLines int    `yaml:"job_max_lines" toml:"job_max_lines"`
+}
+
+// Worker is one worker in the pool
+type Worker struct {
+	ID        string            `yaml:"id" toml:"id"`
+	Threads   int               `yaml:"threads" toml:"threads"`
+	QueueSize int               `yaml:"queue_size" toml:"queue_size"`
+	MemoryMB  int               `yaml:"memory_mb" toml:"memory_mb"` // 0 means memory isn't tracked
+	Resources map[string]int    `yaml:"resources" toml:"resources"`
+	Labels    map[string]string `yaml:"labels" toml:"labels"`
+}
+
+type Scheduler struct {
+	Policy         string `yaml:"policy" toml:"policy"`
+	OversizePolicy string `yaml:"oversize_policy" toml:"oversize_policy"`
+	Backfill       bool   `yaml:"backfill" toml:"backfill"`
+	WorkStealing   bool   `yaml:"work_stealing" toml:"work_stealing"`
+	Preemption     bool   `yaml:"preemption" toml:"preemption"`
+	// PreemptionGracePeriod is how long a job runs before it can be preempted
+	PreemptionGracePeriod Duration          `yaml:"preemption_grace_period" toml:"preemption_grace_period"`
+	Tenants               map[string]Tenant `yaml:"tenants" toml:"tenants"`
+	// DefaultTenant applies to tenants not listed in Tenants
+	DefaultTenant Tenant `yaml:"default_tenant" toml:"default_tenant"`
+}
+
+// Tenant is a tenant's fair-share weight and quotas, 0 meaning unlimited
+type Tenant struct {
+	Weight     float64 `yaml:"weight" toml:"weight" json:"weight"`
+	MaxThreads int     `yaml:"max_threads" toml:"max_threads" json:"max_threads"`
+	MaxQueued  int     `yaml:"max_queued" toml:"max_queued" json:"max_queued"`
+}
+
+func (t Tenant) TenantConfig() scheduler.TenantConfig {
+	return scheduler.TenantConfig{Weight: t.Weight, MaxThreads: t.MaxThreads, MaxQueued: t.MaxQueued}
+}
+
+type Artifacts struct {
+	Store string `yaml:"store" toml:"store"` // local or s3
+	Dir   string `yaml:"dir" toml:"dir"`     // for local, empty means a temp dir
+	S3    S3     `yaml:"s3" toml:"s3"`
+	// OffloadBytes is the JSON size above which payloads and results move
+	// to the artifact store, 0 disables
+	OffloadBytes int `yaml:"offload_bytes" toml:"offload_bytes"`
+	// MaxUploadBytes caps the body of POST /artifacts
+	MaxUploadBytes int `yaml:"max_upload_bytes" toml:"max_upload_bytes"`
+}
+
+type S3 struct {
+	Endpoint        string `yaml:"endpoint" toml:"endpoint"`
+	Bucket          string `yaml:"bucket" toml:"bucket"`
+	Region          string `yaml:"region" toml:"region"`
+	AccessKeyID     string `yaml:"access_key_id" toml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" toml:"secret_access_key"`
+}
+
+type Health struct {
+	CheckTimeout Duration `yaml:"check_timeout" toml:"check_timeout"`
+	// MaxDispatchWait is how long a worker loop may block handing over a
+	// job before /readyz fails
+	MaxDispatchWait Duration `yaml:"max_dispatch_wait" toml:"max_dispatch_wait"`
+}
+
+// Autoscale grows and shrinks the worker pool with the queue. Added workers
+// are built from Worker, whose ID is the prefix of theirs.
+type Autoscale struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// MinWorkers and MaxWorkers bound the whole pool, configured workers
+	// included
+	MinWorkers        int      `yaml:"min_workers" toml:"min_workers"`
+	MaxWorkers        int      `yaml:"max_workers" toml:"max_workers"`
+	Interval          Duration `yaml:"interval" toml:"interval"`
+	ScaleUpCooldown   Duration `yaml:"scale_up_cooldown" toml:"scale_up_cooldown"`
+	ScaleDownCooldown Duration `yaml:"scale_down_cooldown" toml:"scale_down_cooldown"`
+	// QueuePerThread and TargetWait are the queued jobs per thread and the
+	// oldest job's wait that trigger scaling up, 0 disables either
+	QueuePerThread float64  `yaml:"queue_per_thread" toml:"queue_per_thread"`
+	TargetWait     Duration `yaml:"target_wait" toml:"target_wait"`
+	// MaxCPU holds back scaling up above this CPU use, 0 disables
+	MaxCPU float64 `yaml:"max_cpu" toml:"max_cpu"`
+	// IdleUtilization is the busy thread fraction below which an empty
+	// queue scales down
+	IdleUtilization float64  `yaml:"idle_utilization" toml:"idle_utilization"`
+	DrainTimeout    Duration `yaml:"drain_timeout" toml:"drain_timeout"`
+	Worker          Worker   `yaml:"worker" toml:"worker"`
+}
+
+// Auth protects the API with API keys and JWT bearer tokens
+type Auth struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// JWTSecret verifies HS256 tokens, empty accepts API keys only
+	JWTSecret   string `yaml:"jwt_secret" toml:"jwt_secret"`
+	JWTIssuer   string `yaml:"jwt_issuer" toml:"jwt_issuer"`
+	JWTAudience string `yaml:"jwt_audience" toml:"jwt_audience"`
+	// BootstrapKey is an admin key for creating the first stored keys
+	BootstrapKey string `yaml:"bootstrap_key" toml:"bootstrap_key"`
+}
+
+// Duration is a time.Duration written like "30s" in files and env
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+// Default is the configuration used for anything not set elsewhere: two
+// workers of 8 and 2 threads and local services on their usual ports
+func Default() Config {
+	return Config{
+		API: API{
+			Port:                  "8080",
+			ShutdownTimeout:       Duration(30 * time.Second),
+			ProgressCacheInterval: Duration(time.Second),
+			// The frontend in docker compose and under the Vite dev server
+			CORSOrigins: []string{"http://localhost:3000", "http://localhost:5173"},
+		},
+		Postgres: Postgres{Host: "localhost", Port: "5432", DB: "job_scheduler", SSLMode: "disable"},
+		Redis:    Redis{Host: "localhost", Port: "6379"},
+		Logging:  Logging{Level: "info", Format: "json", JobLevel: "info", JobMaxLines: 1000},
+		Workers: []Worker{
+			{ID: "w1", Threads: 8, QueueSize: DefaultQueueSize},
+			{ID: "w2", Threads: 2, QueueSize: DefaultQueueSize},
+		},
+		Scheduler: Scheduler{
+			Policy:                scheduler.PolicyBestFit,
+			OversizePolicy:        string(scheduler.OversizeReject),
+			WorkStealing:          true,
+			PreemptionGracePeriod: Duration(30 * time.Second),
+			DefaultTenant:         Tenant{Weight: 1},
+		},
+		Artifacts: Artifacts{Store: "local", S3: S3{Region: "us-east-1"}, OffloadBytes: 1 << 20, MaxUploadBytes: 256 << 20},
+		Health: Health{
+			CheckTimeout:    Duration(2 * time.Second),
+			MaxDispatchWait: Duration(30 * time.Second),
+		},
+		Autoscale: Autoscale{
+			MaxWorkers:        8,
+			Interval:          Duration(10 * time.Second),
+			ScaleUpCooldown:   Duration(30 * time.Second),
+			ScaleDownCooldown: Duration(2 * time.Minute),
+			QueuePerThread:    2,
+			TargetWait:        Duration(10 * time.Second),
+			MaxCPU:            0.9,
+			IdleUtilization:   0.25,
+			DrainTimeout:      Duration(time.Minute),
+			Worker:            Worker{ID: "auto", Threads: 4, QueueSize: DefaultQueueSize},
+		},
+	}
+}
+
+// DefaultQueueSize is the JobQueue size of workers that don't set one
+const DefaultQueueSize = 100
+
+// Load reads path, if not empty, over the defaults, applies environment
+// overrides and validates the result. The file format follows the
+// extension: .yaml, .yml or .toml.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := cfg.applyEnv(os.Getenv); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	case ".toml":
+		err = toml.NewDecoder(strings.NewReader(string(data))).DisallowUnknownFields().Decode(c)
+	default:
+		return fmt.Errorf("config: %s: unsupported format %q (want .yaml, .yml or .toml)", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	// Workers listed without a queue size get the default
+	for i := range c.Workers {
+		if c.Workers[i].QueueSize == 0 {
+			c.Workers[i].QueueSize = DefaultQueueSize
+		}
+	}
+	return nil
+}
+
+// Validate reports every problem with c at once
+func (c Config) Validate() error {
+	var errs []error
+	bad := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if n, err := strconv.Atoi(c.API.Port); err != nil || n < 1 || n > 65535 {
+		bad("api.port", "%q is not a port number", c.API.Port)
+	}
+	if c.API.ShutdownTimeout < 0 || c.API.StartupRetryTimeout < 0 || c.API.ProgressCacheInterval < 0 {
+		bad("api", "durations can't be negative")
+	}
+	for i, origin := range c.API.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			bad(fmt.Sprintf("api.cors_origins[%d]", i), "%q is not an origin like https://app.example.com", origin)
+		}
+	}
+	if c.Postgres.Host == "" {
+		bad("postgres.host", "required")
+	}
+	if c.Redis.Host == "" {
+		bad("redis.host", "required")
+	}
+
+	for field, level := range map[string]string{"logging.level": c.Logging.Level, "logging.job_level": c.Logging.JobLevel} {
+		switch strings.ToLower(level) {
+		case "debug", "info", "warn", "error":
+		default:
+			bad(field, "%q is not one of debug, info, warn or error", level)
+		}
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		bad("logging.format", "%q is not json or text", c.Logging.Format)
+	}
+	if c.Logging.JobMaxLines < 0 {
+		bad("logging.job_max_lines", "can't be negative")
+	}
+
+	if len(c.Workers) == 0 {
+		bad("workers", "at least one worker is required")
+	}
+	ids := make(map[string]int)
+	for i, w := range c.Workers {
+		field := fmt.Sprintf("workers[%d]", i)
+		if w.ID == "" {
+			bad(field+".id", "required")
+		} else if prev, ok := ids[w.ID]; ok {
+			bad(field+".id", "%q is already used by workers[%d]", w.ID, prev)
+		} else {
+			ids[w.ID] = i
+		}
+		if w.Threads < 1 {
+			bad(field+".threads", "must be at least 1, got %d", w.Threads)
+		}
+		if w.QueueSize < 1 {
+			bad(field+".queue_size", "must be at least 1, got %d", w.QueueSize)
+		}
+		if w.MemoryMB < 0 {
+			bad(field+".memory_mb", "can't be negative")
+		}
+		for name, n := range w.Resources {
+			if n < 0 {
+				bad(field+".resources."+name, "can't be negative")
+			}
+		}
+	}
+
+	if _, err := scheduler.NewPolicy(c.Scheduler.Policy, nil); err != nil {
+		bad("scheduler.policy", "%v", err)
+	}
+	if _, err := scheduler.ParseOversizePolicy(c.Scheduler.OversizePolicy); err != nil {
+		bad("scheduler.oversize_policy", "%v", err)
+	}
+	if c.Scheduler.PreemptionGracePeriod < 0 {
+		bad("scheduler.preemption_grace_period", "can't be negative")
+	}
+	for name, t := range c.Scheduler.Tenants {
+		checkTenant(bad, "scheduler.tenants."+name, t)
+	}
+	checkTenant(bad, "scheduler.default_tenant", c.Scheduler.DefaultTenant)
+
+	switch c.Artifacts.Store {
+	case "local":
+	case "s3":
+		if c.Artifacts.S3.Endpoint == "" {
+			bad("artifacts.s3.endpoint", "required with the s3 store")
+		}
+		if c.Artifacts.S3.Bucket == "" {
+			bad("artifacts.s3.bucket", "required with the s3 store")
+		}
+	default:
+		bad("artifacts.store", "%q is not local or s3", c.Artifacts.Store)
+	}
+	if c.Artifacts.OffloadBytes < 0 {
+		bad("artifacts.offload_bytes", "can't be negative")
+	}
+	if c.Artifacts.MaxUploadBytes < 1 {
+		bad("artifacts.max_upload_bytes", "must be at least 1")
+	}
+
+	if c.Health.CheckTimeout <= 0 {
+		bad("health.check_timeout", "must be positive")
+	}
+	if c.Health.MaxDispatchWait < 0 {
+		bad("health.max_dispatch_wait", "can't be negative")
+	}
+
+	if a := c.Autoscale; a.Enabled {
+		if a.MinWorkers < 0 {
+			bad("autoscale.min_workers", "can't be negative")
+		}
+		if a.MaxWorkers < max(a.MinWorkers, len(c.Workers), 1) {
+			bad("autoscale.max_workers", "must be at least min_workers and the %d configured workers, got %d", len(c.Workers), a.MaxWorkers)
+		}
+		if a.Interval <= 0 {
+			bad("autoscale.interval", "must be positive")
+		}
+		if a.ScaleUpCooldown < 0 || a.ScaleDownCooldown < 0 || a.TargetWait < 0 || a.DrainTimeout < 0 {
+			bad("autoscale", "durations can't be negative")
+		}
+		if a.QueuePerThread < 0 {
+			bad("autoscale.queue_per_thread", "can't be negative")
+		}
+		if a.MaxCPU < 0 || a.MaxCPU > 1 {
+			bad("autoscale.max_cpu", "must be between 0 and 1, got %g", a.MaxCPU)
+		}
+		if a.IdleUtilization < 0 || a.IdleUtilization > 1 {
+			bad("autoscale.idle_utilization", "must be between 0 and 1, got %g", a.IdleUtilization)
+		}
+		if a.Worker.ID == "" {
+			bad("autoscale.worker.id", "required as the prefix of added workers")
+		}
+		if a.Worker.Threads < 1 {
+			bad("autoscale.worker.threads", "must be at least 1, got %d", a.Worker.Threads)
+		}
+		if a.Worker.QueueSize < 1 {
+			bad("autoscale.worker.queue_size", "must be at least 1, got %d", a.Worker.QueueSize)
+		}
+		if a.Worker.MemoryMB < 0 {
+			bad("autoscale.worker.memory_mb", "can't be negative")
+		}
+	}
+
+	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < 32 {
+		bad("auth.jwt_secret", "must be at least 32 bytes")
+	}
+	if c.Auth.BootstrapKey != "" && len(c.Auth.BootstrapKey) < 16 {
+		bad("auth.bootstrap_key", "must be at least 16 characters")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func checkTenant(bad func(field, format string, args ...interface{}), field string, t Tenant) {
+	if t.Weight <= 0 {
+		bad(field+".weight", "must be positive, got %g", t.Weight)
+	}
+	if t.MaxThreads < 0 || t.MaxQueued < 0 {
+		bad(field, "quotas can't be negative")
+	}
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func envMap(m map[string]string) func(string) string {
+	return func(key string) string { return m[key] }
+}
+
+const yamlConfig = `
+api:
+  shutdown_timeout: 1m
+workers:
+  - id: big
+    threads: 16
+    labels: {tier: fast}
+  - id: small
+    threads: 2
+    queue_size: 10
+    resources: {gpu-license: 1}
+  - id: spare
+    threads: 4
+scheduler:
+  policy: fair_share
+  tenants:
+    team-a: {weight: 2, max_threads: 8}
+artifacts:
+  store: s3
+  s3: {endpoint: "http://minio:9000", bucket: jobs}
+`
+
+const tomlConfig = `
+[api]
+shutdown_timeout = "1m"
+
+[[workers]]
+id = "big"
+threads = 16
+labels = { tier = "fast" }
+
+[[workers]]
+id = "small"
+threads = 2
+queue_size = 10
+resources = { gpu-license = 1 }
+
+[[workers]]
+id = "spare"
+threads = 4
+
+[scheduler]
+policy = "fair_share"
+tenants = { team-a = { weight = 2.0, max_threads = 8 } }
+
+[artifacts]
+store = "s3"
+s3 = { endpoint = "http://minio:9000", bucket = "jobs" }
+`
+
+func TestReadFile(t *testing.T) {
+	for name, content := range map[string]string{"config.yaml": yamlConfig, "config.toml": tomlConfig} {
+		t.Run(name, func(t *testing.T) {
+			cfg := Default()
+			if err := cfg.readFile(writeFile(t, name, content)); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.Workers) != 3 {
+				t.Fatalf("expected the file's 3 workers to replace the defaults, got %+v", cfg.Workers)
+			}
+			big, small := cfg.Workers[0], cfg.Workers[1]
+			if big.ID != "big" || big.Threads != 16 || big.QueueSize != DefaultQueueSize || big.Labels["tier"] != "fast" {
+				t.Errorf("unexpected first worker %+v", big)
+			}
+			if small.QueueSize != 10 || small.Resources["gpu-license"] != 1 {
+				t.Errorf("unexpected second worker %+v", small)
+			}
+			if cfg.API.ShutdownTimeout.D() != time.Minute {
+				t.Errorf("expected shutdown timeout 1m, got %s", cfg.API.ShutdownTimeout.D())
+			}
+			if cfg.Scheduler.Policy != "fair_share" || cfg.Scheduler.Tenants["team-a"].MaxThreads != 8 {
+				t.Errorf("unexpected scheduler config %+v", cfg.Scheduler)
+			}
+			// Sections the file leaves out keep their defaults
+			if cfg.Postgres.Host != "localhost" || !cfg.Scheduler.WorkStealing {
+				t.Error("expected defaults for settings missing from the file")
+			}
+		})
+	}
+}
+
+func TestReadFileRejectsUnknownFields(t *testing.T) {
+	for name, content := range map[string]string{
+		"config.yaml": "workers:\n  - id: w1\n    thread: 4\n",
+		"config.toml": "[[workers]]\nid = \"w1\"\nthread = 4\n",
+		"config.json": "{}",
+	} {
+		cfg := Default()
+		if err := cfg.readFile(writeFile(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	cfg := Default()
+	err := cfg.applyEnv(envMap(map[string]string{
+		"WORKER_QUEUE_SIZE":    "50",
+		"WORKER_1_THREADS":     "4",
+		"WORKER_1_LABELS":      "tier=fast,region=a",
+		"WORKER_3_ID":          "gpu",
+		"WORKER_3_THREADS":     "1",
+		"WORKER_3_RESOURCES":   "gpu-license=1",
+		"WORK_STEALING":        "false",
+		"TENANTS":              `{"team-a":{"weight":3}}`,
+		"HEALTH_CHECK_TIMEOUT": "5s",
+		"AUTOSCALE_ENABLED":    "true",
+		"AUTOSCALE_MAX_CPU":    "0.75",
+		"CORS_ALLOWED_ORIGINS": "https://app.example.com, http://localhost:3000",
+		"AUTH_ENABLED":         "true",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Workers) != 3 {
+		t.Fatalf("expected WORKER_3_* to add a worker, got %+v", cfg.Workers)
+	}
+	if w := cfg.Workers[0]; w.ID != "w1" || w.Threads != 4 || w.QueueSize != 50 || w.Labels["region"] != "a" {
+		t.Errorf("unexpected first worker %+v", w)
+	}
+	if w := cfg.Workers[2]; w.ID != "gpu" || w.Threads != 1 || w.QueueSize != 50 || w.Resources["gpu-license"] != 1 {
+		t.Errorf("unexpected added worker %+v", w)
+	}
+	if cfg.Scheduler.WorkStealing || cfg.Scheduler.Tenants["team-a"].Weight != 3 || cfg.Health.CheckTimeout.D() != 5*time.Second {
+		t.Errorf("unexpected overrides %+v %+v", cfg.Scheduler, cfg.Health)
+	}
+	if !cfg.Autoscale.Enabled || cfg.Autoscale.MaxCPU != 0.75 {
+		t.Errorf("unexpected autoscale overrides %+v", cfg.Autoscale)
+	}
+	if len(cfg.API.CORSOrigins) != 2 || cfg.API.CORSOrigins[0] != "https://app.example.com" || !cfg.Auth.Enabled {
+		t.Errorf("unexpected auth overrides %+v %+v", cfg.API.CORSOrigins, cfg.Auth)
+	}
+}
+
+func TestEnvErrorsNameTheVariable(t *testing.T) {
+	cfg := Default()
+	err := cfg.applyEnv(envMap(map[string]string{
+		"WORKER_1_THREADS": "eight",
+		"WORK_STEALING":    "maybe",
+		"SHUTDOWN_TIMEOUT": "30",
+	}))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"WORKER_1_THREADS", "WORK_STEALING", "SHUTDOWN_TIMEOUT"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s in %q", want, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("expected the defaults to be valid: %v", err)
+	}
+
+	cfg := Default()
+	cfg.Workers = append(cfg.Workers, Worker{ID: "w1", Threads: 0, QueueSize: 1})
+	cfg.Scheduler.Policy = "random"
+	cfg.Artifacts.Store = "s3"
+	cfg.Logging.Format = "xml"
+	cfg.API.CORSOrigins = []string{"https://ok.example.com", "ftp://files", "https://app.example.com/path"}
+	cfg.Auth.JWTSecret = "short"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		`workers[2].id: "w1" is already used by workers[0]`,
+		"workers[2].threads: must be at least 1",
+		"scheduler.policy",
+		"artifacts.s3.bucket: required",
+		"logging.format",
+		`api.cors_origins[1]: "ftp://files" is not an origin`,
+		"api.cors_origins[2]",
+		"auth.jwt_secret: must be at least 32 bytes",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+
+	cfg = Default()
+	cfg.Workers = nil
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "at least one worker") {
+		t.Errorf("expected an error for no workers, got %v", err)
+	}
+
+	cfg = Default()
+	cfg.Autoscale.Enabled = true
+	cfg.Autoscale.MaxWorkers = 1
+	cfg.Autoscale.MaxCPU = 90
+	err = cfg.Validate()
+	for _, want := range []string{
+		"autoscale.max_workers: must be at least min_workers and the 2 configured workers",
+		"autoscale.max_cpu: must be between 0 and 1",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// workerEnv are the per-worker variables, WORKER_<n>_<suffix> with n from 1
+var workerEnv = []string{"ID", "THREADS", "QUEUE_SIZE", "MEMORY_MB", "RESOURCES", "LABELS"}
+
+// env reads overrides, collecting what doesn't parse. Unset and empty
+// variables leave the value alone.
+type env struct {
+	getenv func(string) string
+	errs   []error
+}
+
+func (e *env) str(key string, dst *string) {
+	if v := e.getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func (e *env) int(key string, dst *int) {
+	if v := e.getenv(key); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", key, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *env) bool(key string, dst *bool) {
+	if v := e.getenv(key); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not true or false", key, v))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (e *env) float(key string, dst *float64) {
+	if v := e.getenv(key); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not a number", key, v))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (e *env) duration(key string, dst *Duration) {
+	if v := e.getenv(key); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not a duration like 30s", key, v))
+			return
+		}
+		*dst = Duration(d)
+	}
+}
+
+// pairs parses "tier=fast,region=a"
+func (e *env) pairs(key string, dst *map[string]string) {
+	v := e.getenv(key)
+	if v == "" {
+		return
+	}
+	out := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not key=value", key, pair))
+			return
+		}
+		out[k] = val
+	}
+	*dst = out
+}
+
+// list parses "a,b,c"
+func (e *env) list(key string, dst *[]string) {
+	v := e.getenv(key)
+	if v == "" {
+		return
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	*dst = out
+}
+
+// resources parses "gpu-license=1,ssd=2"
+func (e *env) resources(key string, dst *map[string]int) {
+	var pairs map[string]string
+	e.pairs(key, &pairs)
+	if pairs == nil {
+		return
+	}
+	out := make(map[string]int, len(pairs))
+	for name, amount := range pairs {
+		n, err := strconv.Atoi(amount)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: amount %q of %s is not an integer", key, amount, name))
+			return
+		}
+		out[name] = n
+	}
+	*dst = out
+}
+
+// applyEnv overrides c with the environment variables the API has always
+// read, so existing .env files keep working on top of a config file
+func (c *Config) applyEnv(getenv func(string) string) error {
+	e := &env{getenv: getenv}
+
+	e.str("API_PORT", &c.API.Port)
+	e.duration("SHUTDOWN_TIMEOUT", &c.API.ShutdownTimeout)
+	e.duration("STARTUP_RETRY_TIMEOUT", &c.API.StartupRetryTimeout)
+	e.duration("PROGRESS_CACHE_INTERVAL", &c.API.ProgressCacheInterval)
+	e.list("CORS_ALLOWED_ORIGINS", &c.API.CORSOrigins)
+
+	e.str("POSTGRES_HOST", &c.Postgres.Host)
+	e.str("POSTGRES_PORT", &c.Postgres.Port)
+	e.str("POSTGRES_USER", &c.Postgres.User)
+	e.str("POSTGRES_PASSWORD", &c.Postgres.Password)
+	e.str("POSTGRES_DB", &c.Postgres.DB)
+	e.str("POSTGRES_SSL_MODE", &c.Postgres.SSLMode)
+
+	e.str("REDIS_HOST", &c.Redis.Host)
+	e.str("REDIS_PORT", &c.Redis.Port)
+	e.str("REDIS_PASSWORD", &c.Redis.Password)
+
+	e.str("LOG_LEVEL", &c.Logging.Level)
+	e.str("LOG_FORMAT", &c.Logging.Format)
+	e.str("JOB_LOG_LEVEL", &c.Logging.JobLevel)
+	e.int("JOB_LOG_MAX_LINES", &c.Logging.JobMaxLines)
+
+	// WORKER_QUEUE_SIZE sets every worker, WORKER_<n>_QUEUE_SIZE one of them
+	var queueSize int
+	e.int("WORKER_QUEUE_SIZE", &queueSize)
+	if queueSize != 0 {
+		for i := range c.Workers {
+			c.Workers[i].QueueSize = queueSize
+		}
+	}
+	// WORKER_<n>_* change the n-th worker, adding it if the file and
+	// defaults have fewer
+	for n := 1; n <= len(c.Workers) || workerSet(getenv, n); n++ {
+		if n > len(c.Workers) {
+			size := queueSize
+			if size == 0 {
+				size = DefaultQueueSize
+			}
+			c.Workers = append(c.Workers, Worker{ID: fmt.Sprintf("w%d", n), QueueSize: size})
+		}
+		w := &c.Workers[n-1]
+		prefix := fmt.Sprintf("WORKER_%d_", n)
+		e.str(prefix+"ID", &w.ID)
+		e.int(prefix+"THREADS", &w.Threads)
+		e.int(prefix+"QUEUE_SIZE", &w.QueueSize)
+		e.int(prefix+"MEMORY_MB", &w.MemoryMB)
+		e.resources(prefix+"RESOURCES", &w.Resources)
+		e.pairs(prefix+"LABELS", &w.Labels)
+	}
+
+	e.str("SCHEDULER_POLICY", &c.Scheduler.Policy)
+	e.str("OVERSIZE_THREAD_POLICY", &c.Scheduler.OversizePolicy)
+	e.bool("SCHEDULER_BACKFILL", &c.Scheduler.Backfill)
+	e.bool("WORK_STEALING", &c.Scheduler.WorkStealing)
+	e.bool("PREEMPTION_ENABLED", &c.Scheduler.Preemption)
+	e.duration("PREEMPTION_GRACE_PERIOD", &c.Scheduler.PreemptionGracePeriod)
+	e.int("TENANT_DEFAULT_MAX_THREADS", &c.Scheduler.DefaultTenant.MaxThreads)
+	e.int("TENANT_DEFAULT_MAX_QUEUED", &c.Scheduler.DefaultTenant.MaxQueued)
+	// TENANTS is a JSON object like {"team-a":{"weight":2,"max_threads":4}}
+	// and replaces the tenants from the file
+	if v := getenv("TENANTS"); v != "" {
+		var tenants map[string]Tenant
+		if err := json.Unmarshal([]byte(v), &tenants); err != nil {
+			e.errs = append(e.errs, fmt.Errorf("TENANTS: %w", err))
+		} else {
+			c.Scheduler.Tenants = tenants
+		}
+	}
+
+	e.str("ARTIFACT_STORE", &c.Artifacts.Store)
+	e.str("ARTIFACT_DIR", &c.Artifacts.Dir)
+	e.int("ARTIFACT_OFFLOAD_BYTES", &c.Artifacts.OffloadBytes)
+	e.int("ARTIFACT_MAX_UPLOAD_BYTES", &c.Artifacts.MaxUploadBytes)
+	e.str("S3_ENDPOINT", &c.Artifacts.S3.Endpoint)
+	e.str("S3_BUCKET", &c.Artifacts.S3.Bucket)
+	e.str("S3_REGION", &c.Artifacts.S3.Region)
+	e.str("S3_ACCESS_KEY_ID", &c.Artifacts.S3.AccessKeyID)
+	e.str("S3_SECRET_ACCESS_KEY", &c.Artifacts.S3.SecretAccessKey)
+
+	e.duration("HEALTH_CHECK_TIMEOUT", &c.Health.CheckTimeout)
+	e.duration("HEALTH_MAX_DISPATCH_WAIT", &c.Health.MaxDispatchWait)
+
+	a := &c.Autoscale
+	e.bool("AUTOSCALE_ENABLED", &a.Enabled)
+	e.int("AUTOSCALE_MIN_WORKERS", &a.MinWorkers)
+	e.int("AUTOSCALE_MAX_WORKERS", &a.MaxWorkers)
+	e.duration("AUTOSCALE_INTERVAL", &a.Interval)
+	e.duration("AUTOSCALE_SCALE_UP_COOLDOWN", &a.ScaleUpCooldown)
+	e.duration("AUTOSCALE_SCALE_DOWN_COOLDOWN", &a.ScaleDownCooldown)
+	e.float("AUTOSCALE_QUEUE_PER_THREAD", &a.QueuePerThread)
+	e.duration("AUTOSCALE_TARGET_WAIT", &a.TargetWait)
+	e.float("AUTOSCALE_MAX_CPU", &a.MaxCPU)
+	e.float("AUTOSCALE_IDLE_UTILIZATION", &a.IdleUtilization)
+	e.duration("AUTOSCALE_DRAIN_TIMEOUT", &a.DrainTimeout)
+	e.str("AUTOSCALE_WORKER_ID_PREFIX", &a.Worker.ID)
+	e.int("AUTOSCALE_WORKER_THREADS", &a.Worker.Threads)
+	e.int("AUTOSCALE_WORKER_QUEUE_SIZE", &a.Worker.QueueSize)
+	e.int("AUTOSCALE_WORKER_MEMORY_MB", &a.Worker.MemoryMB)
+	e.resources("AUTOSCALE_WORKER_RESOURCES", &a.Worker.Resources)
+	e.pairs("AUTOSCALE_WORKER_LABELS", &a.Worker.Labels)
+
+	e.bool("AUTH_ENABLED", &c.Auth.Enabled)
+	e.str("AUTH_JWT_SECRET", &c.Auth.JWTSecret)
+	e.str("AUTH_JWT_ISSUER", &c.Auth.JWTIssuer)
+	e.str("AUTH_JWT_AUDIENCE", &c.Auth.JWTAudience)
+	e.str("AUTH_BOOTSTRAP_KEY", &c.Auth.BootstrapKey)
+
+	if len(e.errs) > 0 {
+		return fmt.Errorf("invalid environment:\n%w", errors.Join(e.errs...))
+	}
+	return nil
+}
+
+// workerSet reports whether any WORKER_<n>_* variable is set
+func workerSet(getenv func(string) string, n int) bool {
+	for _, suffix := range workerEnv {
+		if getenv(fmt.Sprintf("WORKER_%d_%s", n, suffix)) != "" {
+			return true
+		}
+	}
+	return false
+}
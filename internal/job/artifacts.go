@@ -0,0 +1,122 @@
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/artifact"
+)
+
+// Artifacts is where jobs write the files they produce (resized images) and
+// where large payloads and results are offloaded to. The API replaces it on
+// startup according to ARTIFACT_STORE.
+var Artifacts artifact.Store = artifact.NewLocalStore(filepath.Join(os.TempDir(), "job-artifacts"))
+
+// payloadDecoders turn a stored payload back into the type the job's
+// handler expects
+var payloadDecoders = map[JobType]func([]byte) (interface{}, error){
+	AddNumbersJob:    decodeAs[AddNumbersPayload],
+	ReverseStringJob: decodeAs[ReverseStringPayload],
+	ResizeImageJob:   decodeAs[ResizeImagePayload],
+	LargeArraySumJob: decodeAs[LargeArraySumPayload],
+}
+
+func decodeAs[P any](data []byte) (interface{}, error) {
+	var p P
+	err := json.Unmarshal(data, &p)
+	return p, err
+}
+
+// RegisterPayload lets jobs of type t load a P payload from the artifact
+// store. RegisterMapReduce does this for its payload type.
+func RegisterPayload[P any](t JobType) {
+	payloadDecoders[t] = decodeAs[P]
+}
+
+// OffloadPayload moves the payload to the artifact store under
+// payloads/<id>.json when its JSON encoding is larger than threshold bytes,
+// leaving only PayloadRef on the job. A threshold <= 0 disables offloading.
+func (j *Job) OffloadPayload(threshold int) error {
+	if threshold <= 0 || j.Payload == nil {
+		return nil
+	}
+	a, err := offload(fmt.Sprintf("payloads/%s.json", j.ID), j.Payload, threshold)
+	if err != nil || a == nil {
+		return err
+	}
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	j.PayloadRef = a.Key
+	j.Payload = nil
+	return nil
+}
+
+// OffloadResult is OffloadPayload for a finished job's Result, stored under
+// results/<id>.json
+func (j *Job) OffloadResult(threshold int) error {
+	if threshold <= 0 || j.Result == nil {
+		return nil
+	}
+	a, err := offload(fmt.Sprintf("results/%s.json", j.ID), j.Result, threshold)
+	if err != nil || a == nil {
+		return err
+	}
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	j.ResultRef = a.Key
+	j.Result = nil
+	return nil
+}
+
+func offload(key string, v interface{}, threshold int) (*artifact.Artifact, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= threshold {
+		return nil, nil
+	}
+	a, err := Artifacts.Put(key, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// LoadPayload fetches an offloaded payload back from the artifact store.
+// It's a no-op once the payload is in memory, so every thread of a chunked
+// job can call it.
+func (j *Job) LoadPayload() *JobError {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	if j.Payload != nil || j.PayloadRef == "" {
+		return nil
+	}
+	decode, ok := payloadDecoders[j.Type]
+	if !ok {
+		return NewJobError(ErrUnsupportedType, fmt.Sprintf("can't load %s payloads from the artifact store", j.Type), false)
+	}
+	r, err := Artifacts.Get(j.PayloadRef)
+	if err != nil {
+		// A missing artifact won't turn up on retry; anything else might be
+		// the store being briefly unreachable
+		return NewJobError(ErrExecution, "load payload: "+err.Error(), !errors.Is(err, artifact.ErrNotFound))
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return NewJobError(ErrExecution, "load payload: "+err.Error(), true)
+	}
+	payload, err := decode(data)
+	if err != nil {
+		return NewJobError(ErrInvalidPayload, fmt.Sprintf("decode %s payload %s: %v", j.Type, j.PayloadRef, err), false)
+	}
+	j.Payload = payload
+	j.Log().Info("loaded payload from artifact store", "key", j.PayloadRef, "bytes", len(data))
+	return nil
+}
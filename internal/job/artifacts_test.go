@@ -0,0 +1,97 @@
+package job
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestOffloadedPayloadIsLoadedWhenJobRuns(t *testing.T) {
+	store := useTestArtifacts(t)
+	array := make([]int, 1000)
+	for i := range array {
+		array[i] = 1
+	}
+	j := NewJob("big", "Sum", LargeArraySumJob, 1, LargeArraySumPayload{Array: array})
+
+	if err := j.OffloadPayload(100); err != nil {
+		t.Fatal(err)
+	}
+	if j.Payload != nil || j.PayloadRef != "payloads/big.json" {
+		t.Fatalf("expected payload offloaded, got ref %q payload %T", j.PayloadRef, j.Payload)
+	}
+	if _, err := store.Stat(j.PayloadRef); err != nil {
+		t.Fatalf("payload not in store: %v", err)
+	}
+
+	j.Execute()
+	if j.Status != Completed {
+		t.Fatalf("expected Completed, got %s (%v)", j.Status, j.Error)
+	}
+	if res := j.Result.(LargeArraySumResult); res.Sum != 1000 {
+		t.Errorf("expected sum 1000, got %d", res.Sum)
+	}
+}
+
+func TestSmallPayloadsStayInline(t *testing.T) {
+	useTestArtifacts(t)
+	j := NewJob("small", "Add", AddNumbersJob, 1, AddNumbersPayload{X: 1, Y: 2})
+	if err := j.OffloadPayload(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if j.PayloadRef != "" || j.Payload == nil {
+		t.Errorf("small payload was offloaded to %q", j.PayloadRef)
+	}
+}
+
+func TestOffloadResult(t *testing.T) {
+	store := useTestArtifacts(t)
+	j := NewJob("rev", "Reverse", ReverseStringJob, 1, ReverseStringPayload{Text: "a long enough string"})
+	j.Execute()
+	if err := j.OffloadResult(10); err != nil {
+		t.Fatal(err)
+	}
+	if j.Result != nil || j.ResultRef != "results/rev.json" {
+		t.Fatalf("expected result offloaded, got ref %q", j.ResultRef)
+	}
+
+	r, err := store.Get(j.ResultRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	var res ReverseStringResult
+	if err := json.Unmarshal(data, &res); err != nil || res.Reversed != "gnirts hguone gnol a" {
+		t.Errorf("unexpected stored result %s (%v)", data, err)
+	}
+}
+
+func TestMissingPayloadArtifactFailsJob(t *testing.T) {
+	useTestArtifacts(t)
+	j := NewJob("gone", "Add", AddNumbersJob, 1, nil)
+	j.PayloadRef = "payloads/gone.json"
+	j.Execute()
+	if j.Status != Failed || j.Error.Code != ErrExecution || j.Error.Retryable {
+		t.Errorf("expected non-retryable execution failure, got %s %v", j.Status, j.Error)
+	}
+}
+
+func TestSplitLoadsOffloadedPayload(t *testing.T) {
+	useTestArtifacts(t)
+	j := NewJob("gang", "Sum", LargeArraySumJob, 1, LargeArraySumPayload{Array: []int{1, 2, 3, 4, 5, 6}})
+	if err := j.OffloadPayload(1); err != nil {
+		t.Fatal(err)
+	}
+	shards, err := j.Split([]int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shards {
+		s.Execute()
+	}
+	j.Merge(shards)
+	if j.Status != Completed || j.Result.(LargeArraySumResult).Sum != 21 {
+		t.Errorf("expected merged sum 21, got %s %v", j.Status, j.Result)
+	}
+}
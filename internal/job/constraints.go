@@ -0,0 +1,143 @@
+package job
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OpIn           Operator = "In"
+	OpNotIn        Operator = "NotIn"
+	OpExists       Operator = "Exists"
+	OpDoesNotExist Operator = "DoesNotExist"
+)
+
+// Rule matches a worker label. A rule with Weight 0 is a hard constraint;
+// a positive Weight makes it a soft preference that only affects scoring.
+type Rule struct {
+	Key      string   `json:"key"`
+	Operator Operator `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+	Weight   int      `json:"weight,omitempty"`
+}
+
+func (r Rule) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case OpIn:
+		return ok && slices.Contains(r.Values, v)
+	case OpNotIn:
+		return !ok || !slices.Contains(r.Values, v)
+	case OpExists:
+		return ok
+	case OpDoesNotExist:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func (r Rule) String() string {
+	switch r.Operator {
+	case OpExists, OpDoesNotExist:
+		return fmt.Sprintf("%s %s", r.Key, r.Operator)
+	default:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+}
+
+func (r Rule) Validate() error {
+	if r.Key == "" {
+		return fmt.Errorf("rule is missing a key")
+	}
+	if r.Weight < 0 {
+		return fmt.Errorf("rule %q: weight must not be negative", r.Key)
+	}
+	switch r.Operator {
+	case OpIn, OpNotIn:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("rule %q: %s needs at least one value", r.Key, r.Operator)
+		}
+	case OpExists, OpDoesNotExist:
+	default:
+		return fmt.Errorf("rule %q: unknown operator %q", r.Key, r.Operator)
+	}
+	return nil
+}
+
+// Constraints restrict which workers a job may run on, based on the workers'
+// labels. Affinity rules say where a job wants to run, anti-affinity rules
+// where it doesn't.
+type Constraints struct {
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
+	Affinity     []Rule            `json:"affinity,omitempty"`
+	AntiAffinity []Rule            `json:"anti_affinity,omitempty"`
+}
+
+func (c *Constraints) Validate() error {
+	if c == nil {
+		return nil
+	}
+	for _, r := range append(slices.Clone(c.Affinity), c.AntiAffinity...) {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Allows checks the hard constraints against a worker's labels. If they
+// aren't met, the reason says which one failed.
+func (c *Constraints) Allows(labels map[string]string) (bool, string) {
+	if c == nil {
+		return true, ""
+	}
+	for _, k := range sortedStringKeys(c.NodeSelector) {
+		if labels[k] != c.NodeSelector[k] {
+			return false, fmt.Sprintf("node selector %s=%s not matched", k, c.NodeSelector[k])
+		}
+	}
+	for _, r := range c.Affinity {
+		if r.Weight == 0 && !r.Matches(labels) {
+			return false, fmt.Sprintf("affinity %s not matched", r)
+		}
+	}
+	for _, r := range c.AntiAffinity {
+		if r.Weight == 0 && r.Matches(labels) {
+			return false, fmt.Sprintf("anti-affinity %s matched", r)
+		}
+	}
+	return true, ""
+}
+
+// Score sums the weights of the soft preferences a worker satisfies.
+// Higher is better.
+func (c *Constraints) Score(labels map[string]string) int {
+	if c == nil {
+		return 0
+	}
+	score := 0
+	for _, r := range c.Affinity {
+		if r.Weight > 0 && r.Matches(labels) {
+			score += r.Weight
+		}
+	}
+	for _, r := range c.AntiAffinity {
+		if r.Weight > 0 && !r.Matches(labels) {
+			score += r.Weight
+		}
+	}
+	return score
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
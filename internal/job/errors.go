@@ -0,0 +1,50 @@
+package job
+
+import "fmt"
+
+type ErrorCode string
+
+const (
+	ErrInvalidPayload  ErrorCode = "INVALID_PAYLOAD"
+	ErrUnsupportedType ErrorCode = "UNSUPPORTED_JOB_TYPE"
+	ErrExecution       ErrorCode = "EXECUTION_FAILED"
+	ErrPanic           ErrorCode = "PANIC"
+)
+
+// JobError describes why a job failed. It replaces the zero-valued results
+// that used to be stored on failure, so callers can tell a real result apart
+// from a failed run.
+type JobError struct {
+	Code      ErrorCode `json:"code"`
+	Message   string    `json:"message"`
+	Retryable bool      `json:"retryable"`
+	Stack     string    `json:"stack,omitempty"` // only set for panics
+}
+
+func NewJobError(code ErrorCode, message string, retryable bool) *JobError {
+	return &JobError{
+		Code:      code,
+		Message:   message,
+		Retryable: retryable,
+	}
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// PanicError wraps a recovered panic value and the stack captured at the
+// point of recovery. Panics are treated as bugs in the handler, so they are
+// not retryable.
+func PanicError(v interface{}, stack []byte) *JobError {
+	return &JobError{
+		Code:    ErrPanic,
+		Message: fmt.Sprintf("panic: %v", v),
+		Stack:   string(stack),
+	}
+}
+
+// invalidPayload builds the error stored when a payload type assertion fails
+func invalidPayload(jobType JobType, payload interface{}) *JobError {
+	return NewJobError(ErrInvalidPayload, fmt.Sprintf("expected %s payload, got %T", jobType, payload), false)
+}
@@ -0,0 +1,392 @@
+package job
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	maxImageSide  = 16384
+	maxImageBytes = 64 << 20
+	// resample holds the source as float64 RGBA, 32 bytes a pixel, so this
+	// caps its working set at 1GiB
+	maxImagePixels = 32 << 20
+)
+
+var imageClient = &http.Client{Timeout: 30 * time.Second}
+
+// Interpolation filters accepted in ResizeImagePayload.Filter
+const (
+	FilterNearest  = "nearest"
+	FilterBilinear = "bilinear"
+	FilterLanczos  = "lanczos"
+)
+
+// filter is a separable resampling kernel with the given support radius
+type filter struct {
+	support float64
+	kernel  func(x float64) float64
+}
+
+var filters = map[string]filter{
+	FilterNearest: {0.5, func(x float64) float64 {
+		if x >= -0.5 && x < 0.5 {
+			return 1
+		}
+		return 0
+	}},
+	FilterBilinear: {1, func(x float64) float64 {
+		x = math.Abs(x)
+		if x < 1 {
+			return 1 - x
+		}
+		return 0
+	}},
+	FilterLanczos: {3, func(x float64) float64 {
+		x = math.Abs(x)
+		if x >= 3 {
+			return 0
+		}
+		return sinc(x) * sinc(x/3)
+	}},
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// errStopped is returned by ResizeImage when stop asked it to give up
+var errStopped = NewJobError(ErrExecution, "stopped before finishing", true)
+
+// ResizeImage loads the image at payload.URL, resamples it and stores the
+// encoded result under resized/<id>.<format>. Progress counts resampled rows
+// and may be nil. stop, if set, is checked between steps and rows; once it
+// returns true ResizeImage gives up without storing anything.
+func ResizeImage(log *slog.Logger, id string, p ResizeImagePayload, progress ProgressFunc, stop func() bool) (ResizeImageResult, *JobError) {
+	if progress == nil {
+		progress = func(int64, int64, string) {}
+	}
+	if stop == nil {
+		stop = func() bool { return false }
+	}
+	filterName := strings.ToLower(defaultString(p.Filter, FilterBilinear))
+	f, ok := filters[filterName]
+	if !ok {
+		return ResizeImageResult{}, NewJobError(ErrInvalidPayload, fmt.Sprintf("unknown filter %q", p.Filter), false)
+	}
+	if p.Width < 0 || p.Height < 0 || (p.Width == 0 && p.Height == 0) ||
+		p.Width > maxImageSide || p.Height > maxImageSide {
+		return ResizeImageResult{}, NewJobError(ErrInvalidPayload,
+			fmt.Sprintf("invalid target size %dx%d", p.Width, p.Height), false)
+	}
+	if p.Quality < 0 || p.Quality > 100 {
+		return ResizeImageResult{}, NewJobError(ErrInvalidPayload, fmt.Sprintf("invalid quality %d", p.Quality), false)
+	}
+
+	progress(0, 0, "load")
+	data, jerr := loadImage(p.URL)
+	if jerr != nil {
+		return ResizeImageResult{}, jerr
+	}
+	if stop() {
+		return ResizeImageResult{}, errStopped
+	}
+	// A few KB of compressed input can declare a huge canvas; check the
+	// header before decoding allocates it
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ResizeImageResult{}, NewJobError(ErrExecution, "decode image: "+err.Error(), false)
+	}
+	if cfg.Width > maxImageSide || cfg.Height > maxImageSide || cfg.Width*cfg.Height > maxImagePixels {
+		return ResizeImageResult{}, NewJobError(ErrInvalidPayload,
+			fmt.Sprintf("source image %dx%d is too large", cfg.Width, cfg.Height), false)
+	}
+	src, srcFormat, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ResizeImageResult{}, NewJobError(ErrExecution, "decode image: "+err.Error(), false)
+	}
+	log.Info("decoded image", "format", srcFormat, "width", src.Bounds().Dx(), "height", src.Bounds().Dy(), "bytes", len(data))
+
+	format := strings.ToLower(defaultString(p.Format, srcFormat))
+	if format == "jpg" {
+		format = "jpeg"
+	}
+	if format != "png" && format != "jpeg" && format != "gif" {
+		return ResizeImageResult{}, NewJobError(ErrInvalidPayload, fmt.Sprintf("unsupported output format %q", format), false)
+	}
+
+	b := src.Bounds()
+	w, h := targetSize(b.Dx(), b.Dy(), p.Width, p.Height, p.KeepAspect)
+	start := time.Now()
+	dst := resample(src, w, h, f, progress, stop)
+	if dst == nil {
+		return ResizeImageResult{}, errStopped
+	}
+	log.Info("resized image", "width", w, "height", h, "filter", filterName, "took", time.Since(start))
+
+	rows := int64(b.Dy() + h)
+	progress(rows, rows, "encode")
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buf, dst)
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: defaultInt(p.Quality, jpeg.DefaultQuality)})
+	case "gif":
+		err = gif.Encode(&buf, dst, nil)
+	}
+	if err != nil {
+		return ResizeImageResult{}, NewJobError(ErrExecution, "encode image: "+err.Error(), false)
+	}
+	if stop() {
+		return ResizeImageResult{}, errStopped
+	}
+
+	ext := format
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+	progress(rows, rows, "store")
+	a, err := Artifacts.Put(fmt.Sprintf("resized/%s.%s", id, ext), &buf)
+	if err != nil {
+		return ResizeImageResult{}, NewJobError(ErrExecution, "store image: "+err.Error(), true)
+	}
+	log.Info("stored resized image", "key", a.Key, "bytes", a.Size)
+	return ResizeImageResult{
+		ResizedURL: a.URL,
+		Key:        a.Key,
+		Width:      w,
+		Height:     h,
+		Bytes:      a.Size,
+		Format:     format,
+	}, nil
+}
+
+// targetSize fills in a missing dimension from the source aspect ratio, and
+// with keepAspect shrinks one side so the image fits inside width x height
+func targetSize(srcW, srcH, width, height int, keepAspect bool) (int, int) {
+	ratio := float64(srcW) / float64(srcH)
+	switch {
+	case width == 0:
+		width = int(math.Round(float64(height) * ratio))
+	case height == 0:
+		height = int(math.Round(float64(width) / ratio))
+	case keepAspect:
+		if float64(width)/float64(height) > ratio {
+			width = int(math.Round(float64(height) * ratio))
+		} else {
+			height = int(math.Round(float64(width) / ratio))
+		}
+	}
+	return min(max(width, 1), maxImageSide), min(max(height, 1), maxImageSide)
+}
+
+// loadImage reads image bytes from a data:, http(s)://, file:// URL or a
+// plain local path
+func loadImage(raw string) ([]byte, *JobError) {
+	if raw == "" {
+		return nil, NewJobError(ErrInvalidPayload, "missing image url", false)
+	}
+	if rest, ok := strings.CutPrefix(raw, "data:"); ok {
+		_, encoded, found := strings.Cut(rest, ";base64,")
+		if !found {
+			return nil, NewJobError(ErrInvalidPayload, "only base64 data URLs are supported", false)
+		}
+		if base64.StdEncoding.DecodedLen(len(encoded)) > maxImageBytes {
+			return nil, NewJobError(ErrInvalidPayload, fmt.Sprintf("image larger than %d bytes", maxImageBytes), false)
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, NewJobError(ErrInvalidPayload, "bad data URL: "+err.Error(), false)
+		}
+		return data, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		resp, err := imageClient.Get(raw)
+		if err != nil {
+			return nil, NewJobError(ErrExecution, "fetch image: "+err.Error(), true)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			// Server errors may clear up; a 404 won't
+			return nil, NewJobError(ErrExecution, "fetch image: "+resp.Status, resp.StatusCode >= 500)
+		}
+		return readLimited(resp.Body)
+	}
+
+	path := raw
+	if err == nil && u.Scheme == "file" {
+		path = u.Path
+	} else if err == nil && u.Scheme != "" {
+		return nil, NewJobError(ErrInvalidPayload, fmt.Sprintf("unsupported url scheme %q", u.Scheme), false)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, NewJobError(ErrExecution, "open image: "+err.Error(), false)
+	}
+	defer f.Close()
+	return readLimited(f)
+}
+
+func readLimited(r io.Reader) ([]byte, *JobError) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImageBytes+1))
+	if err != nil {
+		return nil, NewJobError(ErrExecution, "read image: "+err.Error(), true)
+	}
+	if len(data) > maxImageBytes {
+		return nil, NewJobError(ErrInvalidPayload, fmt.Sprintf("image larger than %d bytes", maxImageBytes), false)
+	}
+	return data, nil
+}
+
+// resample scales src to w x h with two separable passes over premultiplied
+// RGBA, horizontal first. It returns nil if stop returns true between rows.
+func resample(src image.Image, w, h int, f filter, progress ProgressFunc, stop func() bool) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	in := make([]float64, sw*sh*4)
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			r, g, bl, a := src.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			i := (y*sw + x) * 4
+			in[i], in[i+1], in[i+2], in[i+3] = float64(r), float64(g), float64(bl), float64(a)
+		}
+	}
+
+	// Horizontal pass: sw x sh -> w x sh
+	mid := make([]float64, w*sh*4)
+	cols := weights(sw, w, f)
+	total := int64(sh + h)
+	progress(0, total, "resize")
+	for y := 0; y < sh; y++ {
+		if stop() {
+			return nil
+		}
+		progress(int64(y), total, "")
+		for x, c := range cols {
+			o := (y*w + x) * 4
+			for k, wt := range c.w {
+				i := (y*sw + c.start + k) * 4
+				mid[o] += in[i] * wt
+				mid[o+1] += in[i+1] * wt
+				mid[o+2] += in[i+2] * wt
+				mid[o+3] += in[i+3] * wt
+			}
+		}
+	}
+
+	// Vertical pass: w x sh -> w x h
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	rows := weights(sh, h, f)
+	for y, c := range rows {
+		if stop() {
+			return nil
+		}
+		progress(int64(sh+y), total, "")
+		for x := 0; x < w; x++ {
+			var px [4]float64
+			for k, wt := range c.w {
+				i := ((c.start+k)*w + x) * 4
+				px[0] += mid[i] * wt
+				px[1] += mid[i+1] * wt
+				px[2] += mid[i+2] * wt
+				px[3] += mid[i+3] * wt
+			}
+			a := clamp8(px[3], 255)
+			dst.SetRGBA(x, y, color.RGBA{clamp8(px[0], a), clamp8(px[1], a), clamp8(px[2], a), a})
+		}
+	}
+	progress(total, total, "")
+	return dst
+}
+
+// contrib is the run of source samples, and their normalised weights, that
+// make up one destination sample
+type contrib struct {
+	start int
+	w     []float64
+}
+
+func weights(srcLen, dstLen int, f filter) []contrib {
+	scale := float64(srcLen) / float64(dstLen)
+	// Widen the kernel when shrinking so every source pixel contributes
+	stretch := math.Max(scale, 1)
+	support := f.support * stretch
+
+	out := make([]contrib, dstLen)
+	for i := range out {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := max(int(math.Ceil(center-support)), 0)
+		hi := min(int(math.Floor(center+support)), srcLen-1)
+		if hi < lo {
+			// Nearest with no widening can fall between samples
+			lo = min(max(int(math.Round(center)), 0), srcLen-1)
+			hi = lo
+		}
+		ws := make([]float64, hi-lo+1)
+		var sum float64
+		for k := range ws {
+			ws[k] = f.kernel((float64(lo+k) - center) / stretch)
+			sum += ws[k]
+		}
+		if sum == 0 {
+			ws = []float64{1}
+			lo = min(max(int(math.Round(center)), 0), srcLen-1)
+			sum = 1
+		}
+		for k := range ws {
+			ws[k] /= sum
+		}
+		out[i] = contrib{start: lo, w: ws}
+	}
+	return out
+}
+
+// clamp8 converts a 16-bit channel value back to 8 bits, clipping the
+// overshoot Lanczos produces around hard edges. Premultiplied colour can't
+// exceed alpha, so hi is the pixel's alpha for colour channels.
+func clamp8(v float64, hi uint8) uint8 {
+	v = math.Round(v / 257)
+	if v < 0 {
+		return 0
+	}
+	if v > float64(hi) {
+		return hi
+	}
+	return uint8(v)
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func defaultInt(n, def int) int {
+	if n == 0 {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,219 @@
+package job
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/artifact"
+)
+
+// testImage writes a w x h PNG, left half red and right half blue, and
+// returns its path
+func testImage(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= w/2 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "in.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func useTestArtifacts(t *testing.T) *artifact.LocalStore {
+	t.Helper()
+	store := artifact.NewLocalStore(t.TempDir())
+	prev := Artifacts
+	Artifacts = store
+	t.Cleanup(func() { Artifacts = prev })
+	return store
+}
+
+func decodeArtifact(t *testing.T, store *artifact.LocalStore, key string) (image.Image, string) {
+	t.Helper()
+	r, err := store.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	img, format, err := image.Decode(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img, format
+}
+
+func TestResizeImageJobWithEachFilter(t *testing.T) {
+	store := useTestArtifacts(t)
+	src := testImage(t, 40, 20)
+
+	for _, filter := range []string{FilterNearest, FilterBilinear, FilterLanczos} {
+		j := NewJob("img-"+filter, "ResizeImage", ResizeImageJob, 1,
+			ResizeImagePayload{URL: src, Width: 10, Height: 5, Filter: filter})
+		j.Execute()
+		if j.Status != Completed {
+			t.Fatalf("%s: expected Completed, got %s (%v)", filter, j.Status, j.Error)
+		}
+		res := j.Result.(ResizeImageResult)
+		if res.Width != 10 || res.Height != 5 || res.Format != "png" || res.Bytes == 0 {
+			t.Fatalf("%s: unexpected result %+v", filter, res)
+		}
+
+		img, _ := decodeArtifact(t, store, res.Key)
+		if img.Bounds().Dx() != 10 || img.Bounds().Dy() != 5 {
+			t.Fatalf("%s: stored image is %v", filter, img.Bounds())
+		}
+		// Far from the seam the colours should survive resampling
+		if r, _, b, _ := img.At(0, 2).RGBA(); r>>8 < 250 || b>>8 > 5 {
+			t.Errorf("%s: expected red on the left, got r=%d b=%d", filter, r>>8, b>>8)
+		}
+		if r, _, b, _ := img.At(9, 2).RGBA(); b>>8 < 250 || r>>8 > 5 {
+			t.Errorf("%s: expected blue on the right, got r=%d b=%d", filter, r>>8, b>>8)
+		}
+	}
+}
+
+func TestResizeImageStopsWhenAsked(t *testing.T) {
+	store := useTestArtifacts(t)
+	src := testImage(t, 40, 20)
+
+	// Let it get part way through resampling
+	calls := 0
+	stop := func() bool {
+		calls++
+		return calls > 5
+	}
+	_, err := ResizeImage(slog.Default(), "stopped", ResizeImagePayload{URL: src, Width: 20}, nil, stop)
+	if err != errStopped {
+		t.Fatalf("expected ResizeImage to stop, got %v", err)
+	}
+	if _, err := store.Stat("resized/stopped.png"); err == nil {
+		t.Error("a stopped resize must not store its output")
+	}
+}
+
+func TestResizeImagePreservesAspectRatio(t *testing.T) {
+	useTestArtifacts(t)
+	src := testImage(t, 40, 20)
+
+	cases := []struct {
+		name         string
+		payload      ResizeImagePayload
+		wantW, wantH int
+	}{
+		{"height derived", ResizeImagePayload{URL: src, Width: 20}, 20, 10},
+		{"width derived", ResizeImagePayload{URL: src, Height: 5}, 10, 5},
+		{"fit inside box", ResizeImagePayload{URL: src, Width: 30, Height: 30, KeepAspect: true}, 30, 15},
+		{"stretch", ResizeImagePayload{URL: src, Width: 30, Height: 30}, 30, 30},
+	}
+	for _, c := range cases {
+		res, err := ResizeImage(slog.Default(), "aspect", c.payload, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if res.Width != c.wantW || res.Height != c.wantH {
+			t.Errorf("%s: expected %dx%d, got %dx%d", c.name, c.wantW, c.wantH, res.Width, res.Height)
+		}
+	}
+}
+
+func TestResizeImageConvertsFormatFromURL(t *testing.T) {
+	store := useTestArtifacts(t)
+	data, err := os.ReadFile(testImage(t, 16, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	res, jerr := ResizeImage(slog.Default(), "conv", ResizeImagePayload{URL: srv.URL + "/in.png", Width: 8, Format: "jpg", Quality: 80}, nil, nil)
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	if res.Format != "jpeg" || filepath.Ext(res.Key) != ".jpg" {
+		t.Fatalf("expected jpeg output, got %+v", res)
+	}
+	if _, format := decodeArtifact(t, store, res.Key); format != "jpeg" {
+		t.Errorf("stored artifact decodes as %s", format)
+	}
+
+	// data: URLs work too
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil)
+	inline := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	if res, jerr := ResizeImage(slog.Default(), "inline", ResizeImagePayload{URL: inline, Width: 2, Format: "gif"}, nil, nil); jerr != nil || res.Format != "gif" {
+		t.Errorf("data URL resize failed: %+v %v", res, jerr)
+	}
+}
+
+func TestResizeImageRejectsBadInput(t *testing.T) {
+	useTestArtifacts(t)
+	src := testImage(t, 4, 4)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	cases := []struct {
+		name    string
+		payload ResizeImagePayload
+		code    ErrorCode
+	}{
+		{"no size", ResizeImagePayload{URL: src}, ErrInvalidPayload},
+		{"negative size", ResizeImagePayload{URL: src, Width: -1, Height: 4}, ErrInvalidPayload},
+		{"unknown filter", ResizeImagePayload{URL: src, Width: 2, Filter: "cubic"}, ErrInvalidPayload},
+		{"unknown format", ResizeImagePayload{URL: src, Width: 2, Format: "bmp"}, ErrInvalidPayload},
+		{"missing file", ResizeImagePayload{URL: src + ".missing", Width: 2}, ErrExecution},
+		{"not an image", ResizeImagePayload{URL: "data:text/plain;base64,aGVsbG8=", Width: 2}, ErrExecution},
+		{"http 404", ResizeImagePayload{URL: srv.URL + "/nope.png", Width: 2}, ErrExecution},
+		{"huge source", ResizeImagePayload{URL: hugePNG(t), Width: 2}, ErrInvalidPayload},
+	}
+	for _, c := range cases {
+		j := NewJob("bad", "ResizeImage", ResizeImageJob, 1, c.payload)
+		j.Execute()
+		if j.Status != Failed || j.Error == nil || j.Error.Code != c.code {
+			t.Errorf("%s: expected %s failure, got %s %v", c.name, c.code, j.Status, j.Error)
+		}
+	}
+}
+
+// hugePNG returns a data URL for a tiny PNG whose header declares a
+// 100000x100000 canvas
+func hugePNG(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// IHDR data starts after the 8-byte signature and 8-byte chunk header
+	binary.BigEndian.PutUint32(b[16:], 100000)
+	binary.BigEndian.PutUint32(b[20:], 100000)
+	binary.BigEndian.PutUint32(b[29:], crc32.ChecksumIEEE(b[12:29]))
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(b)
+}
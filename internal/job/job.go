@@ -1,8 +1,14 @@
 package job
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/tracing"
 )
 
 type Status string
@@ -24,18 +30,59 @@ const (
 )
 
 type Job struct {
-	ID           string
-	Name         string
-	Type         JobType
-	Status       Status
-	Priority     int
-	Payload      interface{}
-	Result       interface{}
+	ID         string
+	Name       string
+	Type       JobType
+	Status     Status
+	Priority   int
+	Payload    interface{}
+	PayloadRef string // artifact key the payload was offloaded to, see OffloadPayload
+	Result     interface{}
+	ResultRef  string // artifact key of an offloaded Result
+	// TraceParent is the W3C traceparent of the request that submitted the
+	// job; spans for scheduling and running it join that trace
+	TraceParent  string
+	traceCtx     context.Context
+	logger       *slog.Logger
+	Error        *JobError
 	resultMu     sync.Mutex
 	CreatedAt    time.Time
 	StartedAt    time.Time
 	CompletedAt  time.Time
 	ThreadDemand int
+	Tenant       string // who submitted the job, for fair share and quotas
+	// MemoryMB and CustomResources are the rest of the job's resource
+	// demand, alongside ThreadDemand
+	MemoryMB        int
+	CustomResources map[string]int
+	// Constraints limits which workers the job may run on, nil means any
+	Constraints *Constraints
+	// ThreadOutcome is set when the scheduler had to change ThreadDemand
+	// because no worker was big enough
+	ThreadOutcome *ThreadOutcome
+	// Preemptible jobs may be stopped to make room for higher-priority work
+	// and run again from the start later
+	Preemptible bool
+	// Gang lets a splittable job run as shards on several workers at once
+	// when no single worker has the threads for it
+	Gang      bool
+	Attempts  int // times a worker has picked the job up
+	preempted atomic.Bool
+	// Progress is the latest progress the handler reported, replaced (never
+	// modified) on each update; read it with LatestProgress
+	Progress *Progress
+	progress progressState
+
+	run      *chunkRun     // the current attempt at a chunked job
+	presplit []interface{} // chunks handed to a gang shard
+	shard    bool          // partials are reduced by the gang's parent
+}
+
+// ThreadOutcome records how an oversized ThreadDemand was handled
+type ThreadOutcome struct {
+	Policy    string `json:"policy"`
+	Requested int    `json:"requested_threads"`
+	Granted   int    `json:"granted_threads"`
 }
 
 func NewJob(id, name string, jobType JobType, priority int, payload interface{}) *Job {
@@ -50,20 +97,197 @@ func NewJob(id, name string, jobType JobType, priority int, payload interface{})
 	}
 }
 
+// Demand is the job's full resource vector. Jobs always need at least one thread.
+func (j *Job) Demand() Resources {
+	threads := j.ThreadDemand
+	if threads < 1 {
+		threads = 1
+	}
+	return Resources{
+		Threads:  threads,
+		MemoryMB: j.MemoryMB,
+		Custom:   j.CustomResources,
+	}
+}
+
+// Fail marks the job as failed with a structured error. Any partial result is
+// dropped so it can't be mistaken for real output.
+func (j *Job) Fail(err *JobError) {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	j.fail(err)
+}
+
+func (j *Job) fail(err *JobError) {
+	j.Error = err
+	j.Result = nil
+	j.Status = Failed
+	j.CompletedAt = time.Now()
+}
+
+// FailOnce is the variant of Fail for use from chunk goroutines. Only the
+// first error is kept.
+func (j *Job) FailOnce(err *JobError) {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	if j.Error == nil {
+		j.fail(err)
+	}
+}
+
+// MarkRunning starts a new attempt at j. StartedAt keeps the time of the
+// first attempt.
+func (j *Job) MarkRunning() {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	j.Status = Running
+	j.Attempts++
+	if j.StartedAt.IsZero() {
+		j.StartedAt = time.Now()
+	}
+}
+
+// MarkCompleted marks j Completed unless it has already failed
+func (j *Job) MarkCompleted() {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	if j.Status == Failed {
+		return
+	}
+	j.Status = Completed
+	if j.CompletedAt.IsZero() {
+		j.CompletedAt = time.Now()
+	}
+}
+
+// CurrentStatus is j's status, safe to read while a worker is running it
+func (j *Job) CurrentStatus() Status {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	return j.Status
+}
+
+// Outcome is j's status and error, read together and safe to read while a
+// worker is running j
+func (j *Job) Outcome() (Status, *JobError) {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	return j.Status, j.Error
+}
+
+// AttemptCount is Attempts, safe to read while a worker is running j
+func (j *Job) AttemptCount() int {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	return j.Attempts
+}
+
+// Times is StartedAt and CompletedAt, safe to read while a worker is
+// running j
+func (j *Job) Times() (started, completed time.Time) {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	return j.StartedAt, j.CompletedAt
+}
+
+// MarkPlaced sets StartedAt to at unless j has already started
+func (j *Job) MarkPlaced(at time.Time) {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	if j.StartedAt.IsZero() {
+		j.StartedAt = at
+	}
+}
+
+// Preempt asks a running job to stop. Chunked handlers notice it between
+// chunks and ResizeImage between rows; the others only once they return,
+// when their result is dropped. The job is then re-queued by the scheduler.
+func (j *Job) Preempt() {
+	j.preempted.Store(true)
+}
+
+// Preempted reports whether Preempt was called since the job last started
+func (j *Job) Preempted() bool {
+	return j.preempted.Load()
+}
+
+// Requeue resets a preempted job so it can run again. Attempts is kept.
+func (j *Job) Requeue() {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	j.Status = Pending
+	j.Result = nil
+	j.Error = nil
+	j.StartedAt = time.Time{}
+	j.CompletedAt = time.Time{}
+	j.run = nil
+	j.traceCtx = nil
+	j.preempted.Store(false)
+	j.resetProgress()
+}
+
+// TraceContext is the context spans about j should be started from: the
+// running attempt's span while a worker has it, otherwise the submitting
+// request's span
+func (j *Job) TraceContext() context.Context {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	if j.traceCtx != nil {
+		return j.traceCtx
+	}
+	return tracing.Extract(j.TraceParent)
+}
+
+// SetLogger sets the logger j's handlers write to; the API uses one that
+// captures lines into the job's logs
+func (j *Job) SetLogger(l *slog.Logger) {
+	j.logger = l
+}
+
+// Log is j's logger, the default logger tagged with the job ID if none was
+// set
+func (j *Job) Log() *slog.Logger {
+	if j.logger != nil {
+		return j.logger
+	}
+	return slog.Default().With("job_id", j.ID)
+}
+
+// SetTraceContext makes ctx the parent of j's spans until it's cleared
+// with nil
+func (j *Job) SetTraceContext(ctx context.Context) {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	j.traceCtx = ctx
+}
+
 // probably want to abstract this more in the fututre, so we don't have to hard code job definition cases into here
 func (j *Job) Execute() {
 	// mark as running and set StartedAt if not set
+	j.resultMu.Lock()
 	j.Status = Running
 	if j.StartedAt.IsZero() {
 		j.StartedAt = time.Now()
 	}
+	j.resultMu.Unlock()
+	if err := j.LoadPayload(); err != nil {
+		j.Fail(err)
+		return
+	}
+	// Chunked jobs run all their chunks on this one thread
+	if j.Chunked() {
+		j.ExecuteChunk(0, 1)
+		if j.Preempted() {
+			return
+		}
+		j.MarkCompleted()
+		return
+	}
 	switch j.Type {
 	case AddNumbersJob:
 		payload, ok := j.Payload.(AddNumbersPayload)
 		if !ok {
-			j.Status = Failed
-			j.Result = AddNumbersResult{Sum: 0}
-			j.CompletedAt = time.Now()
+			j.Fail(invalidPayload(j.Type, j.Payload))
 			return
 		}
 		j.Result = AddNumbersResult{Sum: payload.X + payload.Y}
@@ -71,9 +295,7 @@ func (j *Job) Execute() {
 	case ReverseStringJob:
 		payload, ok := j.Payload.(ReverseStringPayload)
 		if !ok {
-			j.Status = Failed
-			j.Result = ReverseStringResult{Reversed: ""}
-			j.CompletedAt = time.Now()
+			j.Fail(invalidPayload(j.Type, j.Payload))
 			return
 		}
 		j.Result = ReverseStringResult{Reversed: reverse(payload.Text)}
@@ -81,59 +303,25 @@ func (j *Job) Execute() {
 	case ResizeImageJob:
 		payload, ok := j.Payload.(ResizeImagePayload)
 		if !ok {
-			j.Status = Failed
-			j.Result = ResizeImageResult{ResizedURL: ""}
-			j.CompletedAt = time.Now()
+			j.Fail(invalidPayload(j.Type, j.Payload))
 			return
 		}
-		resized := ResizeImage(payload.URL, payload.Width, payload.Height) // call helper
-		j.Result = ResizeImageResult{ResizedURL: resized}
-
-	case LargeArraySumJob:
-		// fallback if called single threaded
-		payload, ok := j.Payload.(LargeArraySumPayload)
-		if !ok {
-			j.Status = Failed
-			j.Result = LargeArraySumResult{Sum: 0}
-			j.CompletedAt = time.Now()
+		result, err := ResizeImage(j.Log(), j.ID, payload, j.ReportProgress, j.Preempted)
+		if j.Preempted() {
 			return
 		}
-		sum := 0
-		for _, v := range payload.Array {
-			sum += v
+		if err != nil {
+			j.Fail(err)
+			return
 		}
-		j.Result = LargeArraySumResult{Sum: sum}
+		j.Result = result
 
 	default:
-		j.Status = Failed
-		return
-	}
-	j.Status = Completed
-	j.CompletedAt = time.Now()
-}
-
-func (j *Job) ExecuteChunk(threadID, totalThreads int) {
-	if j.Type != LargeArraySumJob {
-		return // other jobs do nothing
-	}
-
-	payload, ok := j.Payload.(LargeArraySumPayload)
-	if !ok {
-		return
-	}
-	n := len(payload.Array)
-	if n == 0 || totalThreads <= 0 || threadID < 0 || threadID >= totalThreads {
-		return
-	}
-	// Use integer-math partitioning that works when totalThreads > n
-	start := threadID * n / totalThreads
-	end := (threadID + 1) * n / totalThreads
-	if start >= end {
+		j.Fail(NewJobError(ErrUnsupportedType, fmt.Sprintf("no handler for job type %q", j.Type), false))
 		return
 	}
-	localSum := 0
-	for i := start; i < end; i++ {
-		localSum += payload.Array[i]
+	if j.Preempted() {
+		return // the scheduler re-queues it, dropping the result
 	}
-	j.addPartialSum(localSum)
+	j.MarkCompleted()
 }
@@ -102,16 +102,6 @@ func TestReverseStringJobEmpty(t *testing.T) {
 	}
 }
 
-func TestResizeImageJobDummy(t *testing.T) {
-	payload := ResizeImagePayload{URL: "http://example.com/image.png", Width: 100, Height: 200}
-	job := NewJob("img1", "ResizeImage", ResizeImageJob, 1, payload)
-	job.Execute()
-	// Since we haven’t implemented actual resizing, just check job completes
-	if job.Status != Completed {
-		t.Errorf("ResizeImageJob: expected status Completed, got %s", job.Status)
-	}
-}
-
 func TestLargeArraySumJobEmptyArray(t *testing.T) {
 	payload := LargeArraySumPayload{Array: []int{}}
 	job := NewJob("arr1", "EmptyArraySum", LargeArraySumJob, 1, payload)
@@ -156,3 +146,158 @@ func TestLargeArraySumJobMultiThreadEdge(t *testing.T) {
 		t.Errorf("Multi-thread edge sum: expected %d, got %d", expected, result.Sum)
 	}
 }
+
+func TestAddNumbersJobInvalidPayload(t *testing.T) {
+	job := NewJob("bad1", "BadAdd", AddNumbersJob, 1, ReverseStringPayload{Text: "oops"})
+	job.Execute()
+	if job.Status != Failed {
+		t.Fatalf("expected status Failed, got %s", job.Status)
+	}
+	if job.Result != nil {
+		t.Errorf("expected no result on failure, got %v", job.Result)
+	}
+	if job.Error == nil || job.Error.Code != ErrInvalidPayload {
+		t.Errorf("expected %s error, got %+v", ErrInvalidPayload, job.Error)
+	}
+	if job.CompletedAt.IsZero() {
+		t.Errorf("expected CompletedAt to be set on failure")
+	}
+}
+
+func TestUnsupportedJobTypeFails(t *testing.T) {
+	job := NewJob("bad2", "Unknown", JobType("Nope"), 1, nil)
+	job.Execute()
+	if job.Status != Failed || job.Error == nil || job.Error.Code != ErrUnsupportedType {
+		t.Errorf("expected %s failure, got status %s error %+v", ErrUnsupportedType, job.Status, job.Error)
+	}
+}
+
+func TestResourcesFits(t *testing.T) {
+	capacity := Resources{Threads: 4, MemoryMB: 1024, Custom: map[string]int{"gpu-license": 1}}
+
+	cases := []struct {
+		name   string
+		demand Resources
+		fits   bool
+	}{
+		{"threads only", Resources{Threads: 4}, true},
+		{"too many threads", Resources{Threads: 5}, false},
+		{"too much memory", Resources{Threads: 1, MemoryMB: 2048}, false},
+		{"custom resource available", Resources{Threads: 1, Custom: map[string]int{"gpu-license": 1}}, true},
+		{"custom resource missing", Resources{Threads: 1, Custom: map[string]int{"fpga": 1}}, false},
+	}
+	for _, c := range cases {
+		if got := c.demand.Fits(capacity); got != c.fits {
+			t.Errorf("%s: expected Fits=%v, got %v (%s)", c.name, c.fits, got, c.demand.Shortfall(capacity))
+		}
+	}
+
+	left := capacity.Sub(Resources{Threads: 1, MemoryMB: 512, Custom: map[string]int{"gpu-license": 1}})
+	if left.Threads != 3 || left.MemoryMB != 512 || left.Custom["gpu-license"] != 0 {
+		t.Errorf("unexpected remaining capacity %s", left)
+	}
+}
+
+func TestConstraintsAllowsAndScore(t *testing.T) {
+	fast := map[string]string{"tier": "fast", "region": "a"}
+	slow := map[string]string{"tier": "slow", "region": "b"}
+
+	c := &Constraints{
+		NodeSelector: map[string]string{"region": "a"},
+		AntiAffinity: []Rule{{Key: "tier", Operator: OpIn, Values: []string{"slow"}}},
+	}
+	if ok, why := c.Allows(fast); !ok {
+		t.Errorf("expected fast worker to be allowed, got %q", why)
+	}
+	if ok, why := c.Allows(slow); ok || why == "" {
+		t.Errorf("expected slow worker to be rejected with a reason, got ok=%v reason=%q", ok, why)
+	}
+
+	soft := &Constraints{Affinity: []Rule{{Key: "tier", Operator: OpIn, Values: []string{"fast"}, Weight: 10}}}
+	if ok, _ := soft.Allows(slow); !ok {
+		t.Error("soft affinity should never reject a worker")
+	}
+	if soft.Score(fast) != 10 || soft.Score(slow) != 0 {
+		t.Errorf("unexpected scores fast=%d slow=%d", soft.Score(fast), soft.Score(slow))
+	}
+
+	var none *Constraints
+	if ok, _ := none.Allows(slow); !ok {
+		t.Error("nil constraints should allow any worker")
+	}
+
+	bad := &Constraints{Affinity: []Rule{{Key: "tier", Operator: OpIn}}}
+	if err := bad.Validate(); err == nil {
+		t.Error("expected In rule without values to be invalid")
+	}
+}
+
+func TestPreemptedJobStopsAndRequeues(t *testing.T) {
+	j := NewJob("1", "Sum", LargeArraySumJob, 1, LargeArraySumPayload{Array: []int{1, 2, 3}})
+	j.Preempt()
+	j.Execute()
+	if j.Status == Completed || j.Result != nil {
+		t.Fatalf("preempted job ran to completion: %s %v", j.Status, j.Result)
+	}
+
+	j.Attempts = 2
+	j.Requeue()
+	if j.Status != Pending || j.Preempted() || !j.StartedAt.IsZero() || j.Attempts != 2 {
+		t.Errorf("unexpected state after requeue: %s preempted=%v attempts=%d", j.Status, j.Preempted(), j.Attempts)
+	}
+}
+
+func TestPreemptedSingleStepJobDoesNotComplete(t *testing.T) {
+	j := NewJob("1", "Add", AddNumbersJob, 1, AddNumbersPayload{X: 1, Y: 2})
+	j.Preempt()
+	j.Execute()
+	if j.Status == Completed {
+		t.Fatal("preempted add_numbers job was marked Completed")
+	}
+}
+
+func TestSplitAndMergeLargeArraySum(t *testing.T) {
+	array := make([]int, 10)
+	for i := range array {
+		array[i] = i + 1
+	}
+	j := NewJob("1", "Sum", LargeArraySumJob, 1, LargeArraySumPayload{Array: array})
+	j.Tenant = "team-a"
+	j.Preemptible = true
+
+	shards, err := j.Split([]int{3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) != 2 || shards[0].ThreadDemand != 3 || shards[1].Tenant != "team-a" || !shards[1].Preemptible {
+		t.Fatalf("unexpected shards %+v", shards)
+	}
+	if n := len(shards[0].presplit); n != 3 {
+		t.Errorf("expected 3 of 5 chunks on the 3-thread shard, got %d", n)
+	}
+
+	for _, s := range shards {
+		s.Execute()
+	}
+	j.Merge(shards)
+	if j.Status != Completed || j.Result.(LargeArraySumResult).Sum != 55 {
+		t.Errorf("expected merged sum 55, got %s %v", j.Status, j.Result)
+	}
+
+	if _, err := NewJob("2", "Add", AddNumbersJob, 1, AddNumbersPayload{}).Split([]int{1, 1}); err == nil {
+		t.Error("expected add_numbers to be unsplittable")
+	}
+}
+
+func TestMergeFailsOnMissingShard(t *testing.T) {
+	j := NewJob("1", "Sum", LargeArraySumJob, 1, LargeArraySumPayload{Array: generateLargeArray(10)})
+	shards, err := j.Split([]int{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards[0].Execute()
+	j.Merge(shards)
+	if j.Status != Failed || j.Error == nil || j.Error.Code != ErrExecution || j.Result != nil {
+		t.Errorf("expected a partial gang to fail, got %s %v %v", j.Status, j.Error, j.Result)
+	}
+}
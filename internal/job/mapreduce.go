@@ -0,0 +1,224 @@
+package job
+
+import (
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/tracing"
+)
+
+// MapReduce describes how a job type runs as independent chunks on several
+// threads. Split cuts the payload into chunks, n being the number of threads
+// it will run on (a hint, handlers may return more or fewer chunks). Map
+// turns one chunk into a partial result; chunks are mapped concurrently.
+// Reduce combines the partials, in chunk order, into the job's Result.
+//
+// Returning an error from Map or Reduce fails the job with ErrExecution.
+type MapReduce[P, R any] struct {
+	Split  func(payload P, n int) []P
+	Map    func(chunk P) (R, error)
+	Reduce func(partials []R) (interface{}, error)
+}
+
+// chunkedHandler is a MapReduce with the types erased so handlers for
+// different payloads can share one registry
+type chunkedHandler interface {
+	split(j *Job, n int) ([]interface{}, bool)
+	mapChunk(chunk interface{}) (interface{}, error)
+	reduce(partials []interface{}) (interface{}, error)
+}
+
+func (mr MapReduce[P, R]) split(j *Job, n int) ([]interface{}, bool) {
+	payload, ok := j.Payload.(P)
+	if !ok {
+		return nil, false
+	}
+	chunks := mr.Split(payload, n)
+	out := make([]interface{}, len(chunks))
+	for i, c := range chunks {
+		out[i] = c
+	}
+	return out, true
+}
+
+func (mr MapReduce[P, R]) mapChunk(chunk interface{}) (interface{}, error) {
+	return mr.Map(chunk.(P))
+}
+
+func (mr MapReduce[P, R]) reduce(partials []interface{}) (interface{}, error) {
+	typed := make([]R, len(partials))
+	for i, p := range partials {
+		typed[i] = p.(R)
+	}
+	return mr.Reduce(typed)
+}
+
+// mapReducers holds the chunked job types. Register handlers before any job
+// runs; the map isn't guarded.
+var mapReducers = map[JobType]chunkedHandler{
+	LargeArraySumJob: largeArraySum,
+}
+
+// RegisterMapReduce makes jobs of type t run through mr, on as many threads
+// as they hold. P is also registered as the type's payload, see
+// RegisterPayload.
+func RegisterMapReduce[P, R any](t JobType, mr MapReduce[P, R]) {
+	mapReducers[t] = mr
+	RegisterPayload[P](t)
+}
+
+// Chunked reports whether j runs as map-reduce chunks. Other jobs run on a
+// single thread whatever their ThreadDemand.
+func (j *Job) Chunked() bool {
+	_, ok := mapReducers[j.Type]
+	return ok
+}
+
+// chunkRun is one attempt at running a chunked job
+type chunkRun struct {
+	chunks   []interface{}
+	partials []interface{}
+	next     atomic.Int64 // next chunk to hand out
+	done     atomic.Int64 // chunks mapped so far
+}
+
+// start returns the current run, splitting the payload on the first call.
+// created is set for the caller that did the split.
+func (j *Job) start(h chunkedHandler, totalThreads int) (run *chunkRun, created bool, ok bool) {
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	if j.run != nil {
+		return j.run, false, true
+	}
+	chunks := j.presplit
+	if chunks == nil {
+		if chunks, ok = h.split(j, max(totalThreads, 1)); !ok {
+			return nil, false, false
+		}
+	}
+	j.run = &chunkRun{chunks: chunks, partials: make([]interface{}, len(chunks))}
+	return j.run, true, true
+}
+
+// ExecuteChunk is run by each of a job's threads. The threads take chunks
+// one at a time until none are left, checking for preemption in between, and
+// whichever maps the last chunk reduces the partials into Result. Jobs that
+// aren't chunked do nothing here.
+func (j *Job) ExecuteChunk(threadID, totalThreads int) {
+	h, ok := mapReducers[j.Type]
+	if !ok {
+		return
+	}
+	_, span := tracing.Tracer.Start(j.TraceContext(), "job.execute_chunk", trace.WithAttributes(
+		attribute.String("job.id", j.ID),
+		attribute.Int("thread.id", threadID),
+		attribute.Int("thread.count", totalThreads),
+	))
+	mapped := 0
+	defer func() {
+		j.Log().Debug("chunk thread done", "thread", threadID, "chunks", mapped)
+		span.SetAttributes(attribute.Int("chunks.mapped", mapped))
+		if j.Preempted() {
+			span.AddEvent("preempted")
+		}
+		span.End()
+	}()
+	if err := j.LoadPayload(); err != nil {
+		j.FailOnce(err)
+		return
+	}
+	run, created, ok := j.start(h, totalThreads)
+	if !ok {
+		j.FailOnce(invalidPayload(j.Type, j.Payload))
+		return
+	}
+	if created {
+		j.Log().Debug("split into chunks", "chunks", len(run.chunks), "threads", totalThreads)
+		j.ReportProgress(0, int64(len(run.chunks)), "map")
+	}
+	if created && len(run.chunks) == 0 {
+		j.reduce(h, run)
+		return
+	}
+	for {
+		if j.Preempted() {
+			return
+		}
+		i := int(run.next.Add(1) - 1)
+		if i >= len(run.chunks) {
+			return
+		}
+		partial, err := h.mapChunk(run.chunks[i])
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			j.Log().Error("chunk failed", "chunk", i, "error", err)
+			j.FailOnce(NewJobError(ErrExecution, err.Error(), false))
+			return
+		}
+		mapped++
+		run.partials[i] = partial
+		done := run.done.Add(1)
+		j.advanceProgress(done, int64(len(run.chunks)))
+		if j.progress.parent != nil {
+			j.progress.parent.addProgress(1, "")
+		}
+		if int(done) == len(run.chunks) {
+			j.reduce(h, run)
+		}
+	}
+}
+
+// reduce sets Result from a finished run. Shards leave that to their gang's
+// parent, see Merge.
+func (j *Job) reduce(h chunkedHandler, run *chunkRun) {
+	if j.shard {
+		return
+	}
+	j.ReportProgress(int64(len(run.partials)), int64(len(run.partials)), "reduce")
+	result, err := h.reduce(run.partials)
+	if err != nil {
+		j.FailOnce(NewJobError(ErrExecution, err.Error(), false))
+		return
+	}
+	j.resultMu.Lock()
+	defer j.resultMu.Unlock()
+	if j.Error == nil {
+		j.Result = result
+	}
+}
+
+// largeArraySum adds up an array in chunks of at most sumChunkSize elements,
+// at least one per thread
+var largeArraySum = MapReduce[LargeArraySumPayload, int]{
+	Split: func(p LargeArraySumPayload, n int) []LargeArraySumPayload {
+		count := max(n, (len(p.Array)+sumChunkSize-1)/sumChunkSize)
+		count = max(min(count, len(p.Array)), 1)
+		chunks := make([]LargeArraySumPayload, count)
+		for i := range chunks {
+			chunks[i].Array = p.Array[i*len(p.Array)/count : (i+1)*len(p.Array)/count]
+		}
+		return chunks
+	},
+	Map: func(chunk LargeArraySumPayload) (int, error) {
+		sum := 0
+		for _, v := range chunk.Array {
+			sum += v
+		}
+		return sum, nil
+	},
+	Reduce: func(partials []int) (interface{}, error) {
+		sum := 0
+		for _, p := range partials {
+			sum += p
+		}
+		return LargeArraySumResult{Sum: sum}, nil
+	},
+}
+
+// sumChunkSize bounds how long a large_array_sum chunk runs between
+// preemption checks
+const sumChunkSize = 1 << 16
@@ -0,0 +1,98 @@
+package job
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const wordCountJob JobType = "WordCount"
+
+type wordCountPayload struct {
+	Lines []string
+}
+
+func init() {
+	RegisterMapReduce(wordCountJob, MapReduce[wordCountPayload, map[string]int]{
+		Split: func(p wordCountPayload, n int) []wordCountPayload {
+			chunks := make([]wordCountPayload, len(p.Lines))
+			for i, line := range p.Lines {
+				chunks[i].Lines = []string{line}
+			}
+			return chunks
+		},
+		Map: func(chunk wordCountPayload) (map[string]int, error) {
+			counts := make(map[string]int)
+			for _, line := range chunk.Lines {
+				if line == "boom" {
+					return nil, errors.New("bad line")
+				}
+				for _, w := range strings.Fields(line) {
+					counts[w]++
+				}
+			}
+			return counts, nil
+		},
+		Reduce: func(partials []map[string]int) (interface{}, error) {
+			total := make(map[string]int)
+			for _, p := range partials {
+				for w, n := range p {
+					total[w] += n
+				}
+			}
+			return total, nil
+		},
+	})
+}
+
+func runChunks(j *Job, threads int) {
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(threadID int) {
+			defer wg.Done()
+			j.ExecuteChunk(threadID, threads)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMapReduceRunsRegisteredType(t *testing.T) {
+	j := NewJob("wc", "WordCount", wordCountJob, 1, wordCountPayload{Lines: []string{"a b", "b c", "c c"}})
+	if !j.Chunked() {
+		t.Fatal("expected registered type to be chunked")
+	}
+	runChunks(j, 2)
+
+	counts := j.Result.(map[string]int)
+	if counts["a"] != 1 || counts["b"] != 2 || counts["c"] != 3 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+
+	// Same handler, single-threaded path
+	single := NewJob("wc2", "WordCount", wordCountJob, 1, wordCountPayload{Lines: []string{"x x"}})
+	single.Execute()
+	if single.Status != Completed || single.Result.(map[string]int)["x"] != 2 {
+		t.Errorf("expected single-threaded run to complete, got %s %v", single.Status, single.Result)
+	}
+}
+
+func TestMapReduceMapErrorFailsJob(t *testing.T) {
+	j := NewJob("wc", "WordCount", wordCountJob, 1, wordCountPayload{Lines: []string{"a", "boom", "b"}})
+	runChunks(j, 3)
+
+	if j.Status != Failed || j.Error == nil || j.Error.Code != ErrExecution {
+		t.Fatalf("expected %s failure, got %s %+v", ErrExecution, j.Status, j.Error)
+	}
+	if j.Result != nil {
+		t.Errorf("failed job kept a result: %v", j.Result)
+	}
+}
+
+func TestNonChunkedJobsAreNotChunked(t *testing.T) {
+	j := NewJob("add", "Add", AddNumbersJob, 1, AddNumbersPayload{X: 1, Y: 2})
+	if j.Chunked() {
+		t.Error("add_numbers has no map-reduce handler")
+	}
+}
@@ -10,9 +10,14 @@ type ReverseStringPayload struct {
 }
 
 type ResizeImagePayload struct {
-	URL    string
-	Width  int
-	Height int
+	URL    string // data:, http(s)://, file:// or a local path
+	Width  int    // 0 derives the width from Height and the source aspect ratio
+	Height int    // likewise for Height
+	// KeepAspect fits the image inside Width x Height instead of stretching it
+	KeepAspect bool   `json:"keep_aspect"`
+	Filter     string // nearest, bilinear (default) or lanczos
+	Format     string // png, jpeg or gif; defaults to the source format
+	Quality    int    // jpeg quality, 1-100
 }
 
 type LargeArraySumPayload struct {
@@ -0,0 +1,112 @@
+package job
+
+import (
+	"sync"
+	"time"
+)
+
+// Progress is how far a running job has got. Processed and Total count
+// whatever units the handler works in (chunks, rows, ...).
+type Progress struct {
+	Percent   float64   `json:"percent"`
+	Processed int64     `json:"processed"`
+	Total     int64     `json:"total"`
+	Stage     string    `json:"stage,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProgressFunc reports progress; an empty stage keeps the current one
+type ProgressFunc func(processed, total int64, stage string)
+
+// progressState is the bookkeeping behind Job.Progress
+type progressState struct {
+	mu       sync.Mutex
+	notify   func(*Job)
+	interval time.Duration
+	notified time.Time
+	parent   *Job // gang parent that shard progress adds up into
+}
+
+// OnProgress has fn called with j after progress updates, at most once per
+// interval. The API uses it to refresh the cached job.
+func (j *Job) OnProgress(interval time.Duration, fn func(*Job)) {
+	j.progress.mu.Lock()
+	defer j.progress.mu.Unlock()
+	j.progress.notify = fn
+	j.progress.interval = interval
+}
+
+// ReportProgress records that processed of total units are done. Handlers
+// may call it from several threads.
+func (j *Job) ReportProgress(processed, total int64, stage string) {
+	j.updateProgress(func(p *Progress) {
+		p.Processed, p.Total = processed, total
+		if stage != "" {
+			p.Stage = stage
+		}
+	})
+}
+
+// LatestProgress returns the last reported progress, nil if there's none
+func (j *Job) LatestProgress() *Progress {
+	j.progress.mu.Lock()
+	defer j.progress.mu.Unlock()
+	return j.Progress
+}
+
+// addProgress counts n more units done, keeping Total
+func (j *Job) addProgress(n int64, stage string) {
+	j.updateProgress(func(p *Progress) {
+		p.Processed = min(p.Processed+n, p.Total)
+		if stage != "" {
+			p.Stage = stage
+		}
+	})
+}
+
+// advanceProgress records processed of total units done unless more of the
+// same total were already reported. Threads publishing a shared counter can
+// arrive out of order; this keeps Processed from going backwards.
+func (j *Job) advanceProgress(processed, total int64) {
+	j.updateProgress(func(p *Progress) {
+		if p.Total == total {
+			processed = max(processed, p.Processed)
+		}
+		p.Processed, p.Total = processed, total
+	})
+}
+
+// updateProgress applies update to a copy of the current progress, so a
+// Progress that has been handed out never changes, then notifies if due
+func (j *Job) updateProgress(update func(p *Progress)) {
+	now := time.Now()
+	j.progress.mu.Lock()
+	var p Progress
+	if j.Progress != nil {
+		p = *j.Progress
+	}
+	update(&p)
+	p.Percent = 0
+	if p.Total > 0 {
+		p.Percent = float64(p.Processed) * 100 / float64(p.Total)
+	}
+	p.UpdatedAt = now
+	j.Progress = &p
+	notify := j.progress.notify
+	if notify != nil && now.Sub(j.progress.notified) >= j.progress.interval {
+		j.progress.notified = now
+	} else {
+		notify = nil
+	}
+	j.progress.mu.Unlock()
+	if notify != nil {
+		notify(j)
+	}
+}
+
+// resetProgress forgets the progress of an attempt that is starting over
+func (j *Job) resetProgress() {
+	j.progress.mu.Lock()
+	defer j.progress.mu.Unlock()
+	j.Progress = nil
+}
@@ -0,0 +1,90 @@
+package job
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChunkedJobProgressAddsUpAcrossThreads(t *testing.T) {
+	j := NewJob("1", "Sum", LargeArraySumJob, 1, LargeArraySumPayload{Array: generateLargeArray(4 * sumChunkSize)})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(threadID int) {
+			defer wg.Done()
+			j.ExecuteChunk(threadID, 2)
+		}(i)
+	}
+	wg.Wait()
+
+	p := j.LatestProgress()
+	if p == nil || p.Processed != 4 || p.Total != 4 || p.Percent != 100 || p.Stage != "reduce" {
+		t.Fatalf("unexpected progress %+v", p)
+	}
+
+	j.Requeue()
+	if j.LatestProgress() != nil {
+		t.Error("expected requeue to reset progress")
+	}
+}
+
+func TestChunkProgressNeverGoesBackwards(t *testing.T) {
+	j := NewJob("1", "Sum", LargeArraySumJob, 1, nil)
+	j.ReportProgress(0, 4, "map")
+	// The thread that counted the third chunk publishes after the fourth
+	j.advanceProgress(4, 4)
+	j.advanceProgress(3, 4)
+	if p := j.LatestProgress(); p.Processed != 4 || p.Percent != 100 || p.Stage != "map" {
+		t.Fatalf("expected progress to stay at 4 of 4, got %+v", p)
+	}
+	// A new total starts over
+	j.advanceProgress(1, 2)
+	if p := j.LatestProgress(); p.Processed != 1 || p.Total != 2 {
+		t.Errorf("expected 1 of 2, got %+v", p)
+	}
+}
+
+func TestShardProgressCountsTowardsParent(t *testing.T) {
+	j := NewJob("1", "Sum", LargeArraySumJob, 1, LargeArraySumPayload{Array: generateLargeArray(10)})
+	shards, err := j.Split([]int{3, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.LatestProgress() != nil {
+		t.Fatal("expected Split to leave the parent's progress alone")
+	}
+	j.StartShards(shards)
+	shards[0].Execute()
+	if p := j.LatestProgress(); p.Processed != 3 || p.Total != 5 || p.Percent != 60 {
+		t.Fatalf("expected 3 of 5 chunks after the first shard, got %+v", p)
+	}
+	shards[1].Execute()
+	j.Merge(shards)
+	if p := j.LatestProgress(); p.Processed != 5 || p.Percent != 100 {
+		t.Errorf("expected all chunks done, got %+v", p)
+	}
+}
+
+func TestOnProgressIsThrottled(t *testing.T) {
+	j := NewJob("1", "Add", AddNumbersJob, 1, AddNumbersPayload{})
+	var calls []int64
+	j.OnProgress(time.Hour, func(j *Job) {
+		calls = append(calls, j.LatestProgress().Processed)
+	})
+	for i := int64(1); i <= 10; i++ {
+		j.ReportProgress(i, 10, "work")
+	}
+	if len(calls) != 1 || calls[0] != 1 {
+		t.Errorf("expected a single notification for the first update, got %v", calls)
+	}
+	if p := j.LatestProgress(); p.Processed != 10 || p.Stage != "work" {
+		t.Errorf("expected the latest update to be kept, got %+v", p)
+	}
+
+	// An empty stage keeps the current one
+	j.ReportProgress(10, 10, "")
+	if p := j.LatestProgress(); p.Stage != "work" {
+		t.Errorf("expected stage to be kept, got %q", p.Stage)
+	}
+}
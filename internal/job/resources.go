@@ -0,0 +1,110 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Resources is a vector of schedulable resources. It's used both for what a
+// job asks for and for what a worker has.
+//
+// Custom resources (e.g. "gpu-license") are only available on workers that
+// list them. Workers that don't track memory drop the memory dimension from
+// the demand before comparing, see worker.DemandFor.
+type Resources struct {
+	Threads  int            `json:"threads"`
+	MemoryMB int            `json:"memory_mb,omitempty"`
+	Custom   map[string]int `json:"custom,omitempty"`
+}
+
+// Fits reports whether r fits inside avail
+func (r Resources) Fits(avail Resources) bool {
+	return r.Shortfall(avail) == ""
+}
+
+// Shortfall describes the first dimension in which r exceeds avail, or
+// returns "" if r fits.
+func (r Resources) Shortfall(avail Resources) string {
+	if r.Threads > avail.Threads {
+		return fmt.Sprintf("threads %d > %d", r.Threads, avail.Threads)
+	}
+	if r.MemoryMB > avail.MemoryMB {
+		return fmt.Sprintf("memory_mb %d > %d", r.MemoryMB, avail.MemoryMB)
+	}
+	for _, name := range sortedKeys(r.Custom) {
+		if r.Custom[name] > avail.Custom[name] {
+			return fmt.Sprintf("%s %d > %d", name, r.Custom[name], avail.Custom[name])
+		}
+	}
+	return ""
+}
+
+// Validate rejects negative amounts. Reserved, they would make a worker look
+// freer than it is.
+func (r Resources) Validate() error {
+	if r.Threads < 0 {
+		return fmt.Errorf("threads can't be negative, got %d", r.Threads)
+	}
+	if r.MemoryMB < 0 {
+		return fmt.Errorf("memory_mb can't be negative, got %d", r.MemoryMB)
+	}
+	for _, name := range sortedKeys(r.Custom) {
+		if r.Custom[name] < 0 {
+			return fmt.Errorf("resource %s can't be negative, got %d", name, r.Custom[name])
+		}
+	}
+	return nil
+}
+
+func (r Resources) Add(o Resources) Resources {
+	out := Resources{
+		Threads:  r.Threads + o.Threads,
+		MemoryMB: r.MemoryMB + o.MemoryMB,
+	}
+	out.Custom = mergeCustom(r.Custom, o.Custom, 1)
+	return out
+}
+
+func (r Resources) Sub(o Resources) Resources {
+	out := Resources{
+		Threads:  r.Threads - o.Threads,
+		MemoryMB: r.MemoryMB - o.MemoryMB,
+	}
+	out.Custom = mergeCustom(r.Custom, o.Custom, -1)
+	return out
+}
+
+func (r Resources) String() string {
+	parts := []string{fmt.Sprintf("threads=%d", r.Threads)}
+	if r.MemoryMB > 0 {
+		parts = append(parts, fmt.Sprintf("memory_mb=%d", r.MemoryMB))
+	}
+	for _, name := range sortedKeys(r.Custom) {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, r.Custom[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func mergeCustom(a, b map[string]int, sign int) map[string]int {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	out := make(map[string]int, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] += sign * v
+	}
+	return out
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -10,6 +10,11 @@ type ReverseStringResult struct {
 
 type ResizeImageResult struct {
 	ResizedURL string
+	Key        string // artifact store key
+	Width      int
+	Height     int
+	Bytes      int64
+	Format     string
 }
 
 type LargeArraySumResult struct {
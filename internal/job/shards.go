@@ -0,0 +1,84 @@
+package job
+
+import "fmt"
+
+// Splittable reports whether j can be split into shards that run on
+// different workers
+func (j *Job) Splittable() bool {
+	return j.Chunked()
+}
+
+// Split partitions j into one shard per entry in threads, each getting a
+// share of the chunks proportional to its threads. Shards inherit everything
+// the scheduler looks at except ThreadDemand. j itself is left alone until
+// the shards are placed and passed to StartShards.
+func (j *Job) Split(threads []int) ([]*Job, error) {
+	h, ok := mapReducers[j.Type]
+	if !ok {
+		return nil, fmt.Errorf("job %s of type %s can't be split", j.ID, j.Type)
+	}
+	if err := j.LoadPayload(); err != nil {
+		return nil, fmt.Errorf("job %s: %w", j.ID, err)
+	}
+	total := 0
+	for _, n := range threads {
+		total += n
+	}
+	chunks, ok := h.split(j, total)
+	if !ok {
+		return nil, fmt.Errorf("job %s: expected %s payload, got %T", j.ID, j.Type, j.Payload)
+	}
+	shards := make([]*Job, 0, len(threads))
+	start, done := 0, 0
+	for i, t := range threads {
+		done += t
+		end := done * len(chunks) / total
+		shard := NewJob(fmt.Sprintf("%s/shard-%d", j.ID, i), j.Name, j.Type, j.Priority, j.Payload)
+		shard.ThreadDemand = t
+		shard.Tenant = j.Tenant
+		shard.MemoryMB = j.MemoryMB
+		shard.CustomResources = j.CustomResources
+		shard.Constraints = j.Constraints
+		shard.Preemptible = j.Preemptible
+		shard.CreatedAt = j.CreatedAt
+		shard.TraceParent = j.TraceParent
+		shard.logger = j.logger
+		shard.progress.parent = j
+		shard.presplit = chunks[start:end:end]
+		shard.shard = true
+		shards = append(shards, shard)
+		start = end
+	}
+	return shards, nil
+}
+
+// StartShards marks j Running as the gang of shards Split made, with its
+// progress counting their chunks
+func (j *Job) StartShards(shards []*Job) {
+	total := 0
+	for _, shard := range shards {
+		total += len(shard.presplit)
+	}
+	j.ReportProgress(0, int64(total), "map")
+	j.MarkRunning()
+}
+
+// Merge reduces the shards' partial results into j once all of them have
+// finished. If any shard failed or didn't finish, j fails too: a result
+// missing some chunks isn't a result.
+func (j *Job) Merge(shards []*Job) {
+	var partials []interface{}
+	for _, shard := range shards {
+		if shard.Status == Failed {
+			j.Fail(shard.Error)
+			return
+		}
+		if shard.Status != Completed || shard.run == nil {
+			j.Fail(NewJobError(ErrExecution, fmt.Sprintf("shard %s did not finish", shard.ID), true))
+			return
+		}
+		partials = append(partials, shard.run.partials...)
+	}
+	j.reduce(mapReducers[j.Type], &chunkRun{partials: partials})
+	j.MarkCompleted()
+}
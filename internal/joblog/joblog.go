@@ -0,0 +1,240 @@
+// Package joblog captures the log lines written through a job's logger so
+// they can be served by the API and persisted to job_logs
+package joblog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one captured log line
+type Entry struct {
+	Seq     int            `json:"seq"` // position in the job's log, counting dropped lines
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// Buffer holds one job's log lines. Once it has max lines the oldest are
+// dropped; Seq keeps counting so followers can tell.
+type Buffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int // Seq of the next line
+	flushed int // lines before this Seq have been persisted
+	max     int
+	closed  bool
+	changed chan struct{} // closed and replaced whenever a line is added or the buffer closes
+}
+
+func NewBuffer(max int) *Buffer {
+	return &Buffer{max: max, changed: make(chan struct{})}
+}
+
+func (b *Buffer) append(e Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.Seq = b.next
+	b.next++
+	b.entries = append(b.entries, e)
+	if b.max > 0 && len(b.entries) > b.max {
+		b.entries = b.entries[len(b.entries)-b.max:]
+	}
+	b.notify()
+}
+
+// notify wakes followers; caller holds b.mu
+func (b *Buffer) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Close marks the job finished. Followers stop once they've read the rest.
+func (b *Buffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.notify()
+	}
+}
+
+// Tail returns the last n lines, or all of them if n <= 0, and the Seq to
+// follow from
+func (b *Buffer) Tail(n int) ([]Entry, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start := 0
+	if n > 0 && len(b.entries) > n {
+		start = len(b.entries) - n
+	}
+	return append([]Entry(nil), b.entries[start:]...), b.next
+}
+
+// Since returns the lines from seq on. If there are none yet, wait is closed
+// when that changes; done reports that the buffer is closed and drained.
+func (b *Buffer) Since(seq int) (lines []Entry, next int, wait <-chan struct{}, done bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.entries {
+		if e.Seq >= seq {
+			lines = append(lines, e)
+		}
+	}
+	return lines, b.next, b.changed, b.closed && len(lines) == 0
+}
+
+// Unflushed returns the lines not yet persisted and marks them persisted
+func (b *Buffer) Unflushed() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []Entry
+	for _, e := range b.entries {
+		if e.Seq >= b.flushed {
+			out = append(out, e)
+		}
+	}
+	b.flushed = b.next
+	return out
+}
+
+// Handler is a slog.Handler that records into a Buffer and passes records
+// on to next (the process log), if set
+type Handler struct {
+	buf    *Buffer
+	next   slog.Handler
+	level  slog.Leveler
+	attrs  []slog.Attr
+	prefix string // dotted group path for attributes
+}
+
+// NewHandler captures records at level and above into buf
+func NewHandler(buf *Buffer, next slog.Handler, level slog.Leveler) *Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &Handler{buf: buf, next: next, level: level}
+}
+
+func (h *Handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.level.Level() || (h.next != nil && h.next.Enabled(ctx, l))
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= h.level.Level() {
+		e := Entry{Time: r.Time, Level: r.Level.String(), Message: r.Message}
+		if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+			e.Attrs = make(map[string]any)
+			for _, a := range h.attrs {
+				addAttr(e.Attrs, "", a)
+			}
+			r.Attrs(func(a slog.Attr) bool {
+				addAttr(e.Attrs, h.prefix, a)
+				return true
+			})
+		}
+		h.buf.append(e)
+	}
+	if h.next != nil && h.next.Enabled(ctx, r.Level) {
+		return h.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+func addAttr(m map[string]any, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, g := range v.Group() {
+			addAttr(m, p, g)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	m[prefix+a.Key] = v.Any()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		if h.prefix != "" {
+			a.Key = h.prefix + a.Key
+		}
+		h2.attrs = append(h2.attrs, a)
+	}
+	if h.next != nil {
+		h2.next = h.next.WithAttrs(attrs)
+	}
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	if h.next != nil {
+		h2.next = h.next.WithGroup(name)
+	}
+	return &h2
+}
+
+// Store keeps the buffers of jobs this process has run
+type Store struct {
+	mu    sync.Mutex
+	bufs  map[string]*Buffer
+	max   int
+	level slog.Leveler
+}
+
+// NewStore keeps up to maxLines lines per job (0 for no limit), captured
+// at level and above
+func NewStore(maxLines int, level slog.Leveler) *Store {
+	return &Store{bufs: make(map[string]*Buffer), max: maxLines, level: level}
+}
+
+// Logger creates the buffer for job id and returns a logger that writes to
+// it and to base
+func (s *Store) Logger(id string, base *slog.Logger) *slog.Logger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf := NewBuffer(s.max)
+	s.bufs[id] = buf
+	return slog.New(NewHandler(buf, base.With("job_id", id).Handler(), s.level))
+}
+
+// Get returns job id's buffer, if this process has one
+func (s *Store) Get(id string) (*Buffer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.bufs[id]
+	return b, ok
+}
+
+// Remove forgets job id's buffer once its lines have been persisted
+func (s *Store) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bufs, id)
+}
+
+// ParseLevel maps "debug", "info", "warn" and "error" to a slog.Level,
+// defaulting to info
+func ParseLevel(s string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
@@ -0,0 +1,98 @@
+package joblog
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerCapturesAndForwards(t *testing.T) {
+	var out bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	s := NewStore(0, slog.LevelDebug)
+
+	log := s.Logger("j1", base)
+	log.Debug("splitting", "chunks", 4)
+	log.With("worker", "w1").WithGroup("img").Info("stored", "bytes", 10)
+
+	buf, ok := s.Get("j1")
+	if !ok {
+		t.Fatal("no buffer for j1")
+	}
+	lines, next := buf.Tail(0)
+	if next != 2 || len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %+v", lines)
+	}
+	if lines[0].Level != "DEBUG" || lines[0].Message != "splitting" || lines[0].Attrs["chunks"] != int64(4) {
+		t.Errorf("unexpected first line %+v", lines[0])
+	}
+	if lines[1].Attrs["worker"] != "w1" || lines[1].Attrs["img.bytes"] != int64(10) {
+		t.Errorf("unexpected attrs %+v", lines[1].Attrs)
+	}
+
+	// The process log only gets what its own level allows, tagged with the job
+	if strings.Contains(out.String(), "splitting") || !strings.Contains(out.String(), "job_id=j1") {
+		t.Errorf("unexpected process log output %q", out.String())
+	}
+}
+
+func TestBufferDropsOldestAndTails(t *testing.T) {
+	b := NewBuffer(3)
+	log := slog.New(NewHandler(b, nil, slog.LevelInfo))
+	for _, m := range []string{"a", "b", "c", "d", "e"} {
+		log.Info(m)
+	}
+	lines, next := b.Tail(2)
+	if next != 5 || len(lines) != 2 || lines[0].Message != "d" || lines[1].Seq != 4 {
+		t.Fatalf("unexpected tail %+v next %d", lines, next)
+	}
+	if all, _ := b.Tail(0); len(all) != 3 || all[0].Message != "c" {
+		t.Errorf("expected the last 3 lines kept, got %+v", all)
+	}
+	if got := b.Unflushed(); len(got) != 3 {
+		t.Errorf("expected 3 unflushed lines, got %d", len(got))
+	}
+	log.Info("f")
+	if got := b.Unflushed(); len(got) != 1 || got[0].Message != "f" {
+		t.Errorf("expected only the new line unflushed, got %+v", got)
+	}
+}
+
+func TestSinceWaitsForNewLinesUntilClosed(t *testing.T) {
+	b := NewBuffer(0)
+	log := slog.New(NewHandler(b, nil, slog.LevelInfo))
+	log.Info("first")
+
+	lines, next, _, done := b.Since(0)
+	if len(lines) != 1 || done {
+		t.Fatalf("expected the first line, got %+v done=%v", lines, done)
+	}
+	_, _, wait, _ := b.Since(next)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		log.Info("second")
+		b.Close()
+	}()
+	select {
+	case <-wait:
+	case <-time.After(time.Second):
+		t.Fatal("follower was never woken")
+	}
+	lines, next, _, _ = b.Since(next)
+	if len(lines) != 1 || lines[0].Message != "second" {
+		t.Fatalf("expected the second line, got %+v", lines)
+	}
+	// Give the Close a moment to land, then the follower is done
+	time.Sleep(20 * time.Millisecond)
+	if _, _, _, done := b.Since(next); !done {
+		t.Error("expected follow to finish once the buffer closed")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if ParseLevel("debug") != slog.LevelDebug || ParseLevel("WARN") != slog.LevelWarn || ParseLevel("bogus") != slog.LevelInfo {
+		t.Error("unexpected level parsing")
+	}
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	autoscaleDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "autoscale_decisions_total",
+		Help:      "Autoscaler decisions by action (up, down or hold) and reason.",
+	}, []string{"action", "reason"})
+	autoscaleWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "autoscale_workers",
+		Help:      "Workers added by the autoscaler and not yet removed.",
+	})
+	autoscaleCPU = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "autoscale_cpu_utilization",
+		Help:      "Process CPU use as a fraction of GOMAXPROCS at the last autoscaler check.",
+	})
+	autoscaleOldestWait = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "autoscale_oldest_wait_seconds",
+		Help:      "How long the oldest queued job had waited at the last autoscaler check.",
+	})
+)
+
+// AutoscaleDecision counts a scaling decision. Holds are counted only when
+// something held the autoscaler back from scaling.
+func AutoscaleDecision(action, reason string) {
+	autoscaleDecisions.WithLabelValues(action, reason).Inc()
+}
+
+// AutoscaleObserved records what the autoscaler saw on a check. cpu below 0
+// means it couldn't be measured and leaves the gauge alone.
+func AutoscaleObserved(workers int, cpu float64, oldestWait time.Duration) {
+	autoscaleWorkers.Set(float64(workers))
+	if cpu >= 0 {
+		autoscaleCPU.Set(cpu)
+	}
+	autoscaleOldestWait.Set(oldestWait.Seconds())
+}
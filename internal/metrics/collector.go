@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/scheduler"
+)
+
+var (
+	queueDepthDesc = prometheus.NewDesc(namespace+"_queue_depth",
+		"Jobs waiting to be placed, by priority.", []string{"priority"}, nil)
+	workerThreadsDesc = prometheus.NewDesc(namespace+"_worker_threads",
+		"Worker threads by state (busy or free).", []string{"worker", "state"}, nil)
+	workerQueuedDesc = prometheus.NewDesc(namespace+"_worker_queued_jobs",
+		"Jobs handed to a worker that haven't started yet.", []string{"worker"}, nil)
+)
+
+// stateCollector reads queue depth and worker load at scrape time, so the
+// numbers are never stale
+type stateCollector struct {
+	sched *scheduler.Scheduler
+}
+
+// RegisterState adds queue depth and per-worker thread gauges for s and its
+// workers to Registry
+func RegisterState(s *scheduler.Scheduler) {
+	Registry.MustRegister(&stateCollector{sched: s})
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+	ch <- workerThreadsDesc
+	ch <- workerQueuedDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	for priority, n := range c.sched.QueuedByPriority() {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n), strconv.Itoa(priority))
+	}
+	for _, w := range c.sched.Workers() {
+		free := w.AvailableThreads()
+		ch <- prometheus.MustNewConstMetric(workerThreadsDesc, prometheus.GaugeValue, float64(w.Capacity().Threads-free), w.ID, "busy")
+		ch <- prometheus.MustNewConstMetric(workerThreadsDesc, prometheus.GaugeValue, float64(free), w.ID, "free")
+		ch <- prometheus.MustNewConstMetric(workerQueuedDesc, prometheus.GaugeValue, float64(w.Queued()), w.ID)
+	}
+}
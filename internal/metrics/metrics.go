@@ -0,0 +1,99 @@
+// Package metrics exposes the scheduler's Prometheus metrics on /metrics
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+const namespace = "jobscheduler"
+
+// Registry holds everything served by Handler. Go runtime and process
+// metrics are included.
+var Registry = prometheus.NewRegistry()
+
+// Buckets for job queue and execution times, 5ms to about 20 minutes
+var jobBuckets = prometheus.ExponentialBuckets(0.005, 4, 10)
+
+var (
+	jobsSubmitted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_submitted_total",
+		Help:      "Jobs accepted by POST /jobs.",
+	}, []string{"type"})
+	jobsCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_completed_total",
+		Help:      "Jobs that finished successfully.",
+	}, []string{"type"})
+	jobsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "jobs_failed_total",
+		Help:      "Jobs that failed, by error code.",
+	}, []string{"type", "code"})
+	queueTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_queue_seconds",
+		Help:      "Time from submission until the scheduler placed the job on a worker.",
+		Buckets:   jobBuckets,
+	}, []string{"type"})
+	execTime = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_execution_seconds",
+		Help:      "Time from start to completion of finished jobs.",
+		Buckets:   jobBuckets,
+	}, []string{"type", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		jobsSubmitted, jobsCompleted, jobsFailed, queueTime, execTime,
+		httpRequests, httpDuration, dbDuration, redisDuration,
+		autoscaleDecisions, autoscaleWorkers, autoscaleCPU, autoscaleOldestWait,
+	)
+}
+
+// Handler serves Registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// JobSubmitted counts a job accepted by the scheduler
+func JobSubmitted(j *job.Job) {
+	jobsSubmitted.WithLabelValues(string(j.Type)).Inc()
+}
+
+// JobFinished records a completed or failed job's outcome and timings.
+// Call it once per job.
+func JobFinished(j *job.Job) {
+	t := string(j.Type)
+	status, jerr := j.Outcome()
+	switch status {
+	case job.Completed:
+		jobsCompleted.WithLabelValues(t).Inc()
+	case job.Failed:
+		code := "unknown"
+		if jerr != nil {
+			code = string(jerr.Code)
+		}
+		jobsFailed.WithLabelValues(t, code).Inc()
+	default:
+		return
+	}
+	// Jobs rejected before they ran have no start time
+	started, completed := j.Times()
+	if started.IsZero() {
+		return
+	}
+	queueTime.WithLabelValues(t).Observe(started.Sub(j.CreatedAt).Seconds())
+	if !completed.IsZero() {
+		execTime.WithLabelValues(t, string(status)).Observe(completed.Sub(started).Seconds())
+	}
+}
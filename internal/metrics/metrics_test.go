@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/scheduler"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+func TestJobFinishedCountsOutcomes(t *testing.T) {
+	now := time.Now()
+	ok := job.NewJob("m1", "Add", job.AddNumbersJob, 1, nil)
+	ok.Status = job.Completed
+	ok.CreatedAt, ok.StartedAt, ok.CompletedAt = now, now.Add(time.Second), now.Add(3*time.Second)
+
+	bad := job.NewJob("m2", "Add", job.AddNumbersJob, 1, nil)
+	bad.Fail(job.NewJobError(job.ErrInvalidPayload, "nope", false))
+
+	completed := testutil.ToFloat64(jobsCompleted.WithLabelValues("AddNumbers"))
+	failed := testutil.ToFloat64(jobsFailed.WithLabelValues("AddNumbers", "INVALID_PAYLOAD"))
+	JobSubmitted(ok)
+	JobFinished(ok)
+	JobFinished(bad)
+
+	if got := testutil.ToFloat64(jobsCompleted.WithLabelValues("AddNumbers")); got != completed+1 {
+		t.Errorf("expected completed to go up by one, got %v -> %v", completed, got)
+	}
+	if got := testutil.ToFloat64(jobsFailed.WithLabelValues("AddNumbers", "INVALID_PAYLOAD")); got != failed+1 {
+		t.Errorf("expected failed to go up by one, got %v -> %v", failed, got)
+	}
+	// Only the job that ran has timings
+	if n := testutil.CollectAndCount(queueTime); n != 1 {
+		t.Errorf("expected one queue time series, got %d", n)
+	}
+}
+
+func TestStateCollectorReportsQueueAndThreads(t *testing.T) {
+	w := worker.NewWorker("w1", 4)
+	s := scheduler.NewScheduler([]*worker.Worker{w})
+	for i := 0; i < 3; i++ {
+		j := job.NewJob("q", "Add", job.AddNumbersJob, 5, job.AddNumbersPayload{})
+		if err := s.Submit(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &stateCollector{sched: s}
+	want := `
+# HELP jobscheduler_queue_depth Jobs waiting to be placed, by priority.
+# TYPE jobscheduler_queue_depth gauge
+jobscheduler_queue_depth{priority="5"} 3
+# HELP jobscheduler_worker_threads Worker threads by state (busy or free).
+# TYPE jobscheduler_worker_threads gauge
+jobscheduler_worker_threads{state="busy",worker="w1"} 0
+jobscheduler_worker_threads{state="free",worker="w1"} 4
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), "jobscheduler_queue_depth", "jobscheduler_worker_threads"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGinMiddlewareLabelsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Gin())
+	r.GET("/jobs/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	r.GET("/metrics", gin.WrapH(Handler()))
+
+	for _, id := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/jobs/"+id, nil))
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/jobs/:id", "404")); got != 2 {
+		t.Errorf("expected 2 requests on /jobs/:id, got %v", got)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `jobscheduler_http_requests_total{method="GET",route="/jobs/:id",status="404"} 2`) {
+		t.Errorf("metrics output is missing the request counter:\n%s", rec.Body.String())
+	}
+}
+
+func TestSQLOperation(t *testing.T) {
+	if got := sqlOperation("\n\t       INSERT INTO jobs ..."); got != "insert" {
+		t.Errorf("expected insert, got %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// Most Postgres and Redis calls here take well under a millisecond
+var storeBuckets = prometheus.ExponentialBuckets(0.0001, 4, 10)
+
+var (
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "postgres_query_seconds",
+		Help:      "Postgres query latency by statement kind.",
+		Buckets:   storeBuckets,
+	}, []string{"operation", "status"})
+	redisDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "redis_command_seconds",
+		Help:      "Redis command latency by command.",
+		Buckets:   storeBuckets,
+	}, []string{"command", "status"})
+)
+
+func errLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// PgxTracer times every query on a pgx connection. Set it as
+// ConnConfig.Tracer.
+type PgxTracer struct{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	at        time.Time
+	operation string
+}
+
+func (PgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryStartKey{}, queryStart{at: time.Now(), operation: sqlOperation(data.SQL)})
+}
+
+func (PgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	start, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	dbDuration.WithLabelValues(start.operation, errLabel(data.Err)).Observe(time.Since(start.at).Seconds())
+}
+
+// sqlOperation is the statement's leading keyword, e.g. "select"
+func sqlOperation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return strings.ToLower(fields[0])
+}
+
+// RedisHook times every command on a go-redis client. Add it with
+// client.AddHook.
+type RedisHook struct{}
+
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		// A miss on GET is a normal answer, not a failure
+		status := errLabel(err)
+		if err == redis.Nil {
+			status = "ok"
+		}
+		redisDuration.WithLabelValues(cmd.Name(), status).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		redisDuration.WithLabelValues("pipeline", errLabel(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// reservation holds a worker for the job at the head of the queue when no
+// worker has room for it yet. Until then other jobs may only backfill that
+// worker if they are expected to finish before at, or if they fit in what
+// will be left over once the head job starts.
+type reservation struct {
+	job    *job.Job
+	worker *worker.Worker
+	at     time.Time     // when enough should be free, zero if unknown
+	extra  job.Resources // spare once the head job has what it needs at at
+}
+
+// reserve works out the reservation for this pass, or returns nil if
+// backfilling is off or the head job can be placed right now. The head job
+// is the first one the placement loop would look at. Caller holds s.mu.
+func (s *Scheduler) reserve(order []*tenant, now time.Time) *reservation {
+	if !s.cfg.Backfill {
+		return nil
+	}
+	var head *job.Job
+	for _, t := range order {
+		for _, i := range s.policy.Pick(t.queue) {
+			if j := t.queue[i]; t.withinThreadQuota(j.Demand().Threads) {
+				head = j
+				break
+			}
+		}
+		if head != nil {
+			break
+		}
+	}
+	if head == nil || choose(s.policy, s.workers, head, nil) != nil {
+		return nil
+	}
+
+	var best *reservation
+	for _, w := range s.workers {
+		if w.State() != worker.Active || placementReason(w, head, false) != "" {
+			continue
+		}
+		r := s.shadow(head, w, now)
+		switch {
+		case best == nil:
+			best = r
+		case r.at.IsZero():
+		case best.at.IsZero() || r.at.Before(best.at):
+			best = r
+		}
+	}
+	return best
+}
+
+// shadow estimates when enough of w frees up for head, assuming running jobs
+// finish in order of their expected end; caller holds s.mu
+func (s *Scheduler) shadow(head *job.Job, w *worker.Worker, now time.Time) *reservation {
+	type ending struct {
+		at   time.Time
+		held job.Resources
+	}
+	var ends []ending
+	for j, p := range s.holding {
+		if p.worker != w {
+			continue
+		}
+		d, ok := s.cfg.History.Estimate(j.Type)
+		if !ok {
+			continue // no idea when it ends, don't count on it
+		}
+		ends = append(ends, ending{at: maxTime(j.StartedAt.Add(d), now), held: p.held})
+	}
+	sort.Slice(ends, func(a, b int) bool { return ends[a].at.Before(ends[b].at) })
+
+	r := &reservation{job: head, worker: w}
+	need := w.DemandFor(head)
+	free := w.Available()
+	for _, e := range ends {
+		free = free.Add(e.held)
+		if need.Fits(free) {
+			r.at = e.at
+			r.extra = free.Sub(need)
+			break
+		}
+	}
+	return r
+}
+
+// blocks reports whether j has to keep off the reserved worker because it
+// could delay the head job
+func (r *reservation) blocks(s *Scheduler, j *job.Job, now time.Time) bool {
+	if r == nil || j == r.job {
+		return false
+	}
+	if r.at.IsZero() {
+		return true // no telling when the head job can start
+	}
+	if d, ok := s.cfg.History.Estimate(j.Type); ok && !now.Add(d).After(r.at) {
+		return false // done before the head job needs the room
+	}
+	return !r.worker.DemandFor(j).Fits(r.extra)
+}
+
+// workersFor lists the workers j may be placed on this pass
+func (r *reservation) workersFor(s *Scheduler, j *job.Job, now time.Time) []*worker.Worker {
+	if !r.blocks(s, j, now) {
+		return s.workers
+	}
+	out := make([]*worker.Worker, 0, len(s.workers)-1)
+	for _, w := range s.workers {
+		if w != r.worker {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+func backfillScheduler(history *RuntimeHistory, workers ...*worker.Worker) *Scheduler {
+	cfg := DefaultConfig()
+	cfg.Backfill = true
+	cfg.History = history
+	return NewSchedulerWithConfig(workers, cfg)
+}
+
+func seededHistory() *RuntimeHistory {
+	h := NewRuntimeHistory()
+	h.Seed(map[job.JobType]time.Duration{
+		job.LargeArraySumJob: 10 * time.Second,
+		job.AddNumbersJob:    time.Second,
+		job.ReverseStringJob: time.Hour,
+	})
+	return h
+}
+
+func currentReservation(s *Scheduler) *reservation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reserve(s.tenantsByShare(), time.Now())
+}
+
+func TestBackfillOnlyLetsShortJobsIntoReservedWorker(t *testing.T) {
+	w := worker.NewWorker("w1", 4)
+	s := backfillScheduler(seededHistory(), w)
+
+	busy := job.NewJob("busy", "Sum", job.LargeArraySumJob, 5, job.LargeArraySumPayload{Array: []int{1}})
+	busy.ThreadDemand = 3
+	running(t, s, w, busy, 2*time.Second) // expected to end in about 8s
+
+	head := job.NewJob("head", "Sum", job.LargeArraySumJob, 10, job.LargeArraySumPayload{Array: []int{1}})
+	head.ThreadDemand = 4
+	short := job.NewJob("short", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	long := job.NewJob("long", "Reverse", job.ReverseStringJob, 1, job.ReverseStringPayload{Text: "abc"})
+	for _, j := range []*job.Job{head, short, long} {
+		if err := s.Submit(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := currentReservation(s)
+	if r == nil || r.job != head || r.worker != w {
+		t.Fatalf("expected w1 reserved for head, got %+v", r)
+	}
+	if until := time.Until(r.at); until < 7*time.Second || until > 9*time.Second {
+		t.Errorf("expected the reservation in about 8s, got %v", until)
+	}
+	now := time.Now()
+	if r.blocks(s, short, now) {
+		t.Error("short job finishes before the reservation and should backfill")
+	}
+	if !r.blocks(s, long, now) {
+		t.Error("long job would delay the head job and should be held back")
+	}
+}
+
+func TestBackfillUsesCapacityLeftOverAfterReservation(t *testing.T) {
+	w := worker.NewWorker("w1", 4)
+	s := backfillScheduler(seededHistory(), w)
+
+	busy := job.NewJob("busy", "Sum", job.LargeArraySumJob, 5, job.LargeArraySumPayload{Array: []int{1}})
+	busy.ThreadDemand = 3
+	running(t, s, w, busy, 0)
+
+	head := job.NewJob("head", "Sum", job.LargeArraySumJob, 10, job.LargeArraySumPayload{Array: []int{1}})
+	head.ThreadDemand = 3
+	long := job.NewJob("long", "Reverse", job.ReverseStringJob, 1, job.ReverseStringPayload{Text: "abc"})
+	s.Submit(head)
+	s.Submit(long)
+
+	// 1 thread is free now; 4 will be once busy ends, head only needs 3, so
+	// one thread is spare even after the head job starts
+	r := currentReservation(s)
+	if r == nil {
+		t.Fatal("expected a reservation")
+	}
+	if r.blocks(s, long, time.Now()) {
+		t.Errorf("long job fits in the spare thread %v and should backfill", r.extra)
+	}
+}
+
+func TestBackfillHoldsWorkerWhenRuntimeUnknown(t *testing.T) {
+	w1 := worker.NewWorker("w1", 4)
+	w2 := worker.NewWorker("w2", 2)
+	s := backfillScheduler(NewRuntimeHistory(), w1, w2)
+
+	busy := job.NewJob("busy", "Sum", job.LargeArraySumJob, 5, job.LargeArraySumPayload{Array: []int{1}})
+	busy.ThreadDemand = 3
+	running(t, s, w1, busy, time.Second)
+
+	head := job.NewJob("head", "Sum", job.LargeArraySumJob, 10, job.LargeArraySumPayload{Array: []int{1}})
+	head.ThreadDemand = 4
+	small := job.NewJob("small", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	s.Submit(head)
+	s.Submit(small)
+
+	r := currentReservation(s)
+	if r == nil || r.worker != w1 {
+		t.Fatalf("expected w1 reserved, got %+v", r)
+	}
+	got := r.workersFor(s, small, time.Now())
+	if len(got) != 1 || got[0] != w2 {
+		t.Errorf("expected small job limited to w2, got %d workers", len(got))
+	}
+}
+
+func TestBackfillOffByDefault(t *testing.T) {
+	w := worker.NewWorker("w1", 4)
+	s := NewScheduler([]*worker.Worker{w})
+	busy := job.NewJob("busy", "Sum", job.LargeArraySumJob, 5, job.LargeArraySumPayload{Array: []int{1}})
+	busy.ThreadDemand = 3
+	running(t, s, w, busy, 0)
+	head := job.NewJob("head", "Sum", job.LargeArraySumJob, 10, job.LargeArraySumPayload{Array: []int{1}})
+	head.ThreadDemand = 4
+	s.Submit(head)
+
+	if r := currentReservation(s); r != nil {
+		t.Errorf("expected no reservation, got %+v", r)
+	}
+}
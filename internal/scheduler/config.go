@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+)
+
+// OversizePolicy decides what happens to a job whose ThreadDemand is larger
+// than every worker
+type OversizePolicy string
+
+const (
+	// OversizeReject refuses the job at submit time
+	OversizeReject OversizePolicy = "reject"
+	// OversizeClamp runs the job on as many threads as the largest worker has
+	OversizeClamp OversizePolicy = "clamp"
+	// OversizeDowngrade runs the job single-threaded
+	OversizeDowngrade OversizePolicy = "downgrade"
+)
+
+func ParseOversizePolicy(s string) (OversizePolicy, error) {
+	switch p := OversizePolicy(s); p {
+	case OversizeReject, OversizeClamp, OversizeDowngrade:
+		return p, nil
+	case "":
+		return OversizeReject, nil
+	default:
+		return "", fmt.Errorf("unknown oversize policy %q (want reject, clamp or downgrade)", s)
+	}
+}
+
+// Config holds the tunable parts of a Scheduler
+type Config struct {
+	OversizePolicy OversizePolicy
+	// Policy orders each tenant's queue and places jobs, nil means BestFit
+	Policy Policy
+	// Tenants holds per-tenant weights and quotas; tenants not listed get
+	// DefaultTenant
+	Tenants       map[string]TenantConfig
+	DefaultTenant TenantConfig
+	// Preemption lets a blocked job stop lower-priority preemptible jobs
+	// that have been running for at least PreemptionGracePeriod
+	Preemption            bool
+	PreemptionGracePeriod time.Duration
+	// Backfill holds capacity for a blocked head job and only lets other
+	// jobs into it if History expects them to finish in time
+	Backfill bool
+	History  *RuntimeHistory
+	// WorkStealing lets idle workers take queued jobs their peers can't
+	// start yet, including workers added later
+	WorkStealing bool
+}
+
+func DefaultConfig() Config {
+	return Config{
+		OversizePolicy: OversizeReject,
+		Policy:         BestFit{},
+		DefaultTenant:  TenantConfig{Weight: 1},
+	}
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"container/heap"
+	"errors"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+// ErrDraining is returned by Submit once Drain has been called
+var ErrDraining = errors.New("scheduler is draining and not accepting jobs")
+
+// Drain stops the scheduler accepting and placing jobs and returns the
+// queued ones, taking them out of their queues.
+// Jobs already placed keep running; preempted ones that come back are
+// queued again and returned by the next Drain call.
+func (s *Scheduler) Drain() []*job.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = true
+	var out []*job.Job
+	for _, t := range s.tenants {
+		for t.queue.Len() > 0 {
+			out = append(out, heap.Pop(&t.queue).(*job.Job))
+		}
+	}
+	return out
+}
+
+// Running returns the jobs placed on a worker that haven't finished. A
+// gang is reported once, as its parent.
+func (s *Scheduler) Running() []*job.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[*job.Job]bool)
+	var out []*job.Job
+	for j := range s.holding {
+		if g, ok := s.gangs[j]; ok {
+			j = g.parent
+		}
+		if !seen[j] {
+			seen[j] = true
+			out = append(out, j)
+		}
+	}
+	return out
+}
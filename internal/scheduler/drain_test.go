@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+func TestDrainReturnsQueuedJobsAndRejectsNewOnes(t *testing.T) {
+	s, w := preemptionScheduler(0)
+	placed := lowPriorityJob("placed", true)
+	running(t, s, w, placed, time.Minute)
+	queued := []*job.Job{lowPriorityJob("q1", false), lowPriorityJob("q2", false)}
+	queued[1].Tenant = "team-a"
+	for _, j := range queued {
+		if err := s.Submit(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	drained := s.Drain()
+	if len(drained) != 2 {
+		t.Fatalf("expected both queued jobs, got %d", len(drained))
+	}
+	if q := s.QueuedByPriority(); len(q) != 0 {
+		t.Errorf("expected empty queues after drain, got %v", q)
+	}
+	if r := s.Running(); len(r) != 1 || r[0] != placed {
+		t.Errorf("expected the placed job to keep running, got %v", r)
+	}
+	if err := s.Submit(lowPriorityJob("late", false)); !errors.Is(err, ErrDraining) {
+		t.Errorf("expected ErrDraining, got %v", err)
+	}
+
+	// A job preempted during the drain is handed back by the next call
+	placed.Preempt()
+	w.CancelReservation(placed)
+	if again := s.Drain(); len(again) != 1 || again[0] != placed {
+		t.Errorf("expected the requeued job, got %v", again)
+	}
+	if len(s.Running()) != 0 {
+		t.Error("expected nothing running")
+	}
+}
@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// gang tracks a job split across several workers until every shard is done
+type gang struct {
+	parent *job.Job
+	shards []*job.Job
+	done   int
+}
+
+// dispatch is a job handed to a worker's JobQueue
+type dispatch struct {
+	worker *worker.Worker
+	job    *job.Job
+}
+
+// gangMember reports whether w could host a shard of j, ignoring threads,
+// when idle or, with now set, right now
+func gangMember(w *worker.Worker, j *job.Job, now bool) bool {
+	if ok, _ := j.Constraints.Allows(w.Labels); !ok {
+		return false
+	}
+	avail := w.Capacity()
+	if now {
+		if w.State() != worker.Active {
+			return false
+		}
+		avail = w.Available()
+	}
+	d := w.DemandFor(j)
+	d.Threads = 1
+	return d.Fits(avail)
+}
+
+// gangCapacity is how many threads j could get across every worker that
+// can host a shard of it; caller holds s.mu
+func (s *Scheduler) gangCapacity(j *job.Job) int {
+	total := 0
+	for _, w := range s.workers {
+		if gangMember(w, j, false) {
+			total += w.Capacity().Threads
+		}
+	}
+	return total
+}
+
+// placeGang splits j over the workers with the most free threads and
+// reserves every shard, or reserves nothing if they don't add up to j's
+// demand. Caller holds s.mu.
+func (s *Scheduler) placeGang(t *tenant, i int, j *job.Job, workers []*worker.Worker) []dispatch {
+	var members []*worker.Worker
+	for _, w := range workers {
+		if gangMember(w, j, true) {
+			members = append(members, w)
+		}
+	}
+	sort.SliceStable(members, func(a, b int) bool {
+		return members[a].AvailableThreads() > members[b].AvailableThreads()
+	})
+
+	need := j.Demand().Threads
+	var threads []int
+	for _, w := range members {
+		if need == 0 {
+			break
+		}
+		n := min(need, w.AvailableThreads())
+		threads = append(threads, n)
+		need -= n
+	}
+	if need > 0 || len(threads) < 2 {
+		return nil
+	}
+	members = members[:len(threads)]
+
+	shards, err := j.Split(threads)
+	if err != nil {
+		return nil
+	}
+	for k, shard := range shards {
+		if !members[k].TryReserve(shard) {
+			// Hand back what was taken. The release callback takes s.mu,
+			// so it can't run here.
+			go func(taken []*job.Job) {
+				for m, sh := range taken {
+					members[m].CancelReservation(sh)
+				}
+			}(shards[:k])
+			return nil
+		}
+	}
+
+	heap.Remove(&t.queue, i)
+	g := &gang{parent: j, shards: shards}
+	out := make([]dispatch, len(shards))
+	for k, shard := range shards {
+		s.hold(t, shard, members[k])
+		s.gangs[shard] = g
+		out[k] = dispatch{worker: members[k], job: shard}
+	}
+	j.Log().Info("placed gang", "shards", len(shards), "threads", threads)
+	j.StartShards(shards)
+	return out
+}
+
+// shardDone records a finished shard and, once the whole gang is done,
+// merges the results into the parent. A gang that was preempted puts the
+// parent back in its queue instead, to be split again. It reports whether
+// shard belonged to a gang; caller holds s.mu.
+func (s *Scheduler) shardDone(shard *job.Job) bool {
+	g, ok := s.gangs[shard]
+	if !ok {
+		return false
+	}
+	delete(s.gangs, shard)
+	g.done++
+	if g.done < len(g.shards) {
+		return true
+	}
+	for _, sh := range g.shards {
+		if st := sh.CurrentStatus(); sh.Preempted() && st != job.Completed && st != job.Failed {
+			g.parent.Requeue()
+			heap.Push(&s.tenantFor(tenantOf(g.parent)).queue, g.parent)
+			g.parent.Log().Info("requeued gang after preemption", "shards", len(g.shards), "attempts", g.parent.AttemptCount())
+			return true
+		}
+	}
+	g.parent.Merge(g.shards)
+	g.parent.Log().Info("merged gang shards", "shards", len(g.shards), "status", g.parent.CurrentStatus())
+	return true
+}
+
+// gangError explains why a gang job can't be admitted, or returns nil
+func (s *Scheduler) gangError(j *job.Job) error {
+	if !j.Splittable() {
+		return fmt.Errorf("%w (%s jobs can't be split across workers)", ErrUnschedulable, j.Type)
+	}
+	if c := s.gangCapacity(j); c < j.Demand().Threads {
+		return fmt.Errorf("%w (thread_demand %d exceeds the %d threads of every worker that could host a shard)", ErrUnschedulable, j.ThreadDemand, c)
+	}
+	return nil
+}
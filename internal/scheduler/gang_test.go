@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+func gangJob(id string, threads, size int) *job.Job {
+	array := make([]int, size)
+	for i := range array {
+		array[i] = i + 1
+	}
+	j := job.NewJob(id, "Sum", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: array})
+	j.ThreadDemand = threads
+	j.Gang = true
+	return j
+}
+
+func TestGangJobRunsAcrossWorkers(t *testing.T) {
+	workers := createTestWorkers() // 4 + 2 threads
+	s := NewScheduler(workers)
+	s.Run()
+	defer s.Stop()
+
+	j := gangJob("gang", 6, 1000)
+	if err := s.Submit(j); err != nil {
+		t.Fatal(err)
+	}
+	if !waitJobCompletion(j, time.Second) {
+		t.Fatal("gang job did not complete")
+	}
+	if sum := j.Result.(job.LargeArraySumResult).Sum; sum != 500500 {
+		t.Errorf("expected 500500, got %d", sum)
+	}
+	if n := j.AttemptCount(); n != 1 {
+		t.Errorf("expected one attempt for the whole gang, got %d", n)
+	}
+}
+
+func TestGangPlacementIsAllOrNothing(t *testing.T) {
+	w1 := worker.NewWorker("w1", 4)
+	w2 := worker.NewWorker("w2", 2)
+	s := NewScheduler([]*worker.Worker{w1, w2})
+	running(t, s, w2, job.NewJob("busy", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{}), 0)
+
+	j := gangJob("gang", 6, 10)
+	if err := s.Submit(j); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	tn := s.tenantFor(DefaultTenant)
+	got := s.placeGang(tn, 0, j, s.workers)
+	s.mu.Unlock()
+
+	if got != nil {
+		t.Fatalf("only 5 threads are free, expected no placement, got %d shards", len(got))
+	}
+	if w1.AvailableThreads() != 4 {
+		t.Errorf("a failed gang must not hold threads, w1 has %d free", w1.AvailableThreads())
+	}
+}
+
+func TestGangAdmission(t *testing.T) {
+	s := NewScheduler(createTestWorkers())
+	defer s.Stop()
+
+	tooBig := gangJob("big", 7, 10)
+	if err := s.Submit(tooBig); !errors.Is(err, ErrUnschedulable) {
+		t.Errorf("expected ErrUnschedulable for 7 threads over 6, got %v", err)
+	}
+	add := job.NewJob("add", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})
+	add.ThreadDemand = 6
+	add.Gang = true
+	if err := s.Submit(add); !errors.Is(err, ErrUnschedulable) {
+		t.Errorf("expected add_numbers to be refused as a gang, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// Health is a snapshot of the scheduler's worker loops
+type Health struct {
+	Stopped bool
+	Loops   int // worker loops still running
+	Workers int
+	// Responsive is false if the scheduler lock couldn't be taken in time,
+	// i.e. placement is wedged
+	Responsive bool
+	// Dispatching is how long each worker's loop has been blocked handing a
+	// job over, for loops that are
+	Dispatching map[string]time.Duration
+}
+
+// loopState is what Health reads about one worker loop
+type loopState struct {
+	dispatchSince atomic.Int64 // unix nanos the current hand-off started, 0 if none
+	removed       bool         // set by RemoveWorker under s.mu, the loop exits
+}
+
+// Health reports whether the worker loops are running and placement isn't
+// stuck, waiting at most timeout for the scheduler lock
+func (s *Scheduler) Health(timeout time.Duration) Health {
+	h := Health{Loops: int(s.running.Load())}
+	select {
+	case <-s.stopCh:
+		h.Stopped = true
+	default:
+	}
+
+	// Workers come and go, so the list is read under the lock too
+	var workers []*worker.Worker
+	var loops []*loopState
+	locked := make(chan struct{})
+	go func() {
+		s.mu.Lock()
+		for _, w := range s.workers {
+			workers = append(workers, w)
+			loops = append(loops, s.loops[w])
+		}
+		s.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		h.Responsive = true
+		h.Workers = len(workers)
+	case <-time.After(timeout):
+		return h
+	}
+
+	now := time.Now()
+	for i, w := range workers {
+		if since := loops[i].dispatchSince.Load(); since != 0 {
+			if h.Dispatching == nil {
+				h.Dispatching = make(map[string]time.Duration)
+			}
+			h.Dispatching[w.ID] = now.Sub(time.Unix(0, since))
+		}
+	}
+	return h
+}
+
+// Check returns why h isn't healthy, nil if it is. Loops blocked handing
+// over a job for longer than maxDispatch count as stuck, unless it's 0.
+func (h Health) Check(maxDispatch time.Duration) error {
+	var errs []error
+	if h.Stopped {
+		errs = append(errs, errors.New("scheduler stopped"))
+	}
+	if h.Loops < h.Workers {
+		errs = append(errs, fmt.Errorf("%d of %d worker loops running", h.Loops, h.Workers))
+	}
+	if !h.Responsive {
+		errs = append(errs, errors.New("scheduler lock not acquired in time"))
+	}
+	for id, d := range h.Dispatching {
+		if maxDispatch > 0 && d > maxDispatch {
+			errs = append(errs, fmt.Errorf("worker %s loop blocked handing over a job for %s", id, d.Round(time.Millisecond)))
+		}
+	}
+	return errors.Join(errs...)
+}
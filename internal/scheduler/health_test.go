@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+func TestHealthReportsLoopsAndStop(t *testing.T) {
+	s := NewScheduler(createTestWorkers())
+	s.Run()
+	h := s.Health(time.Second)
+	if err := h.Check(time.Second); err != nil || h.Loops != 2 {
+		t.Fatalf("expected a healthy scheduler with 2 loops, got %+v: %v", h, err)
+	}
+
+	s.Stop()
+	h = s.Health(time.Second)
+	if !h.Stopped || h.Loops != 0 || h.Check(time.Second) == nil {
+		t.Errorf("expected a stopped scheduler to be unhealthy, got %+v", h)
+	}
+}
+
+func TestHealthReportsBlockedDispatch(t *testing.T) {
+	// Never started and unbuffered, so the hand-off blocks
+	w := worker.NewWorkerWithQueueSize("w1", 1, 0)
+	s := NewScheduler([]*worker.Worker{w})
+	s.Run()
+	defer s.Stop()
+	if err := s.Submit(job.NewJob("1", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for s.Health(time.Second).Dispatching["w1"] < 20*time.Millisecond {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the w1 loop to show as blocked")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Health(time.Second).Check(10 * time.Millisecond); err == nil {
+		t.Error("expected a loop blocked past maxDispatch to be unhealthy")
+	}
+}
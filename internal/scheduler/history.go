@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+// historyWeight is how much a new observation moves the running estimate
+const historyWeight = 0.2
+
+// RuntimeHistory keeps a per job type estimate of execution time. It can be
+// seeded from the execution_time rows in job_metrics and is updated as jobs
+// finish.
+type RuntimeHistory struct {
+	mu        sync.RWMutex
+	estimates map[job.JobType]time.Duration
+}
+
+func NewRuntimeHistory() *RuntimeHistory {
+	return &RuntimeHistory{estimates: make(map[job.JobType]time.Duration)}
+}
+
+// Seed replaces the estimate for each given type, e.g. with averages loaded
+// from the database at startup
+func (h *RuntimeHistory) Seed(averages map[job.JobType]time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for t, d := range averages {
+		h.estimates[t] = d
+	}
+}
+
+// Observe folds a finished job's execution time into the estimate for its
+// type using an exponentially weighted moving average
+func (h *RuntimeHistory) Observe(t job.JobType, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prev, ok := h.estimates[t]
+	if !ok {
+		h.estimates[t] = d
+		return
+	}
+	h.estimates[t] = time.Duration(historyWeight*float64(d) + (1-historyWeight)*float64(prev))
+}
+
+// Estimate returns the expected execution time for a job type, and false if
+// nothing is known about it yet
+func (h *RuntimeHistory) Estimate(t job.JobType) (time.Duration, bool) {
+	if h == nil {
+		return 0, false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	d, ok := h.estimates[t]
+	return d, ok
+}
@@ -0,0 +1,84 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// fitScore is the capacity left on a worker after placing demand, averaged
+// over every dimension the worker tracks. Lower is a tighter fit. Resources
+// the job doesn't use count as leftover, so workers with scarce custom
+// resources are kept for jobs that need them.
+func fitScore(demand, free, capacity job.Resources) float64 {
+	score, dims := 0.0, 0
+	add := func(d, f, c int) {
+		if c > 0 {
+			score += float64(f-d) / float64(c)
+			dims++
+		}
+	}
+	add(demand.Threads, free.Threads, capacity.Threads)
+	add(demand.MemoryMB, free.MemoryMB, capacity.MemoryMB)
+	for name, c := range capacity.Custom {
+		add(demand.Custom[name], free.Custom[name], c)
+	}
+	if dims == 0 {
+		return 0
+	}
+	return score / float64(dims)
+}
+
+// placementReason says why j can't go on w, or returns "" if it can. With
+// now set it checks the worker's free resources, otherwise its full capacity.
+func placementReason(w *worker.Worker, j *job.Job, now bool) string {
+	if ok, why := j.Constraints.Allows(w.Labels); !ok {
+		return why
+	}
+	avail := w.Capacity()
+	if now {
+		if state := w.State(); state != worker.Active {
+			return "worker " + state.String()
+		}
+		avail = w.Available()
+	}
+	if short := w.DemandFor(j).Shortfall(avail); short != "" {
+		if now {
+			return "insufficient free " + short
+		}
+		return "insufficient capacity " + short
+	}
+	return ""
+}
+
+// choose returns the worker j should go on among those that can take it
+// right now, or nil if there are none. The highest policy score wins and
+// ties go to prefer.
+func choose(policy Policy, workers []*worker.Worker, j *job.Job, prefer *worker.Worker) *worker.Worker {
+	var best *worker.Worker
+	bestScore := 0.0
+	for _, w := range workers {
+		if placementReason(w, j, true) != "" {
+			continue
+		}
+		score := policy.Score(j, w)
+		if best == nil || score > bestScore || (score == bestScore && w == prefer) {
+			best, bestScore = w, score
+		}
+	}
+	return best
+}
+
+// unschedulableReason explains, per worker, why j can never be placed
+func unschedulableReason(workers []*worker.Worker, j *job.Job) string {
+	if len(workers) == 0 {
+		return "no workers"
+	}
+	reasons := make([]string, 0, len(workers))
+	for _, w := range workers {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", w.ID, placementReason(w, j, false)))
+	}
+	return strings.Join(reasons, "; ")
+}
@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// Policy decides which queued job is placed next and on which worker.
+//
+// Workers that break a job's hard constraints, aren't active or don't have
+// room for it are ruled out by the scheduler before the policy scores the
+// rest. All methods are called with the scheduler lock held.
+type Policy interface {
+	Name() string
+	// Pick returns queue indexes in the order jobs should be considered
+	Pick(queue []*job.Job) []int
+	// Score rates placing j on w, higher is better
+	Score(j *job.Job, w *worker.Worker) float64
+}
+
+// PlacementObserver is implemented by policies that keep state about what
+// is running. Gang shards are reported one by one.
+type PlacementObserver interface {
+	Placed(j *job.Job, w *worker.Worker)
+	// Released is called once j has handed back the resources it held
+	Released(j *job.Job, held job.Resources)
+}
+
+const (
+	PolicyPriority  = "priority"
+	PolicyFIFO      = "fifo"
+	PolicyFairShare = "fair_share"
+	PolicySJF       = "sjf"
+	PolicyBestFit   = "best_fit"
+)
+
+// NewPolicy builds one of the built-in policies by name. history is only used
+// by shortest-job-first.
+func NewPolicy(name string, history *RuntimeHistory) (Policy, error) {
+	switch name {
+	case PolicyPriority:
+		return StrictPriority{}, nil
+	case PolicyFIFO:
+		return FIFO{}, nil
+	case PolicyFairShare:
+		return NewWeightedFairShare(byJobType, nil), nil
+	case PolicySJF:
+		return ShortestJobFirst{History: history}, nil
+	case PolicyBestFit, "":
+		return BestFit{}, nil
+	default:
+		return nil, fmt.Errorf("unknown scheduling policy %q", name)
+	}
+}
+
+// sortedIndexes returns 0..len(queue)-1 ordered by less
+func sortedIndexes(queue []*job.Job, less func(a, b *job.Job) bool) []int {
+	order := make([]int, len(queue))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return less(queue[order[a]], queue[order[b]]) })
+	return order
+}
+
+// higherPriority is the queue's natural order: priority, then age
+func higherPriority(a, b *job.Job) bool {
+	if a.Priority == b.Priority {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.Priority > b.Priority
+}
+
+// affinity is the part of every score that comes from soft label preferences.
+// It's scaled so it always outweighs resource fit.
+func affinity(j *job.Job, w *worker.Worker) float64 {
+	return float64(j.Constraints.Score(w.Labels)) * 10
+}
+
+// StrictPriority runs the highest priority job first, oldest first among
+// equals, on the first worker with room
+type StrictPriority struct{}
+
+func (StrictPriority) Name() string                               { return PolicyPriority }
+func (StrictPriority) Pick(queue []*job.Job) []int                { return sortedIndexes(queue, higherPriority) }
+func (StrictPriority) Score(j *job.Job, w *worker.Worker) float64 { return affinity(j, w) }
+
+// FIFO runs jobs in submission order regardless of priority
+type FIFO struct{}
+
+func (FIFO) Name() string { return PolicyFIFO }
+func (FIFO) Pick(queue []*job.Job) []int {
+	return sortedIndexes(queue, func(a, b *job.Job) bool { return a.CreatedAt.Before(b.CreatedAt) })
+}
+func (FIFO) Score(j *job.Job, w *worker.Worker) float64 { return affinity(j, w) }
+
+// BestFit keeps priority order but packs each job onto the worker it fits
+// most tightly, leaving big workers free for big jobs
+type BestFit struct{}
+
+func (BestFit) Name() string                { return PolicyBestFit }
+func (BestFit) Pick(queue []*job.Job) []int { return sortedIndexes(queue, higherPriority) }
+func (BestFit) Score(j *job.Job, w *worker.Worker) float64 {
+	return affinity(j, w) - fitScore(w.DemandFor(j), w.Available(), w.Capacity())
+}
+
+// ShortestJobFirst runs the job type with the shortest historical execution
+// time first. Types with no history go after known ones, in priority order.
+type ShortestJobFirst struct {
+	History *RuntimeHistory
+}
+
+func (ShortestJobFirst) Name() string { return PolicySJF }
+func (p ShortestJobFirst) Pick(queue []*job.Job) []int {
+	return sortedIndexes(queue, func(a, b *job.Job) bool {
+		da, okA := p.History.Estimate(a.Type)
+		db, okB := p.History.Estimate(b.Type)
+		switch {
+		case okA && okB && da != db:
+			return da < db
+		case okA != okB:
+			return okA
+		default:
+			return higherPriority(a, b)
+		}
+	})
+}
+func (ShortestJobFirst) Score(j *job.Job, w *worker.Worker) float64 { return affinity(j, w) }
+
+// byJobType groups jobs for fair sharing by their type
+func byJobType(j *job.Job) string { return string(j.Type) }
+
+// WeightedFairShare splits capacity between groups of jobs in proportion to
+// their weights. The group whose running jobs hold the least thread share
+// relative to its weight goes next; within a group jobs run in priority order.
+type WeightedFairShare struct {
+	group   func(*job.Job) string
+	weights map[string]float64
+	usage   map[string]float64 // threads held by running jobs, per group
+}
+
+// NewWeightedFairShare groups jobs with group. Groups missing from weights
+// get a weight of 1.
+func NewWeightedFairShare(group func(*job.Job) string, weights map[string]float64) *WeightedFairShare {
+	return &WeightedFairShare{
+		group:   group,
+		weights: weights,
+		usage:   make(map[string]float64),
+	}
+}
+
+func (p *WeightedFairShare) Name() string { return PolicyFairShare }
+
+func (p *WeightedFairShare) share(g string) float64 {
+	w := p.weights[g]
+	if w <= 0 {
+		w = 1
+	}
+	return p.usage[g] / w
+}
+
+func (p *WeightedFairShare) Pick(queue []*job.Job) []int {
+	return sortedIndexes(queue, func(a, b *job.Job) bool {
+		sa, sb := p.share(p.group(a)), p.share(p.group(b))
+		if sa != sb {
+			return sa < sb
+		}
+		return higherPriority(a, b)
+	})
+}
+
+func (p *WeightedFairShare) Score(j *job.Job, w *worker.Worker) float64 { return affinity(j, w) }
+
+func (p *WeightedFairShare) Placed(j *job.Job, w *worker.Worker) {
+	p.usage[p.group(j)] += float64(w.DemandFor(j).Threads)
+}
+
+func (p *WeightedFairShare) Released(j *job.Job, held job.Resources) {
+	g := p.group(j)
+	if p.usage[g] -= float64(held.Threads); p.usage[g] == 0 {
+		delete(p.usage, g)
+	}
+}
@@ -0,0 +1,184 @@
+package scheduler
+
+import (
+	"maps"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// policyQueue builds the same mixed queue for every policy under test
+func policyQueue() []*job.Job {
+	base := time.Now()
+	mk := func(id string, t job.JobType, priority int, age time.Duration) *job.Job {
+		j := job.NewJob(id, id, t, priority, nil)
+		j.CreatedAt = base.Add(-age)
+		return j
+	}
+	return []*job.Job{
+		mk("old-low-sum", job.LargeArraySumJob, 1, 4*time.Second),
+		mk("new-high-add", job.AddNumbersJob, 10, 1*time.Second),
+		mk("mid-add", job.AddNumbersJob, 5, 2*time.Second),
+		mk("mid-resize", job.ResizeImageJob, 5, 3*time.Second),
+	}
+}
+
+func pickedIDs(p Policy, queue []*job.Job) []string {
+	ids := make([]string, 0, len(queue))
+	for _, i := range p.Pick(queue) {
+		ids = append(ids, queue[i].ID)
+	}
+	return ids
+}
+
+func TestPoliciesOrderQueueDifferently(t *testing.T) {
+	history := NewRuntimeHistory()
+	history.Seed(map[job.JobType]time.Duration{
+		job.AddNumbersJob:    time.Millisecond,
+		job.LargeArraySumJob: 50 * time.Millisecond,
+		job.ResizeImageJob:   100 * time.Millisecond,
+	})
+
+	cases := []struct {
+		policy Policy
+		want   []string
+	}{
+		{StrictPriority{}, []string{"new-high-add", "mid-resize", "mid-add", "old-low-sum"}},
+		{BestFit{}, []string{"new-high-add", "mid-resize", "mid-add", "old-low-sum"}},
+		{FIFO{}, []string{"old-low-sum", "mid-resize", "mid-add", "new-high-add"}},
+		{ShortestJobFirst{History: history}, []string{"new-high-add", "mid-add", "old-low-sum", "mid-resize"}},
+	}
+	for _, c := range cases {
+		if got := pickedIDs(c.policy, policyQueue()); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected order %v, got %v", c.policy.Name(), c.want, got)
+		}
+	}
+}
+
+func TestShortestJobFirstWithoutHistoryFallsBackToPriority(t *testing.T) {
+	got := pickedIDs(ShortestJobFirst{History: NewRuntimeHistory()}, policyQueue())
+	want := pickedIDs(StrictPriority{}, policyQueue())
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected priority order %v, got %v", want, got)
+	}
+}
+
+func TestWeightedFairShareAlternatesGroups(t *testing.T) {
+	w := worker.NewWorker("w", 4)
+	p := NewWeightedFairShare(byJobType, map[string]float64{string(job.AddNumbersJob): 2})
+
+	// Submit a flood of high priority adds and a few low priority reverses.
+	// Strict priority would run every add first; fair share interleaves them
+	// two adds to every reverse, per the weights.
+	var queue []*job.Job
+	for i := 0; i < 6; i++ {
+		queue = append(queue, job.NewJob("add", "add", job.AddNumbersJob, 10, nil))
+	}
+	for i := 0; i < 3; i++ {
+		queue = append(queue, job.NewJob("rev", "rev", job.ReverseStringJob, 1, nil))
+	}
+
+	var placed []job.JobType
+	for len(queue) > 0 {
+		i := p.Pick(queue)[0]
+		p.Placed(queue[i], w)
+		placed = append(placed, queue[i].Type)
+		queue = append(queue[:i], queue[i+1:]...)
+	}
+
+	adds := 0
+	for _, t := range placed[:6] {
+		if t == job.AddNumbersJob {
+			adds++
+		}
+	}
+	if adds != 4 {
+		t.Errorf("expected 4 adds in the first 6 placements, got %d (%v)", adds, placed)
+	}
+}
+
+func TestWeightedFairShareReleasesFinishedJobs(t *testing.T) {
+	p := NewWeightedFairShare(byJobType, nil)
+	cfg := DefaultConfig()
+	cfg.Policy = p
+	s := NewSchedulerWithConfig(createTestWorkers(), cfg)
+	s.Run()
+	defer s.Stop()
+
+	jobs := []*job.Job{
+		gangJob("gang", 6, 1000),
+		job.NewJob("add", "add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2}),
+	}
+	for _, j := range jobs {
+		if err := s.Submit(j); err != nil {
+			t.Fatal(err)
+		}
+		if !waitJobCompletion(j, time.Second) {
+			t.Fatalf("%s did not complete", j.ID)
+		}
+	}
+
+	// Usage is released when resources come back, just after completion
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		usage := maps.Clone(p.usage)
+		s.mu.Unlock()
+		if len(usage) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no usage once every job finished, got %v", usage)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPolicyScoresPlacement(t *testing.T) {
+	roomy := worker.NewWorker("roomy", 8)
+	snug := worker.NewWorker("snug", 2)
+	j := job.NewJob("fit", "fit", job.AddNumbersJob, 1, nil)
+	j.ThreadDemand = 2
+
+	if got := choose(BestFit{}, []*worker.Worker{roomy, snug}, j, roomy); got != snug {
+		t.Errorf("best_fit: expected snug worker, got %s", got.ID)
+	}
+	// Policies without packing leave the choice to the asking worker
+	if got := choose(StrictPriority{}, []*worker.Worker{roomy, snug}, j, roomy); got != roomy {
+		t.Errorf("priority: expected roomy worker, got %s", got.ID)
+	}
+}
+
+func TestSchedulerRunsWithEachPolicy(t *testing.T) {
+	for _, name := range []string{PolicyPriority, PolicyFIFO, PolicyFairShare, PolicySJF, PolicyBestFit} {
+		policy, err := NewPolicy(name, NewRuntimeHistory())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		s := NewSchedulerWithConfig(createTestWorkers(), Config{Policy: policy})
+		s.Run()
+
+		var submitted []*job.Job
+		for i := 0; i < 5; i++ {
+			j := job.NewJob("j", "Add", job.AddNumbersJob, i, job.AddNumbersPayload{X: i, Y: i})
+			j.ThreadDemand = 1 + i%2
+			if err := s.Submit(j); err != nil {
+				t.Fatalf("%s: submit: %v", name, err)
+			}
+			submitted = append(submitted, j)
+		}
+		for i, j := range submitted {
+			if !waitJobCompletion(j, time.Second) {
+				t.Errorf("%s: job %d did not complete", name, i)
+			}
+		}
+		s.Stop()
+	}
+
+	if _, err := NewPolicy("random", nil); err == nil {
+		t.Error("expected unknown policy name to be rejected")
+	}
+}
@@ -0,0 +1,258 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+var (
+	// ErrUnknownWorker is returned for worker IDs the scheduler doesn't have
+	ErrUnknownWorker = errors.New("unknown worker")
+	// ErrWorkerExists is returned by AddWorker for an ID already in use
+	ErrWorkerExists = errors.New("worker already exists")
+	// ErrWorkerBusy is returned by RemoveWorker while jobs are placed on
+	// the worker; drain it first
+	ErrWorkerBusy = errors.New("worker has jobs placed on it")
+	// ErrStranded is returned when a change would leave a queued job with no
+	// worker that could ever run it
+	ErrStranded = errors.New("change would leave queued jobs unschedulable")
+)
+
+// drainPoll is how often DrainWorker checks whether the worker is empty
+var drainPoll = 50 * time.Millisecond
+
+// Workers returns the workers jobs are placed on
+func (s *Scheduler) Workers() []*worker.Worker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.workers)
+}
+
+// Worker returns the worker with id, nil if there is none
+func (s *Scheduler) Worker(id string) *worker.Worker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.find(id)
+}
+
+// find returns the worker with id; caller holds s.mu
+func (s *Scheduler) find(id string) *worker.Worker {
+	for _, w := range s.workers {
+		if w.ID == id {
+			return w
+		}
+	}
+	return nil
+}
+
+// AddWorker starts placing jobs on w, which should already be started
+func (s *Scheduler) AddWorker(w *worker.Worker) error {
+	s.mu.Lock()
+	if s.find(w.ID) != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrWorkerExists, w.ID)
+	}
+	// A new slice, so snapshots handed out earlier don't change
+	s.workers = append(slices.Clone(s.workers), w)
+	s.loops[w] = &loopState{}
+	w.OnCapacityFreed(s.jobFreed)
+	w.OnSteal(s.jobStolen)
+	if s.started {
+		s.startLoop(w)
+	}
+	workers := s.workers
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	if s.cfg.WorkStealing {
+		worker.Connect(workers)
+	}
+	return nil
+}
+
+// RemoveWorker stops placing jobs on the worker with id and stops it. Jobs
+// must not be placed on it any more, so drain it first; anything still
+// queued on it that the scheduler didn't place goes back into the queues.
+func (s *Scheduler) RemoveWorker(id string) error {
+	s.mu.Lock()
+	w := s.find(id)
+	if w == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownWorker, id)
+	}
+	if n := s.placedOn(w); n > 0 {
+		s.mu.Unlock()
+		return fmt.Errorf("%w (%s has %d)", ErrWorkerBusy, id, n)
+	}
+	if j := s.stranded(w, nil); j != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("%w (no other worker can run queued job %s)", ErrStranded, j.ID)
+	}
+	w.Pause()
+	s.workers = slices.DeleteFunc(slices.Clone(s.workers), func(x *worker.Worker) bool { return x == w })
+	s.loops[w].removed = true
+	delete(s.loops, w)
+	workers := s.workers
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	if s.cfg.WorkStealing {
+		worker.Disconnect(w)
+		worker.Connect(workers)
+	}
+	s.requeue(w, w.Unqueue(func(*job.Job) bool { return true }))
+	w.Stop()
+	return nil
+}
+
+// ResizeWorker changes how many threads the worker with id offers. Jobs
+// running there keep their threads; see worker.Resize.
+func (s *Scheduler) ResizeWorker(id string, threads int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.find(id)
+	if w == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownWorker, id)
+	}
+	c := w.Capacity()
+	c.Threads = threads
+	if j := s.stranded(w, &c); j != nil && threads >= 1 {
+		return fmt.Errorf("%w (queued job %s needs %d threads and no other worker can run it)", ErrStranded, j.ID, j.ThreadDemand)
+	}
+	if err := w.Resize(threads); err != nil {
+		return err
+	}
+	s.cond.Broadcast() // there may be room now
+	return nil
+}
+
+// PauseWorker stops the worker with id starting jobs and moves the ones
+// queued on it back to the scheduler. Jobs already running carry on. Gang
+// shards stay, as the rest of their gang is placed elsewhere.
+func (s *Scheduler) PauseWorker(id string) error {
+	s.mu.Lock()
+	w := s.find(id)
+	if w == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownWorker, id)
+	}
+	w.Pause()
+	shards := make(map[*job.Job]bool)
+	for j, p := range s.holding {
+		if _, ok := s.gangs[j]; ok && p.worker == w {
+			shards[j] = true
+		}
+	}
+	s.mu.Unlock()
+
+	s.requeue(w, w.Unqueue(func(j *job.Job) bool { return !shards[j] }))
+	return nil
+}
+
+// ResumeWorker lets a paused or draining worker take jobs again
+func (s *Scheduler) ResumeWorker(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w := s.find(id)
+	if w == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownWorker, id)
+	}
+	w.Resume()
+	s.cond.Broadcast()
+	return nil
+}
+
+// DrainWorker stops placing jobs on the worker with id and waits until the
+// jobs already placed there have finished, or ctx is done. The worker is
+// left draining, ready for RemoveWorker.
+func (s *Scheduler) DrainWorker(ctx context.Context, id string) error {
+	s.mu.Lock()
+	w := s.find(id)
+	if w == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrUnknownWorker, id)
+	}
+	w.Drain()
+	s.mu.Unlock()
+
+	tick := time.NewTicker(drainPoll)
+	defer tick.Stop()
+	for {
+		s.mu.Lock()
+		n := s.placedOn(w)
+		s.mu.Unlock()
+		if n == 0 && w.Queued() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("worker %s still has %d jobs placed: %w", id, n, ctx.Err())
+		case <-tick.C:
+		}
+	}
+}
+
+// placedOn counts the jobs holding resources on w; caller holds s.mu
+func (s *Scheduler) placedOn(w *worker.Worker) int {
+	n := 0
+	for _, p := range s.holding {
+		if p.worker == w {
+			n++
+		}
+	}
+	return n
+}
+
+// stranded returns a queued job no worker could ever run if w's capacity
+// were c, or if w were gone when c is nil; caller holds s.mu. Gang jobs can
+// spread over whatever is left, so they're not checked.
+func (s *Scheduler) stranded(w *worker.Worker, c *job.Resources) *job.Job {
+	for _, t := range s.tenants {
+	queue:
+		for _, j := range t.queue {
+			if j.Gang {
+				continue
+			}
+			for _, x := range s.workers {
+				if x != w {
+					if placementReason(x, j, false) == "" {
+						continue queue
+					}
+				} else if c != nil {
+					if ok, _ := j.Constraints.Allows(x.Labels); ok && x.DemandFor(j).Fits(*c) {
+						continue queue
+					}
+				}
+			}
+			return j
+		}
+	}
+	return nil
+}
+
+// requeue puts jobs taken off w before they started back into their
+// tenants' queues. Preempted ones may already be back via jobFreed.
+func (s *Scheduler) requeue(w *worker.Worker, jobs []*job.Job) {
+	if len(jobs) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range jobs {
+		t := s.tenantFor(tenantOf(j))
+		if slices.Contains(t.queue, j) {
+			continue
+		}
+		j.Requeue()
+		heap.Push(&t.queue, j)
+		j.Log().Info("requeued off worker", "worker", w.ID, "state", w.State().String())
+	}
+	s.cond.Broadcast()
+}
@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+func TestAddAndRemoveWorker(t *testing.T) {
+	small := worker.NewWorker("small", 2)
+	small.Start()
+	s := NewScheduler([]*worker.Worker{small})
+	s.Run()
+	defer s.Stop()
+
+	big := job.NewJob("big", "Sum", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3}})
+	big.ThreadDemand = 4
+	if err := s.Submit(big); !errors.Is(err, ErrUnschedulable) {
+		t.Fatalf("expected ErrUnschedulable before the big worker exists, got %v", err)
+	}
+
+	large := worker.NewWorker("large", 4)
+	large.Start()
+	if err := s.AddWorker(large); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddWorker(worker.NewWorker("large", 1)); !errors.Is(err, ErrWorkerExists) {
+		t.Errorf("expected ErrWorkerExists, got %v", err)
+	}
+	if err := s.Submit(big); err != nil {
+		t.Fatal(err)
+	}
+	if !s.WaitAllJobsDone(time.Second) {
+		t.Fatal("job was never placed on the added worker")
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(s.Running()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("job did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := s.RemoveWorker("nope"); !errors.Is(err, ErrUnknownWorker) {
+		t.Errorf("expected ErrUnknownWorker, got %v", err)
+	}
+	if err := s.RemoveWorker("large"); err != nil {
+		t.Fatal(err)
+	}
+	if ws := s.Workers(); len(ws) != 1 || ws[0] != small {
+		t.Errorf("expected only the small worker left, got %v", ws)
+	}
+	deadline = time.Now().Add(time.Second)
+	for s.Health(time.Second).Check(0) != nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the removed worker's loop to be accounted for: %v", s.Health(time.Second).Check(0))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestResizeWorkerWontStrandQueuedJobs(t *testing.T) {
+	w := worker.NewWorker("w1", 4) // not started, jobs stay queued
+	s := NewScheduler([]*worker.Worker{w})
+	j := job.NewJob("1", "Sum", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1}})
+	j.ThreadDemand = 4
+	if err := s.Submit(j); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ResizeWorker("w1", 2); !errors.Is(err, ErrStranded) {
+		t.Errorf("expected ErrStranded, got %v", err)
+	}
+	if err := s.RemoveWorker("w1"); !errors.Is(err, ErrStranded) {
+		t.Errorf("expected ErrStranded, got %v", err)
+	}
+	if err := s.ResizeWorker("w1", 8); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Capacity().Threads; got != 8 {
+		t.Errorf("expected 8 threads, got %d", got)
+	}
+	if err := s.ResizeWorker("w1", 0); err == nil {
+		t.Error("expected an error for 0 threads")
+	}
+}
+
+func TestPauseWorkerRequeuesQueuedJobs(t *testing.T) {
+	s, w := preemptionScheduler(0)
+	j := lowPriorityJob("1", false)
+	running(t, s, w, j, 0)
+	j.Status = job.Pending // placed but still waiting in the worker's queue
+	w.JobQueue <- j
+
+	if err := s.PauseWorker("w1"); err != nil {
+		t.Fatal(err)
+	}
+	if q := s.QueuedByPriority(); q[1] != 1 {
+		t.Fatalf("expected the job back in the queue, got %v", q)
+	}
+	if len(s.Running()) != 0 || w.AvailableThreads() != 2 {
+		t.Errorf("expected the reservation released, %d threads free", w.AvailableThreads())
+	}
+	if why := s.Explain(j)["w1"]; why != "worker paused" {
+		t.Errorf("expected placement to skip the paused worker, got %q", why)
+	}
+
+	if err := s.ResumeWorker("w1"); err != nil {
+		t.Fatal(err)
+	}
+	if why := s.Explain(j)["w1"]; why != "" {
+		t.Errorf("expected the resumed worker to take the job, got %q", why)
+	}
+}
+
+func TestDrainWorkerWaitsForPlacedJobs(t *testing.T) {
+	s, w := preemptionScheduler(0)
+	j := lowPriorityJob("1", false)
+	running(t, s, w, j, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := s.DrainWorker(ctx, "w1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the drain to time out while the job runs, got %v", err)
+	}
+	if w.State() != worker.Draining {
+		t.Errorf("expected the worker to stay draining, got %s", w.State())
+	}
+	if err := s.RemoveWorker("w1"); !errors.Is(err, ErrWorkerBusy) {
+		t.Errorf("expected ErrWorkerBusy, got %v", err)
+	}
+
+	w.CancelReservation(j) // as if it finished
+	if err := s.DrainWorker(context.Background(), "w1"); err != nil {
+		t.Fatal(err)
+	}
+	w.Start() // RemoveWorker stops it
+	if err := s.RemoveWorker("w1"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// placement is where a placed job runs and what it holds there
+type placement struct {
+	worker *worker.Worker
+	held   job.Resources
+}
+
+// preempt looks for the highest-priority queued job that no worker has room
+// for and stops just enough lower-priority preemptible jobs on one worker to
+// make room. The stopped jobs are re-queued by jobFreed once their
+// resources are back. Caller holds s.mu.
+func (s *Scheduler) preempt() {
+	if !s.cfg.Preemption {
+		return
+	}
+	now := time.Now()
+	var retryAt time.Time
+	for _, j := range s.blocked() {
+		if choose(s.policy, s.workers, j, nil) != nil {
+			continue // a worker loop will pick it up
+		}
+		victims, freeing, wait := s.victimsFor(j, now)
+		if freeing {
+			return // earlier preemptions will make room
+		}
+		if victims != nil {
+			for _, v := range victims {
+				v.Log().Info("preempting job", "for", j.ID, "priority", v.Priority, "blocked_priority", j.Priority)
+				for _, r := range s.stopping(v) {
+					r.Preempt()
+					s.preempting[r] = true
+				}
+			}
+			j.Log().Info("preempting lower-priority jobs to make room", "victims", len(victims))
+			return
+		}
+		if !wait.IsZero() && (retryAt.IsZero() || wait.Before(retryAt)) {
+			retryAt = wait
+		}
+	}
+	if !retryAt.IsZero() && s.retry == nil {
+		s.retry = time.AfterFunc(retryAt.Sub(now), func() {
+			s.mu.Lock()
+			s.retry = nil
+			s.mu.Unlock()
+			s.wake()
+		})
+	}
+}
+
+// stopping is what preempting v stops: v, or every unfinished shard of its
+// gang, which can't finish without it; caller holds s.mu
+func (s *Scheduler) stopping(v *job.Job) []*job.Job {
+	g, ok := s.gangs[v]
+	if !ok {
+		return []*job.Job{v}
+	}
+	var out []*job.Job
+	for _, shard := range g.shards {
+		if _, ok := s.gangs[shard]; ok {
+			out = append(out, shard)
+		}
+	}
+	return out
+}
+
+// blocked lists queued jobs whose tenant has quota for them, highest
+// priority first; caller holds s.mu
+func (s *Scheduler) blocked() []*job.Job {
+	var out []*job.Job
+	for _, t := range s.tenants {
+		for _, j := range t.queue {
+			if t.withinThreadQuota(j.Demand().Threads) {
+				out = append(out, j)
+			}
+		}
+	}
+	sort.Slice(out, func(a, b int) bool {
+		return higherPriority(out[a], out[b])
+	})
+	return out
+}
+
+// victimsFor picks the fewest running jobs to stop so that j fits on one
+// worker. Only preemptible jobs of lower priority that are past the grace
+// period qualify, lowest priority and most recently started first so the
+// least work is thrown away. freeing is set when jobs already being
+// preempted will make enough room by themselves. If nothing qualifies yet
+// but would once a grace period runs out, wait is when that happens.
+func (s *Scheduler) victimsFor(j *job.Job, now time.Time) (victims []*job.Job, freeing bool, wait time.Time) {
+	for _, w := range s.workers {
+		if w.State() != worker.Active || placementReason(w, j, false) != "" {
+			continue // nothing would be placed here even once there's room
+		}
+		need := w.DemandFor(j)
+		avail := w.Available()
+		var eligible, waiting []*job.Job
+		for r, p := range s.holding {
+			if p.worker != w {
+				continue
+			}
+			switch {
+			case s.preempting[r]:
+				avail = avail.Add(p.held)
+			case !r.Preemptible || r.Priority >= j.Priority:
+			case now.Sub(r.StartedAt) < s.cfg.PreemptionGracePeriod:
+				waiting = append(waiting, r)
+			default:
+				eligible = append(eligible, r)
+			}
+		}
+		if need.Fits(avail) {
+			return nil, true, time.Time{}
+		}
+
+		sort.Slice(eligible, func(a, b int) bool {
+			if eligible[a].Priority != eligible[b].Priority {
+				return eligible[a].Priority < eligible[b].Priority
+			}
+			return eligible[a].StartedAt.After(eligible[b].StartedAt)
+		})
+		var picked []*job.Job
+		for _, r := range eligible {
+			if need.Fits(avail) {
+				break
+			}
+			picked = append(picked, r)
+			avail = avail.Add(s.holding[r].held)
+		}
+		if need.Fits(avail) {
+			if victims == nil || len(picked) < len(victims) {
+				victims = picked
+			}
+			continue
+		}
+		for _, r := range waiting {
+			at := r.StartedAt.Add(s.cfg.PreemptionGracePeriod)
+			if wait.IsZero() || at.Before(wait) {
+				wait = at
+			}
+		}
+	}
+	return victims, false, wait
+}
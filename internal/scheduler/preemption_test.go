@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+func preemptionScheduler(grace time.Duration) (*Scheduler, *worker.Worker) {
+	w := worker.NewWorker("w1", 2) // not started, jobs stay where we put them
+	cfg := DefaultConfig()
+	cfg.Preemption = true
+	cfg.PreemptionGracePeriod = grace
+	return NewSchedulerWithConfig([]*worker.Worker{w}, cfg), w
+}
+
+// running submits j and places it on w the way a worker loop would
+func running(t *testing.T, s *Scheduler, w *worker.Worker, j *job.Job, since time.Duration) {
+	t.Helper()
+	if err := s.Submit(j); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tn := s.tenantFor(tenantOf(j))
+	for i, q := range tn.queue {
+		if q == j {
+			if !w.TryReserve(j) {
+				t.Fatalf("no room for %s", j.ID)
+			}
+			s.place(tn, i, j, w)
+			j.StartedAt = time.Now().Add(-since)
+			j.Status = job.Running
+			return
+		}
+	}
+	t.Fatalf("%s not queued", j.ID)
+}
+
+func lowPriorityJob(id string, preemptible bool) *job.Job {
+	j := job.NewJob(id, "Sum", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2}})
+	j.Preemptible = preemptible
+	return j
+}
+
+func TestPreemptStopsLowerPriorityJobAndRequeuesIt(t *testing.T) {
+	s, w := preemptionScheduler(10 * time.Second)
+	low1 := lowPriorityJob("low1", true)
+	low2 := lowPriorityJob("low2", true)
+	low1.Attempts = 1
+	running(t, s, w, low1, time.Minute)
+	running(t, s, w, low2, 2*time.Minute)
+
+	urgent := job.NewJob("urgent", "Add", job.AddNumbersJob, 100, job.AddNumbersPayload{X: 1, Y: 2})
+	if err := s.Submit(urgent); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	s.preempt()
+	s.preempt() // already freeing, must not stop a second job
+	s.mu.Unlock()
+
+	// Only one thread is needed; the most recently started job goes
+	if !low1.Preempted() || low2.Preempted() {
+		t.Fatalf("expected only low1 preempted, got low1=%v low2=%v", low1.Preempted(), low2.Preempted())
+	}
+
+	// The worker hands low1's resources back
+	w.CancelReservation(low1)
+
+	if low1.Status != job.Pending || low1.Preempted() {
+		t.Errorf("expected low1 reset to Pending, got %s (preempted=%v)", low1.Status, low1.Preempted())
+	}
+	if low1.Attempts != 1 {
+		t.Errorf("expected attempt count to be kept, got %d", low1.Attempts)
+	}
+	if q := s.Tenants()[DefaultTenant].Queued; q != 2 {
+		t.Errorf("expected urgent and low1 queued, got %d", q)
+	}
+}
+
+func TestPreemptRespectsFlagPriorityAndGracePeriod(t *testing.T) {
+	s, w := preemptionScheduler(time.Hour)
+	pinned := lowPriorityJob("pinned", false)
+	fresh := lowPriorityJob("fresh", true)
+	running(t, s, w, pinned, time.Minute)
+	running(t, s, w, fresh, time.Minute) // inside the grace period
+
+	same := job.NewJob("same", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	urgent := job.NewJob("urgent", "Add", job.AddNumbersJob, 100, job.AddNumbersPayload{X: 1, Y: 2})
+	s.Submit(same)
+	s.Submit(urgent)
+
+	s.mu.Lock()
+	s.preempt()
+	retrying := s.retry != nil
+	s.mu.Unlock()
+	defer s.Stop()
+
+	if pinned.Preempted() || fresh.Preempted() {
+		t.Fatalf("nothing should be preempted, got pinned=%v fresh=%v", pinned.Preempted(), fresh.Preempted())
+	}
+	if !retrying {
+		t.Error("expected a retry once the grace period ends")
+	}
+}
+
+func TestPreemptionDisabledByDefault(t *testing.T) {
+	w := worker.NewWorker("w1", 1)
+	s := NewScheduler([]*worker.Worker{w})
+	low := lowPriorityJob("low", true)
+	running(t, s, w, low, time.Hour)
+	s.Submit(job.NewJob("urgent", "Add", job.AddNumbersJob, 100, job.AddNumbersPayload{X: 1, Y: 2}))
+
+	s.mu.Lock()
+	s.preempt()
+	s.mu.Unlock()
+
+	if low.Preempted() {
+		t.Error("preemption happened without being enabled")
+	}
+}
+
+func TestPreemptingAShardRequeuesTheWholeGang(t *testing.T) {
+	w1 := worker.NewWorker("w1", 4)
+	w2 := worker.NewWorker("w2", 2)
+	cfg := DefaultConfig()
+	cfg.Preemption = true
+	cfg.PreemptionGracePeriod = time.Second
+	s := NewSchedulerWithConfig([]*worker.Worker{w1, w2}, cfg)
+
+	gang := gangJob("gang", 6, 10)
+	gang.Preemptible = true
+	if err := s.Submit(gang); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	placed := s.placeGang(s.tenantFor(DefaultTenant), 0, gang, s.workers)
+	for _, d := range placed {
+		if !d.job.Preemptible {
+			t.Errorf("expected shard %s to inherit preemptible", d.job.ID)
+		}
+		d.job.StartedAt = time.Now().Add(-time.Minute)
+	}
+	s.mu.Unlock()
+	if len(placed) != 2 {
+		t.Fatalf("expected 2 shards, got %d", len(placed))
+	}
+
+	urgent := job.NewJob("urgent", "Add", job.AddNumbersJob, 100, job.AddNumbersPayload{X: 1, Y: 2})
+	if err := s.Submit(urgent); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.preempt()
+	s.mu.Unlock()
+
+	// One shard makes room, but the gang can't finish without both
+	for _, d := range placed {
+		if !d.job.Preempted() {
+			t.Fatalf("expected every shard preempted, %s wasn't", d.job.ID)
+		}
+	}
+	placed[0].worker.CancelReservation(placed[0].job)
+	if q := s.Tenants()[DefaultTenant].Queued; q != 1 {
+		t.Errorf("expected the gang to wait for its other shard, got %d queued", q)
+	}
+	placed[1].worker.CancelReservation(placed[1].job)
+
+	if st := gang.CurrentStatus(); st != job.Pending || gang.Preempted() {
+		t.Errorf("expected the parent reset to Pending, got %s (preempted=%v)", st, gang.Preempted())
+	}
+	if q := s.Tenants()[DefaultTenant].Queued; q != 2 {
+		t.Errorf("expected urgent and the parent queued, not the shards, got %d", q)
+	}
+}
@@ -2,7 +2,10 @@ package scheduler
 
 import (
 	"container/heap"
+	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
@@ -46,128 +49,367 @@ func (jq *JobQueue) Pop() interface{} {
 // ---------------------
 
 type Scheduler struct {
-	mu      sync.Mutex
-	cond    *sync.Cond
-	jobQ    JobQueue
-	workers []*worker.Worker
-	wg      sync.WaitGroup
-	stopCh  chan struct{}
+	mu       sync.Mutex
+	cond     *sync.Cond
+	tenants  map[string]*tenant
+	holding  map[*job.Job]placement // where each placed job runs, until it finishes
+	workers  []*worker.Worker
+	wg       sync.WaitGroup
+	stopCh   chan struct{}
+	cfg      Config
+	policy   Policy
+	draining bool // set by Drain: no new jobs, nothing more is placed
+
+	gangs      map[*job.Job]*gang // shard -> the gang it belongs to
+	preempting map[*job.Job]bool  // asked to stop, resources not back yet
+	retry      *time.Timer        // re-checks preemption once a grace period ends
+
+	loops   map[*worker.Worker]*loopState
+	running atomic.Int32 // worker loops that haven't exited
+	started bool         // Run has been called; added workers get a loop
 }
 
 // NewScheduler takes a list of worker pointers
 func NewScheduler(workers []*worker.Worker) *Scheduler {
+	return NewSchedulerWithConfig(workers, DefaultConfig())
+}
+
+func NewSchedulerWithConfig(workers []*worker.Worker, cfg Config) *Scheduler {
 	s := &Scheduler{
-		jobQ:    make(JobQueue, 0),
-		workers: workers,
-		stopCh:  make(chan struct{}),
+		tenants:    make(map[string]*tenant),
+		holding:    make(map[*job.Job]placement),
+		workers:    workers,
+		stopCh:     make(chan struct{}),
+		cfg:        cfg,
+		policy:     cfg.Policy,
+		gangs:      make(map[*job.Job]*gang),
+		preempting: make(map[*job.Job]bool),
+		loops:      make(map[*worker.Worker]*loopState),
+	}
+	if s.policy == nil {
+		s.policy = BestFit{}
 	}
 	s.cond = sync.NewCond(&s.mu)
+	for _, w := range workers {
+		s.loops[w] = &loopState{}
+		// Jobs waiting for threads get another chance whenever a worker frees some
+		w.OnCapacityFreed(s.jobFreed)
+		w.OnSteal(s.jobStolen)
+	}
+	if cfg.WorkStealing {
+		worker.Connect(workers)
+	}
 	return s
 }
 
-// Submit adds a job to the priority queue
-func (s *Scheduler) Submit(j *job.Job) {
+// ErrUnschedulable is returned by Submit for jobs whose resource demand is
+// bigger than any worker, even an idle one
+var ErrUnschedulable = errors.New("no worker can ever satisfy the job's resource demand")
+
+// ErrInvalidDemand is returned by Submit for jobs asking for negative
+// amounts of a resource
+var ErrInvalidDemand = errors.New("invalid resource demand")
+
+// Submit adds a job to its tenant's queue
+func (s *Scheduler) Submit(j *job.Job) (err error) {
+	span := traceSubmit(j)
+	defer func() { endSubmit(span, err) }()
 	s.mu.Lock()
-	heap.Push(&s.jobQ, j)
+	if s.draining {
+		s.mu.Unlock()
+		return ErrDraining
+	}
+	if err := s.admit(j); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	t := s.tenantFor(tenantOf(j))
+	if t.cfg.MaxQueued > 0 && len(t.queue) >= t.cfg.MaxQueued {
+		s.mu.Unlock()
+		return fmt.Errorf("%w (tenant %s already has %d queued jobs)", ErrQuotaExceeded, t.name, len(t.queue))
+	}
+	heap.Push(&t.queue, j)
 	s.cond.Broadcast() // wake up all waiting worker loops
 	s.mu.Unlock()
+	return nil
 }
 
 // Run starts one goroutine per worker
 func (s *Scheduler) Run() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+	for _, w := range s.workers {
+		s.startLoop(w)
+	}
+}
+
+// startLoop runs w's placement loop; caller holds s.mu
+func (s *Scheduler) startLoop(w *worker.Worker) {
+	s.wg.Add(1)
+	s.running.Add(1)
+	go s.workerLoop(w, s.loops[w])
+}
+
+// admit applies the oversize policy to j and checks that some worker could
+// run it; caller holds s.mu
+func (s *Scheduler) admit(j *job.Job) error {
+	demand := job.Resources{Threads: j.ThreadDemand, MemoryMB: j.MemoryMB, Custom: j.CustomResources}
+	if err := demand.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDemand, err)
+	}
+	largest := 0
 	for _, w := range s.workers {
-		s.wg.Add(1)
-		go s.workerLoop(w)
+		largest = max(largest, w.Capacity().Threads)
 	}
+	// Gang jobs can be bigger than any one worker; the oversize policy is
+	// for everything else
+	if !j.Gang && j.ThreadDemand > largest {
+		var granted int
+		switch s.cfg.OversizePolicy {
+		case OversizeClamp:
+			granted = s.largestFitFor(j)
+		case OversizeDowngrade:
+			granted = 1
+		default:
+			return fmt.Errorf("%w (thread_demand %d exceeds the largest worker's %d threads)", ErrUnschedulable, j.ThreadDemand, largest)
+		}
+		j.ThreadOutcome = &job.ThreadOutcome{
+			Policy:    string(s.cfg.OversizePolicy),
+			Requested: j.ThreadDemand,
+			Granted:   granted,
+		}
+		j.Log().Warn("thread demand exceeds the largest worker",
+			"policy", s.cfg.OversizePolicy, "requested", j.ThreadDemand, "granted", granted)
+		j.ThreadDemand = granted
+	}
+
+	if err := j.Constraints.Validate(); err != nil {
+		return fmt.Errorf("invalid constraints: %w", err)
+	}
+	if j.Gang {
+		if err := s.gangError(j); err != nil {
+			return err
+		}
+	} else if !s.canEverRun(j) {
+		return fmt.Errorf("%w (%s)", ErrUnschedulable, unschedulableReason(s.workers, j))
+	}
+	if t := s.tenantFor(tenantOf(j)); t.cfg.MaxThreads > 0 && j.Demand().Threads > t.cfg.MaxThreads {
+		return fmt.Errorf("%w (thread_demand %d exceeds tenant %s's quota of %d threads)", ErrUnschedulable, j.ThreadDemand, t.name, t.cfg.MaxThreads)
+	}
+	return nil
+}
+
+// largestFitFor is the most threads j could get on any single worker that
+// can also meet the rest of its demand; caller holds s.mu
+func (s *Scheduler) largestFitFor(j *job.Job) int {
+	granted := 1
+	for _, w := range s.workers {
+		if ok, _ := j.Constraints.Allows(w.Labels); !ok {
+			continue
+		}
+		d, c := w.DemandFor(j), w.Capacity()
+		d.Threads = c.Threads
+		if d.Fits(c) {
+			granted = max(granted, c.Threads)
+		}
+	}
+	return granted
+}
+
+// canEverRun reports whether any worker could run j when idle; caller holds s.mu
+func (s *Scheduler) canEverRun(j *job.Job) bool {
+	for _, w := range s.workers {
+		if placementReason(w, j, false) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Explain reports, for each worker, why j can't be placed on it right now.
+// Workers that could take j immediately map to "".
+func (s *Scheduler) Explain(j *job.Job) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reasons := make(map[string]string, len(s.workers))
+	for _, w := range s.workers {
+		reasons[w.ID] = placementReason(w, j, true)
+	}
+	return reasons
 }
 
 // workerLoop continuously tries to get jobs and assign them to this worker
-func (s *Scheduler) workerLoop(w *worker.Worker) {
+func (s *Scheduler) workerLoop(w *worker.Worker, state *loopState) {
 	defer s.wg.Done()
+	defer s.running.Add(-1)
 	for {
 		s.mu.Lock()
 
 		// Check for stop signal first
-		select {
-		case <-s.stopCh:
+		if s.stopped(state) {
 			s.mu.Unlock()
 			return
-		default:
 		}
 
-		// Wait while no jobs available
-		for len(s.jobQ) == 0 {
+		// Wait while no jobs available, or for good once draining
+		for s.queued() == 0 || s.draining {
 			s.cond.Wait()
 			// Check stop signal after waking up
-			select {
-			case <-s.stopCh:
+			if s.stopped(state) {
 				s.mu.Unlock()
 				return
-			default:
 			}
 		}
 
-		var selectedJob *job.Job
-		var index int
-		var fallbackSingleThread bool
+		var assigned []dispatch
+		handedOff := false
 
-		// Iterate jobs by priority
-		for i, j := range s.jobQ {
-			// Check if worker can execute immediately
-			if j.ThreadDemand <= w.AvailableThreads() {
-				selectedJob = j
-				index = i
-				break
-			}
-		}
-
-		if selectedJob == nil && len(s.jobQ) > 0 {
-			// No currently free worker can execute any job
-			maxThreads := 0
-			for _, other := range s.workers {
-				if other.NumThreads > maxThreads {
-					maxThreads = other.NumThreads
+		// Tenants take turns by dominant share. Within a tenant, go through
+		// jobs in the order the policy picks and take the first one for which
+		// this worker is the policy's choice right now. TryReserve is what
+		// keeps another loop from handing the same resources out twice. With
+		// backfilling on, a head job nothing has room for yet holds a worker
+		// against jobs that would delay it.
+		now := time.Now()
+		order := s.tenantsByShare()
+		held := s.reserve(order, now)
+	tenants:
+		for _, t := range order {
+			for _, i := range s.policy.Pick(t.queue) {
+				j := t.queue[i]
+				if !t.withinThreadQuota(j.Demand().Threads) {
+					continue // tenant is at its thread quota
+				}
+				allowed := held.workersFor(s, j, now)
+				best := choose(s.policy, allowed, j, w)
+				if best == nil {
+					if j.Gang {
+						// No single worker has room, try all of them together
+						if assigned = s.placeGang(t, i, j, allowed); assigned != nil {
+							break tenants
+						}
+					}
+					continue // nothing has room for it yet
+				}
+				if best != w {
+					handedOff = true // leave it for the better-scoring worker
+					continue
+				}
+				if w.TryReserve(j) {
+					assigned = []dispatch{{worker: w, job: j}}
+					s.place(t, i, j, w)
+					break tenants
 				}
 			}
+		}
 
-			// Pick highest priority job anyway if no worker can satisfy it
-			if s.jobQ[0].ThreadDemand > maxThreads {
-				selectedJob = s.jobQ[0]
-				index = 0
-				fallbackSingleThread = true
-			} else {
-				// Wait until threads become free
-				s.cond.Wait()
-				s.mu.Unlock()
-				continue
+		if assigned == nil {
+			if handedOff {
+				s.cond.Broadcast() // make sure the better worker's loop looks
 			}
+			s.preempt()
+			// Wait until threads become free or a new job arrives
+			s.cond.Wait()
+			s.mu.Unlock()
+			continue
 		}
 
-		// Remove job from queue
-		s.jobQ = append(s.jobQ[:index], s.jobQ[index+1:]...)
-		// Set started_at timestamp if not already set
-		if selectedJob.StartedAt.IsZero() {
-			selectedJob.StartedAt = time.Now()
+		// StartedAt is when the job was first placed; any wait in the
+		// worker's JobQueue after that counts as execution time
+		placed := time.Now()
+		for _, d := range assigned {
+			d.job.MarkPlaced(placed)
 		}
 		s.mu.Unlock()
 
-		if fallbackSingleThread {
-			// Run single-thread fallback
-			selectedJob.ThreadDemand = 1
+		for _, d := range assigned {
+			traceQueueWait(d.job, placed)
 		}
+		for k, d := range assigned {
+			span := traceDispatch(d.job, d.worker)
+			state.dispatchSince.Store(time.Now().UnixNano())
+			select {
+			case d.worker.JobQueue <- d.job:
+				state.dispatchSince.Store(0)
+				span.End()
+			case <-s.stopCh:
+				state.dispatchSince.Store(0)
+				span.End()
+				for _, rest := range assigned[k:] {
+					rest.worker.CancelReservation(rest.job)
+				}
+				return
+			}
+		}
+	}
+}
 
-		select {
-		case w.JobQueue <- selectedJob:
-		case <-s.stopCh:
-			return
+// stopped reports whether the loop with state should exit, because the
+// scheduler stopped or its worker was removed; caller holds s.mu
+func (s *Scheduler) stopped(state *loopState) bool {
+	select {
+	case <-s.stopCh:
+		return true
+	default:
+		return state.removed
+	}
+}
+
+// queued counts jobs waiting across all tenants; caller holds s.mu
+func (s *Scheduler) queued() int {
+	n := 0
+	for _, t := range s.tenants {
+		n += len(t.queue)
+	}
+	return n
+}
+
+// QueuedByPriority counts the jobs waiting to be placed at each priority
+func (s *Scheduler) QueuedByPriority() map[int]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[int]int)
+	for _, t := range s.tenants {
+		for _, j := range t.queue {
+			out[j.Priority]++
+		}
+	}
+	return out
+}
+
+// Backlog is how many jobs are waiting to be placed and when the oldest of
+// them was submitted, zero if none are
+func (s *Scheduler) Backlog() (queued int, oldest time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tenants {
+		for _, j := range t.queue {
+			queued++
+			if oldest.IsZero() || j.CreatedAt.Before(oldest) {
+				oldest = j.CreatedAt
+			}
 		}
 	}
+	return queued, oldest
+}
+
+// wake re-runs placement in every worker loop
+func (s *Scheduler) wake() {
+	s.mu.Lock()
+	s.cond.Broadcast()
+	s.mu.Unlock()
 }
 
 // Stop signals all worker loops to exit and stops workers
 func (s *Scheduler) Stop() {
 	close(s.stopCh)
+	s.mu.Lock()
+	if s.retry != nil {
+		s.retry.Stop()
+	}
 	s.cond.Broadcast() // wake up all waiting worker loops
+	s.mu.Unlock()
 	s.wg.Wait()
 
 	for _, w := range s.workers {
@@ -180,7 +422,7 @@ func (s *Scheduler) WaitAllJobsDone(timeout time.Duration) bool {
 	deadline := time.Now().Add(timeout)
 	for {
 		s.mu.Lock()
-		if len(s.jobQ) == 0 {
+		if s.queued() == 0 {
 			s.mu.Unlock()
 			return true
 		}
@@ -1,6 +1,9 @@
 package scheduler
 
 import (
+	"errors"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -8,6 +11,29 @@ import (
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
 )
 
+// occupiedJob is a single-chunk job that counts how many of its kind are
+// running at once
+const occupiedJob job.JobType = "Occupied"
+
+type occupancy struct {
+	running, peak atomic.Int64
+}
+
+func init() {
+	job.RegisterMapReduce(occupiedJob, job.MapReduce[*occupancy, struct{}]{
+		Split: func(o *occupancy, n int) []*occupancy { return []*occupancy{o} },
+		Map: func(o *occupancy) (struct{}, error) {
+			n := o.running.Add(1)
+			for p := o.peak.Load(); n > p && !o.peak.CompareAndSwap(p, n); p = o.peak.Load() {
+			}
+			time.Sleep(20 * time.Millisecond) // long enough for the others to overlap
+			o.running.Add(-1)
+			return struct{}{}, nil
+		},
+		Reduce: func([]struct{}) (interface{}, error) { return nil, nil },
+	})
+}
+
 // Helper to create test workers
 func createTestWorkers() []*worker.Worker {
 	w1 := worker.NewWorker("w1", 4)
@@ -21,7 +47,7 @@ func createTestWorkers() []*worker.Worker {
 func waitJobCompletion(j *job.Job, timeout time.Duration) bool {
 	deadline := time.Now().Add(timeout)
 	for {
-		if j.Status == job.Completed {
+		if j.CurrentStatus() == job.Completed {
 			return true
 		}
 		if time.Now().After(deadline) {
@@ -77,7 +103,7 @@ func TestSchedulerThreadDemand(t *testing.T) {
 	}
 }
 
-func TestSchedulerSingleThreadFallback(t *testing.T) {
+func TestSchedulerRejectsImpossibleThreadDemand(t *testing.T) {
 	workers := createTestWorkers()
 	s := NewScheduler(workers)
 	s.Run()
@@ -85,10 +111,277 @@ func TestSchedulerSingleThreadFallback(t *testing.T) {
 
 	jobImpossible := job.NewJob("impossible", "ImpossibleJob", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3}})
 	jobImpossible.ThreadDemand = 10
-	s.Submit(jobImpossible)
+	err := s.Submit(jobImpossible)
+	if !errors.Is(err, ErrUnschedulable) {
+		t.Fatalf("expected ErrUnschedulable, got %v", err)
+	}
+	if jobImpossible.ThreadDemand != 10 {
+		t.Errorf("thread demand should not be rewritten, got %d", jobImpossible.ThreadDemand)
+	}
+}
 
-	done := waitJobCompletion(jobImpossible, 500*time.Millisecond)
-	if !done {
-		t.Errorf("Job with impossible thread demand did not complete with single-thread fallback")
+func TestSchedulerOversizePolicies(t *testing.T) {
+	cases := []struct {
+		policy  OversizePolicy
+		granted int
+	}{
+		{OversizeClamp, 4},
+		{OversizeDowngrade, 1},
+	}
+	for _, c := range cases {
+		workers := createTestWorkers()
+		s := NewSchedulerWithConfig(workers, Config{OversizePolicy: c.policy})
+		s.Run()
+
+		j := job.NewJob("big", "TooWide", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3, 4, 5}})
+		j.ThreadDemand = 10
+		if err := s.Submit(j); err != nil {
+			t.Fatalf("%s: unexpected submit error: %v", c.policy, err)
+		}
+		if !waitJobCompletion(j, 500*time.Millisecond) {
+			t.Errorf("%s: job did not complete", c.policy)
+		}
+		if j.ThreadDemand != c.granted {
+			t.Errorf("%s: expected %d threads, got %d", c.policy, c.granted, j.ThreadDemand)
+		}
+		o := j.ThreadOutcome
+		if o == nil || o.Policy != string(c.policy) || o.Requested != 10 || o.Granted != c.granted {
+			t.Errorf("%s: unexpected outcome %+v", c.policy, o)
+		}
+		if res := j.Result.(job.LargeArraySumResult); res.Sum != 15 {
+			t.Errorf("%s: expected sum 15, got %d", c.policy, res.Sum)
+		}
+		s.Stop()
+	}
+}
+
+func TestSchedulerRejectsMissingCustomResource(t *testing.T) {
+	workers := createTestWorkers()
+	s := NewScheduler(workers)
+	s.Run()
+	defer s.Stop()
+
+	j := job.NewJob("gpu", "NeedsGPU", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 1})
+	j.CustomResources = map[string]int{"gpu-license": 1}
+	if err := s.Submit(j); !errors.Is(err, ErrUnschedulable) {
+		t.Fatalf("expected ErrUnschedulable, got %v", err)
+	}
+}
+
+func TestSchedulerRejectsNegativeDemand(t *testing.T) {
+	w := worker.NewWorkerWithResources("w1", job.Resources{Threads: 2, MemoryMB: 1024, Custom: map[string]int{"ssd": 1}}, 10)
+	s := NewScheduler([]*worker.Worker{w})
+
+	negMemory := job.NewJob("1", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})
+	negMemory.MemoryMB = -512
+	negCustom := job.NewJob("2", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})
+	negCustom.CustomResources = map[string]int{"ssd": -1}
+	for _, j := range []*job.Job{negMemory, negCustom} {
+		if err := s.Submit(j); !errors.Is(err, ErrInvalidDemand) {
+			t.Errorf("job %s: expected ErrInvalidDemand, got %v", j.ID, err)
+		}
+	}
+	if q := s.QueuedByPriority(); len(q) != 0 {
+		t.Errorf("expected nothing queued, got %v", q)
+	}
+	if got := w.Available(); got.MemoryMB != 1024 || got.Custom["ssd"] != 1 {
+		t.Errorf("expected the worker's capacity untouched, got %s", got)
+	}
+}
+
+func TestSchedulerPlacesByMemory(t *testing.T) {
+	small := worker.NewWorkerWithResources("small", job.Resources{Threads: 4, MemoryMB: 256}, 10)
+	big := worker.NewWorkerWithResources("big", job.Resources{Threads: 4, MemoryMB: 4096}, 10)
+	small.Start()
+	big.Start()
+	s := NewScheduler([]*worker.Worker{small, big})
+	s.Run()
+	defer s.Stop()
+
+	// Only the big worker has room for these, and only two at a time
+	occ := &occupancy{}
+	var submitted []*job.Job
+	for i := 0; i < 4; i++ {
+		j := job.NewJob("mem", "Occupied", occupiedJob, 1, occ)
+		j.MemoryMB = 2048
+		if err := s.Submit(j); err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		submitted = append(submitted, j)
+	}
+
+	for i, j := range submitted {
+		if !waitJobCompletion(j, 2*time.Second) {
+			t.Fatalf("memory-bound job %d did not complete", i)
+		}
+	}
+	if peak := occ.peak.Load(); peak > 2 {
+		t.Errorf("expected at most two memory-bound jobs at once, got %d", peak)
+	}
+	if got := big.Available().MemoryMB; got != 4096 {
+		t.Errorf("expected big worker memory to be released, got %d", got)
+	}
+}
+
+func TestBestFitPrefersTighterWorker(t *testing.T) {
+	roomy := worker.NewWorkerWithResources("roomy", job.Resources{Threads: 8, MemoryMB: 8192}, 10)
+	snug := worker.NewWorkerWithResources("snug", job.Resources{Threads: 2, MemoryMB: 1024}, 10)
+
+	j := job.NewJob("fit", "Fit", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 1})
+	j.ThreadDemand = 2
+	j.MemoryMB = 1000
+
+	if got := choose(BestFit{}, []*worker.Worker{roomy, snug}, j, roomy); got != snug {
+		t.Errorf("expected snug worker, got %v", got.ID)
+	}
+}
+
+func TestSchedulerNoDeadlockWhenDemandExceedsFreeThreads(t *testing.T) {
+	workers := createTestWorkers()
+	s := NewScheduler(workers)
+	s.Run()
+	defer s.Stop()
+
+	// More 3-thread jobs than can ever run at once. Placement used to trust a
+	// stale AvailableThreads, overbook w1 and leave its consumers deadlocked.
+	var submitted []*job.Job
+	for i := 0; i < 12; i++ {
+		j := job.NewJob("j", "Wide", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3, 4, 5, 6}})
+		j.ThreadDemand = 3
+		submitted = append(submitted, j)
+		s.Submit(j)
+	}
+
+	for i, j := range submitted {
+		if !waitJobCompletion(j, 2*time.Second) {
+			t.Fatalf("job %d did not complete; scheduler or worker deadlocked", i)
+		}
+	}
+}
+
+func TestSchedulerSingleThreadJobsRespectCapacity(t *testing.T) {
+	w := worker.NewWorker("solo", 1)
+	w.Start()
+	s := NewScheduler([]*worker.Worker{w})
+	s.Run()
+	defer s.Stop()
+
+	var submitted []*job.Job
+	for i := 0; i < 5; i++ {
+		j := job.NewJob("s", "Single", job.AddNumbersJob, 1, job.AddNumbersPayload{X: i, Y: 1})
+		j.ThreadDemand = 1
+		submitted = append(submitted, j)
+		s.Submit(j)
+	}
+
+	for i, j := range submitted {
+		if !waitJobCompletion(j, time.Second) {
+			t.Fatalf("job %d did not complete", i)
+		}
+	}
+	if got := w.AvailableThreads(); got != 1 {
+		t.Errorf("expected the thread to be free again, got %d", got)
+	}
+}
+
+func labeledWorkers() (*worker.Worker, *worker.Worker) {
+	fast := worker.NewWorker("fast", 4)
+	fast.Labels = map[string]string{"tier": "fast", "region": "a"}
+	slow := worker.NewWorker("slow", 4)
+	slow.Labels = map[string]string{"tier": "slow", "region": "b"}
+	return fast, slow
+}
+
+func TestSchedulerHonorsNodeSelector(t *testing.T) {
+	fast, slow := labeledWorkers()
+	if choose(BestFit{}, []*worker.Worker{fast, slow}, &job.Job{}, slow) != slow {
+		t.Fatal("without constraints ties should go to the preferred worker")
+	}
+
+	j := job.NewJob("sel", "Selected", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 1})
+	j.Constraints = &job.Constraints{NodeSelector: map[string]string{"tier": "fast"}}
+	if got := choose(BestFit{}, []*worker.Worker{fast, slow}, j, slow); got != fast {
+		t.Errorf("expected fast worker, got %v", got)
+	}
+
+	soft := job.NewJob("soft", "Soft", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 1})
+	soft.Constraints = &job.Constraints{
+		AntiAffinity: []job.Rule{{Key: "region", Operator: job.OpIn, Values: []string{"a"}, Weight: 5}},
+	}
+	if got := choose(BestFit{}, []*worker.Worker{fast, slow}, soft, fast); got != slow {
+		t.Errorf("expected soft anti-affinity to steer to slow worker, got %v", got)
+	}
+
+	fast.Start()
+	slow.Start()
+	s := NewScheduler([]*worker.Worker{fast, slow})
+	s.Run()
+	defer s.Stop()
+	if err := s.Submit(j); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if !waitJobCompletion(j, time.Second) {
+		t.Fatal("constrained job did not complete")
+	}
+}
+
+func TestSchedulerExplainsUnschedulableJob(t *testing.T) {
+	fast, slow := labeledWorkers()
+	fast.Start()
+	slow.Start()
+	s := NewScheduler([]*worker.Worker{fast, slow})
+	s.Run()
+	defer s.Stop()
+
+	j := job.NewJob("nowhere", "Nowhere", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 1})
+	j.Constraints = &job.Constraints{
+		NodeSelector: map[string]string{"region": "a"},
+		Affinity:     []job.Rule{{Key: "tier", Operator: job.OpIn, Values: []string{"slow"}}},
+	}
+	err := s.Submit(j)
+	if !errors.Is(err, ErrUnschedulable) {
+		t.Fatalf("expected ErrUnschedulable, got %v", err)
+	}
+
+	reasons := s.Explain(j)
+	if !strings.Contains(reasons["fast"], "affinity") || !strings.Contains(reasons["slow"], "node selector") {
+		t.Errorf("unexpected explanation %v", reasons)
+	}
+}
+
+func TestIdleWorkerStealsPlacedJob(t *testing.T) {
+	busy, idle := worker.NewWorker("busy", 1), worker.NewWorker("idle", 1)
+	idle.Start()
+	idle.Pause() // so the scheduler has to place on busy
+	cfg := DefaultConfig()
+	cfg.WorkStealing = true
+	s := NewSchedulerWithConfig([]*worker.Worker{busy, idle}, cfg)
+	s.Run()
+	defer s.Stop()
+
+	j := job.NewJob("stolen", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	if err := s.Submit(j); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(busy.JobQueue) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("job was not dispatched to busy")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// busy has the job reserved but can't start it; idle can
+	busy.Pause()
+	busy.Start()
+	idle.Resume()
+	if !waitJobCompletion(j, time.Second) {
+		t.Fatal("stolen job did not complete")
+	}
+	if idle.Steals() != 1 || busy.Stolen() != 1 {
+		t.Errorf("expected idle to steal the job, got steals=%d stolen=%d", idle.Steals(), busy.Stolen())
+	}
+	if n := busy.AvailableThreads(); n != 1 {
+		t.Errorf("expected busy's reservation to move with the job, %d threads free", n)
 	}
 }
@@ -0,0 +1,193 @@
+package scheduler
+
+import (
+	"container/heap"
+	"errors"
+	"sort"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+// DefaultTenant owns jobs submitted without a tenant
+const DefaultTenant = "default"
+
+// ErrQuotaExceeded is returned by Submit when a tenant already has as many
+// queued jobs as its quota allows
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// TenantConfig sets a tenant's fair-share weight and hard quotas. Zero
+// quotas mean unlimited.
+type TenantConfig struct {
+	Weight     float64 `json:"weight"`
+	MaxThreads int     `json:"max_threads"` // threads held by running jobs at once
+	MaxQueued  int     `json:"max_queued"`  // jobs waiting to be placed
+}
+
+// tenant is one tenant's queue and what it currently holds on the workers
+type tenant struct {
+	name    string
+	cfg     TenantConfig
+	queue   JobQueue
+	running job.Resources
+}
+
+// TenantStatus is a snapshot of one tenant's queue and usage
+type TenantStatus struct {
+	Queued         int
+	RunningThreads int
+	DominantShare  float64
+}
+
+func tenantOf(j *job.Job) string {
+	if j.Tenant == "" {
+		return DefaultTenant
+	}
+	return j.Tenant
+}
+
+// tenantFor returns the named tenant, creating it on first use; caller
+// holds s.mu
+func (s *Scheduler) tenantFor(name string) *tenant {
+	t, ok := s.tenants[name]
+	if !ok {
+		cfg, ok := s.cfg.Tenants[name]
+		if !ok {
+			cfg = s.cfg.DefaultTenant
+		}
+		t = &tenant{name: name, cfg: cfg}
+		s.tenants[name] = t
+	}
+	return t
+}
+
+// totalCapacity is the sum of every worker's capacity; caller holds s.mu
+func (s *Scheduler) totalCapacity() job.Resources {
+	var total job.Resources
+	for _, w := range s.workers {
+		total = total.Add(w.Capacity())
+	}
+	return total
+}
+
+// dominantShare is the tenant's largest share of any resource, divided by
+// its weight. Dominant Resource Fairness serves the tenant with the smallest
+// dominant share first.
+func dominantShare(running, total job.Resources, weight float64) float64 {
+	share := 0.0
+	ratio := func(used, capacity int) {
+		if capacity > 0 {
+			share = max(share, float64(used)/float64(capacity))
+		}
+	}
+	ratio(running.Threads, total.Threads)
+	ratio(running.MemoryMB, total.MemoryMB)
+	for name, c := range total.Custom {
+		ratio(running.Custom[name], c)
+	}
+	if weight <= 0 {
+		weight = 1
+	}
+	return share / weight
+}
+
+// tenantsByShare lists tenants with queued jobs, smallest dominant share
+// first; caller holds s.mu
+func (s *Scheduler) tenantsByShare() []*tenant {
+	total := s.totalCapacity()
+	order := make([]*tenant, 0, len(s.tenants))
+	shares := make(map[*tenant]float64, len(s.tenants))
+	for _, t := range s.tenants {
+		if len(t.queue) == 0 {
+			continue
+		}
+		order = append(order, t)
+		shares[t] = dominantShare(t.running, total, t.cfg.Weight)
+	}
+	sort.Slice(order, func(a, b int) bool {
+		if shares[order[a]] != shares[order[b]] {
+			return shares[order[a]] < shares[order[b]]
+		}
+		return order[a].name < order[b].name
+	})
+	return order
+}
+
+// withinThreadQuota reports whether t can start a job needing threads more
+func (t *tenant) withinThreadQuota(threads int) bool {
+	return t.cfg.MaxThreads == 0 || t.running.Threads+threads <= t.cfg.MaxThreads
+}
+
+// place records that j now holds held on w; caller holds s.mu
+func (s *Scheduler) place(t *tenant, i int, j *job.Job, w *worker.Worker) {
+	heap.Remove(&t.queue, i)
+	s.hold(t, j, w)
+}
+
+// hold charges what j needs on w to t and the policy; caller holds s.mu
+func (s *Scheduler) hold(t *tenant, j *job.Job, w *worker.Worker) {
+	held := w.DemandFor(j)
+	t.running = t.running.Add(held)
+	s.holding[j] = placement{worker: w, held: held}
+	if o, ok := s.policy.(PlacementObserver); ok {
+		o.Placed(j, w)
+	}
+}
+
+// jobStolen is called by a worker that took j from a peer's queue. j's
+// placement follows it, and the peer may have room for something else now.
+func (s *Scheduler) jobStolen(j *job.Job, thief *worker.Worker, held job.Resources) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.holding[j]; ok {
+		t := s.tenantFor(tenantOf(j))
+		t.running = t.running.Sub(p.held).Add(held)
+		s.holding[j] = placement{worker: thief, held: held}
+		if o, ok := s.policy.(PlacementObserver); ok {
+			o.Released(j, p.held)
+			o.Placed(j, thief)
+		}
+	}
+	s.cond.Broadcast()
+}
+
+// jobFreed is called by workers when a job hands back its resources.
+// Preempted jobs that didn't finish go back into their tenant's queue.
+func (s *Scheduler) jobFreed(j *job.Job, _ job.Resources) {
+	s.mu.Lock()
+	t := s.tenantFor(tenantOf(j))
+	if p, ok := s.holding[j]; ok {
+		delete(s.holding, j)
+		t.running = t.running.Sub(p.held)
+		if o, ok := s.policy.(PlacementObserver); ok {
+			o.Released(j, p.held)
+		}
+	}
+	delete(s.preempting, j)
+	// A preempted shard isn't requeued by itself; shardDone requeues its
+	// gang's parent once every shard is back
+	shard := s.shardDone(j)
+	if !shard && j.Preempted() && j.Status != job.Completed && j.Status != job.Failed {
+		j.Requeue()
+		heap.Push(&t.queue, j)
+		j.Log().Info("requeued after preemption", "attempts", j.Attempts)
+	}
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// Tenants reports queue length and usage for every tenant seen so far
+func (s *Scheduler) Tenants() map[string]TenantStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := s.totalCapacity()
+	out := make(map[string]TenantStatus, len(s.tenants))
+	for name, t := range s.tenants {
+		out[name] = TenantStatus{
+			Queued:         len(t.queue),
+			RunningThreads: t.running.Threads,
+			DominantShare:  dominantShare(t.running, total, t.cfg.Weight),
+		}
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+func tenantJob(id, tenant string, threads int) *job.Job {
+	j := job.NewJob(id, "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	j.ThreadDemand = threads
+	j.Tenant = tenant
+	return j
+}
+
+func TestSubmitEnforcesQueuedJobQuota(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Tenants = map[string]TenantConfig{"noisy": {Weight: 1, MaxQueued: 2}}
+	// Not running, so everything stays queued
+	s := NewSchedulerWithConfig(createTestWorkers(), cfg)
+
+	for i, id := range []string{"n1", "n2"} {
+		if err := s.Submit(tenantJob(id, "noisy", 1)); err != nil {
+			t.Fatalf("job %d: unexpected error %v", i, err)
+		}
+	}
+	if err := s.Submit(tenantJob("n3", "noisy", 1)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	// Other tenants are unaffected
+	if err := s.Submit(tenantJob("q1", "quiet", 1)); err != nil {
+		t.Fatalf("quiet tenant was rejected: %v", err)
+	}
+
+	status := s.Tenants()
+	if status["noisy"].Queued != 2 || status["quiet"].Queued != 1 {
+		t.Errorf("unexpected tenant status %+v", status)
+	}
+}
+
+func TestSubmitRejectsJobLargerThanTenantThreadQuota(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DefaultTenant = TenantConfig{Weight: 1, MaxThreads: 2}
+	s := NewSchedulerWithConfig(createTestWorkers(), cfg)
+
+	if err := s.Submit(tenantJob("big", "", 3)); !errors.Is(err, ErrUnschedulable) {
+		t.Fatalf("expected ErrUnschedulable, got %v", err)
+	}
+}
+
+func TestTenantsByShareUsesDominantResource(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Tenants = map[string]TenantConfig{"heavy": {Weight: 4}}
+	s := NewSchedulerWithConfig(createTestWorkers(), cfg) // 6 threads in total
+
+	for _, name := range []string{"a", "b", "heavy"} {
+		if err := s.Submit(tenantJob(name+"-job", name, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.tenants["a"].running = job.Resources{Threads: 3}
+	s.tenants["b"].running = job.Resources{Threads: 2}
+	s.tenants["heavy"].running = job.Resources{Threads: 4}
+
+	// shares: heavy 4/6/4, b 2/6, a 3/6
+	var got []string
+	for _, t := range s.tenantsByShare() {
+		got = append(got, t.name)
+	}
+	want := []string{"heavy", "b", "a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, got)
+		}
+	}
+}
+
+func TestTenantThreadQuotaReleasedWhenJobsFinish(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DefaultTenant = TenantConfig{Weight: 1, MaxThreads: 1}
+	s := NewSchedulerWithConfig(createTestWorkers(), cfg)
+	s.Run()
+	defer s.Stop()
+
+	var submitted []*job.Job
+	for _, id := range []string{"t1", "t2", "t3", "t4"} {
+		j := tenantJob(id, "", 1)
+		if err := s.Submit(j); err != nil {
+			t.Fatal(err)
+		}
+		submitted = append(submitted, j)
+	}
+	for _, j := range submitted {
+		if !waitJobCompletion(j, time.Second) {
+			t.Fatalf("job %s did not complete; tenant quota never freed", j.ID)
+		}
+	}
+
+	// Give the last release callback a moment to land
+	deadline := time.Now().Add(time.Second)
+	for s.Tenants()[DefaultTenant].RunningThreads != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("tenant still holds %d threads", s.Tenants()[DefaultTenant].RunningThreads)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestQueuedByPriorityCountsAllTenants(t *testing.T) {
+	// Not running, so everything stays queued
+	s := NewScheduler(createTestWorkers())
+	for i, tenant := range []string{"a", "b", "a"} {
+		j := tenantJob(string(rune('x'+i)), tenant, 1)
+		j.Priority = 1 + i%2
+		if err := s.Submit(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := s.QueuedByPriority()
+	if got[1] != 2 || got[2] != 1 {
+		t.Errorf("expected 2 jobs at priority 1 and 1 at priority 2, got %v", got)
+	}
+}
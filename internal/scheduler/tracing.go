@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/tracing"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/worker"
+)
+
+func jobAttributes(j *job.Job) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		attribute.String("job.id", j.ID),
+		attribute.String("job.type", string(j.Type)),
+		attribute.Int("job.priority", j.Priority),
+	)
+}
+
+// traceSubmit starts the span for a Submit call and makes it the parent of
+// j's later spans; end it with endSubmit
+func traceSubmit(j *job.Job) trace.Span {
+	ctx, span := tracing.Tracer.Start(j.TraceContext(), "scheduler.submit", jobAttributes(j))
+	if tp := tracing.Inject(ctx); tp != "" {
+		j.TraceParent = tp
+	}
+	return span
+}
+
+// endSubmit ends a traceSubmit span with Submit's outcome
+func endSubmit(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceQueueWait records the time j spent queued as a span that started
+// when it was submitted and ends at placement
+func traceQueueWait(j *job.Job, placed time.Time) {
+	_, span := tracing.Tracer.Start(j.TraceContext(), "scheduler.queue_wait",
+		jobAttributes(j),
+		trace.WithAttributes(attribute.Int("job.attempt", j.Attempts+1)),
+		trace.WithTimestamp(j.CreatedAt))
+	span.End(trace.WithTimestamp(placed))
+}
+
+// traceDispatch covers handing j to w's JobQueue, which blocks while the
+// queue is full
+func traceDispatch(j *job.Job, w *worker.Worker) trace.Span {
+	_, span := tracing.Tracer.Start(j.TraceContext(), "scheduler.dispatch",
+		jobAttributes(j), trace.WithAttributes(attribute.String("worker.id", w.ID)))
+	return span
+}
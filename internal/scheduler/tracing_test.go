@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/tracing"
+)
+
+var spans = tracetest.NewSpanRecorder()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+}
+
+func TestJobSpansJoinSubmittersTrace(t *testing.T) {
+	s := NewScheduler(createTestWorkers())
+	s.Run()
+	defer s.Stop()
+
+	ctx, root := tracing.Tracer.Start(context.Background(), "POST /jobs")
+	j := job.NewJob("traced", "Sum", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3, 4}})
+	j.ThreadDemand = 2
+	j.TraceParent = tracing.Inject(ctx)
+	j.CreatedAt = time.Now()
+	if err := s.Submit(j); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+	if !waitJobCompletion(j, time.Second) {
+		t.Fatal("job did not complete")
+	}
+
+	// The execute span ends just after the job is marked Completed
+	byName := map[string][]sdktrace.ReadOnlySpan{}
+	deadline := time.Now().Add(time.Second)
+	for len(byName["worker.execute"]) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		byName = map[string][]sdktrace.ReadOnlySpan{}
+		for _, sp := range spans.Ended() {
+			if sp.SpanContext().TraceID() == root.SpanContext().TraceID() {
+				byName[sp.Name()] = append(byName[sp.Name()], sp)
+			}
+		}
+	}
+
+	if len(byName["scheduler.submit"]) != 1 {
+		t.Fatalf("expected one scheduler.submit span in the trace, got %d", len(byName["scheduler.submit"]))
+	}
+	submit := byName["scheduler.submit"][0]
+	if submit.Parent().SpanID() != root.SpanContext().SpanID() {
+		t.Error("scheduler.submit isn't a child of the request span")
+	}
+	for _, name := range []string{"scheduler.queue_wait", "scheduler.dispatch", "worker.execute"} {
+		if len(byName[name]) != 1 {
+			t.Fatalf("expected one %s span in the trace, got %d", name, len(byName[name]))
+		}
+		if byName[name][0].Parent().SpanID() != submit.SpanContext().SpanID() {
+			t.Errorf("%s isn't a child of the submit span", name)
+		}
+	}
+	execute := byName["worker.execute"][0]
+	if len(byName["job.execute_chunk"]) != 2 {
+		t.Fatalf("expected a chunk span per thread, got %d", len(byName["job.execute_chunk"]))
+	}
+	for _, c := range byName["job.execute_chunk"] {
+		if c.Parent().SpanID() != execute.SpanContext().SpanID() {
+			t.Error("chunk span isn't a child of the execute span")
+		}
+	}
+}
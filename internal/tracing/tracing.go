@@ -0,0 +1,72 @@
+// Package tracing wires up OpenTelemetry and carries W3C trace context from
+// the submitting request through scheduling and execution
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is shared by every package. It follows whatever provider Setup
+// (or a test) installs, even if that happens after this is initialised.
+var Tracer = otel.Tracer("github.com/samrichell-smith/distributed-job-scheduler")
+
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Setup installs a tracer provider and the W3C propagator. Spans are
+// exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT (or the traces
+// specific variant) is set; otherwise they're only used for IDs and
+// propagation. The returned function flushes and stops the provider.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	name := os.Getenv("OTEL_SERVICE_NAME")
+	if name == "" {
+		name = "job-scheduler"
+	}
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(name))),
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator)
+	return tp.Shutdown, nil
+}
+
+// Inject returns the traceparent header for ctx's span, "" if there is none
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier["traceparent"]
+}
+
+// Extract is the inverse of Inject. An empty or malformed traceparent gives
+// a context without a span, so spans started from it are new roots.
+func Extract(traceparent string) context.Context {
+	if traceparent == "" {
+		return context.Background()
+	}
+	return propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// TraceID returns the trace ID in a traceparent, "" if it has none
+func TraceID(traceparent string) string {
+	sc := trace.SpanContextFromContext(Extract(traceparent))
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
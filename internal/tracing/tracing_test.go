@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var spans = tracetest.NewSpanRecorder()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+}
+
+func TestInjectExtractRoundTrip(t *testing.T) {
+	ctx, span := Tracer.Start(context.Background(), "root")
+	defer span.End()
+
+	tp := Inject(ctx)
+	if tp == "" {
+		t.Fatal("expected a traceparent")
+	}
+	got := trace.SpanContextFromContext(Extract(tp))
+	if got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("round trip lost the span: %v", got)
+	}
+	if TraceID(tp) != span.SpanContext().TraceID().String() {
+		t.Errorf("unexpected trace id %q", TraceID(tp))
+	}
+
+	if Inject(context.Background()) != "" || TraceID("") != "" || TraceID("garbage") != "" {
+		t.Error("expected nothing from contexts without a span")
+	}
+}
+
+func TestGinContinuesClientTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Gin())
+	var handlerTrace string
+	r.POST("/jobs", func(c *gin.Context) {
+		handlerTrace = Inject(c.Request.Context())
+		c.Status(http.StatusAccepted)
+	})
+
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+	req.Header.Set("traceparent", parent)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	if TraceID(handlerTrace) != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("handler span isn't in the client's trace: %q", handlerTrace)
+	}
+	if rec.Header().Get("traceparent") != handlerTrace {
+		t.Errorf("expected the server span in the response, got %q", rec.Header().Get("traceparent"))
+	}
+
+	var server sdktrace.ReadOnlySpan
+	for _, s := range spans.Ended() {
+		if s.Name() == "POST /jobs" {
+			server = s
+		}
+	}
+	if server == nil {
+		t.Fatal("no server span recorded")
+	}
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" || server.SpanKind() != trace.SpanKindServer {
+		t.Errorf("unexpected server span parent %v kind %v", server.Parent().SpanID(), server.SpanKind())
+	}
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"sync"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+// admission is the single source of truth for a worker's capacity. Every job
+// holds its resources from the moment it is assigned until it finishes,
+// single-threaded jobs included, so free() is always the capacity minus the
+// demand of all assigned jobs.
+//
+// Resources are taken all-or-nothing. Taking threads one at a time let two
+// jobs each grab part of the pool and then wait on each other forever.
+type admission struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	capacity job.Resources
+	used     job.Resources
+}
+
+func newAdmission(capacity job.Resources) *admission {
+	a := &admission{capacity: capacity}
+	a.cond = sync.NewCond(&a.mu)
+	return a
+}
+
+// tryAcquire takes r if all of it is free right now
+func (a *admission) tryAcquire(r job.Resources) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !r.Fits(a.capacity.Sub(a.used)) {
+		return false
+	}
+	a.used = a.used.Add(r)
+	return true
+}
+
+// acquire blocks until r is free and then takes all of it at once
+func (a *admission) acquire(r job.Resources) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for !r.Fits(a.capacity.Sub(a.used)) {
+		a.cond.Wait()
+	}
+	a.used = a.used.Add(r)
+}
+
+func (a *admission) release(r job.Resources) {
+	a.mu.Lock()
+	a.used = a.used.Sub(r)
+	a.mu.Unlock()
+	a.cond.Broadcast()
+}
+
+func (a *admission) free() job.Resources {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.capacity.Sub(a.used)
+}
+
+func (a *admission) total() job.Resources {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.capacity
+}
+
+// resize changes the thread count. Shrinking below what is in use is fine:
+// running jobs keep their threads and nothing new fits until they finish.
+func (a *admission) resize(threads int) {
+	a.mu.Lock()
+	a.capacity.Threads = threads
+	a.mu.Unlock()
+	a.cond.Broadcast()
+}
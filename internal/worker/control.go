@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"fmt"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+// Resize changes how many threads the worker offers. Growing starts more
+// consumers and frees the new threads right away. Shrinking takes effect as
+// threads come back: running jobs keep what they hold, and nothing new
+// starts until they fit in the smaller pool.
+func (w *Worker) Resize(threads int) error {
+	if threads < 1 {
+		return fmt.Errorf("worker %s: threads must be at least 1, got %d", w.ID, threads)
+	}
+	w.capacity.resize(threads)
+
+	w.poolMu.Lock()
+	w.NumThreads = threads
+	if w.started {
+		if threads > w.consumers {
+			// Consumers still on their way out can stay instead
+			kept := min(w.retiring, threads-w.consumers)
+			w.retiring -= kept
+			w.startConsumers(threads - w.consumers - kept)
+		} else {
+			w.retiring += w.consumers - threads
+		}
+		w.consumers = threads
+	}
+	w.poolMu.Unlock()
+
+	w.queue.wake()
+	return nil
+}
+
+// retire reports whether the calling consumer should exit because the
+// worker shrank
+func (w *Worker) retire() bool {
+	w.poolMu.Lock()
+	defer w.poolMu.Unlock()
+	if w.retiring == 0 {
+		return false
+	}
+	w.retiring--
+	return true
+}
+
+// State is whether a worker takes new jobs
+type State int32
+
+const (
+	// Active workers take jobs from the scheduler and steal from peers
+	Active State = iota
+	// Draining workers run what is already assigned to them and take
+	// nothing new
+	Draining
+	// Paused workers start nothing; running jobs carry on
+	Paused
+)
+
+func (s State) String() string {
+	switch s {
+	case Draining:
+		return "draining"
+	case Paused:
+		return "paused"
+	default:
+		return "active"
+	}
+}
+
+func (w *Worker) State() State {
+	return State(w.state.Load())
+}
+
+// Pause stops the worker starting jobs, its own or stolen ones. Jobs queued
+// here wait until Resume unless Unqueue takes them.
+func (w *Worker) Pause() {
+	w.setState(Paused)
+}
+
+// Drain lets the worker finish the jobs assigned to it but take no more
+func (w *Worker) Drain() {
+	w.setState(Draining)
+}
+
+// Resume makes a paused or draining worker active again
+func (w *Worker) Resume() {
+	w.setState(Active)
+	for _, p := range w.peerList() {
+		p.queue.wake() // it may take on its peers' jobs again
+	}
+}
+
+func (w *Worker) setState(s State) {
+	w.state.Store(int32(s))
+	w.queue.wake()
+}
+
+// Unqueue takes every job ok accepts out of the worker's queue, including
+// ones still buffered in JobQueue, and gives back their reservations. The
+// jobs haven't started; the caller decides where they go next.
+func (w *Worker) Unqueue(ok func(*job.Job) bool) []*job.Job {
+	var out, keep []*job.Job
+	for drained := false; !drained; {
+		select {
+		case j, open := <-w.JobQueue:
+			if !open {
+				drained = true
+			} else if ok(j) {
+				out = append(out, j)
+			} else {
+				keep = append(keep, j)
+			}
+		default:
+			drained = true
+		}
+	}
+	for _, j := range keep {
+		w.queue.pushBack(j)
+	}
+
+	q := w.queue
+	q.mu.Lock()
+	for j := q.takeFront(ok); j != nil; j = q.takeFront(ok) {
+		out = append(out, j)
+	}
+	q.gen++
+	q.mu.Unlock()
+
+	for _, j := range out {
+		w.CancelReservation(j)
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+// runAll pushes n jobs sleeping d onto w and returns how long they took to
+// hand their threads back
+func runAll(t *testing.T, w *Worker, n int, d time.Duration) time.Duration {
+	t.Helper()
+	done := make(chan *job.Job, n)
+	w.OnCapacityFreed(func(j *job.Job, _ job.Resources) { done <- j })
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		w.JobQueue <- job.NewJob("s", "Sleep", sleepJob, 1, d)
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case j := <-done:
+			if j.Status != job.Completed {
+				t.Fatalf("expected Completed, got %s", j.Status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not complete")
+		}
+	}
+	return time.Since(start)
+}
+
+func TestResizeGrowsAndShrinksThePool(t *testing.T) {
+	w := NewWorker("w1", 1)
+	w.Start()
+	defer w.Stop()
+
+	if err := w.Resize(3); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.AvailableThreads(); got != 3 {
+		t.Fatalf("expected 3 free threads after growing, got %d", got)
+	}
+	if took := runAll(t, w, 3, 100*time.Millisecond); took > 250*time.Millisecond {
+		t.Errorf("expected 3 jobs to run side by side on 3 threads, took %s", took)
+	}
+
+	if err := w.Resize(1); err != nil {
+		t.Fatal(err)
+	}
+	if took := runAll(t, w, 2, 50*time.Millisecond); took < 100*time.Millisecond {
+		t.Errorf("expected 2 jobs to run one after the other on 1 thread, took %s", took)
+	}
+	w.poolMu.Lock()
+	consumers, retiring := w.consumers, w.retiring
+	w.poolMu.Unlock()
+	if consumers != 1 || retiring != 0 {
+		t.Errorf("expected the extra consumers to exit, got consumers=%d retiring=%d", consumers, retiring)
+	}
+
+	if err := w.Resize(0); err == nil {
+		t.Error("expected an error for 0 threads")
+	}
+}
+
+func TestShrinkingBelowHeldThreads(t *testing.T) {
+	w := NewWorker("w1", 4)
+	j := job.NewJob("1", "Sum", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1}})
+	j.ThreadDemand = 3
+	if !w.TryReserve(j) {
+		t.Fatal("reservation failed")
+	}
+	if err := w.Resize(2); err != nil {
+		t.Fatal(err)
+	}
+	small := job.NewJob("2", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})
+	if w.TryReserve(small) {
+		t.Error("expected nothing to fit until the held threads come back")
+	}
+	w.CancelReservation(j)
+	if got := w.AvailableThreads(); got != 2 {
+		t.Errorf("expected the smaller pool to be free, got %d threads", got)
+	}
+}
+
+func TestPausedWorkerStartsNothing(t *testing.T) {
+	w := NewWorker("w1", 2)
+	w.Start()
+	defer w.Stop()
+	w.Pause()
+
+	j := job.NewJob("1", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	if !w.TryReserve(j) {
+		t.Fatal("reservation failed")
+	}
+	w.JobQueue <- j
+	time.Sleep(50 * time.Millisecond)
+	if j.Status != job.Pending {
+		t.Fatalf("expected the job to wait on a paused worker, got %s", j.Status)
+	}
+
+	taken := w.Unqueue(func(*job.Job) bool { return true })
+	if len(taken) != 1 || taken[0] != j || w.Queued() != 0 {
+		t.Fatalf("expected Unqueue to take the job, got %v", taken)
+	}
+	if got := w.AvailableThreads(); got != 2 {
+		t.Errorf("expected the reservation to be given back, got %d threads free", got)
+	}
+
+	w.Resume()
+	if w.State() != Active {
+		t.Fatalf("expected active, got %s", w.State())
+	}
+	runAll(t, w, 1, time.Millisecond)
+}
+
+func TestDrainingWorkerDoesntSteal(t *testing.T) {
+	owner, thief := NewWorker("owner", 1), NewWorker("thief", 1)
+	Connect([]*Worker{owner, thief})
+	thief.Drain()
+
+	busy := job.NewJob("1", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})
+	if !owner.TryReserve(busy) {
+		t.Fatal("reservation failed")
+	}
+	j := job.NewJob("2", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{})
+	owner.queue.pushBack(j)
+
+	thief.Start()
+	defer thief.Stop()
+	time.Sleep(50 * time.Millisecond)
+	if thief.Steals() != 0 || owner.Queued() != 1 {
+		t.Error("a draining worker took a job from its peer")
+	}
+}
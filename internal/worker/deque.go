@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"sync"
+
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+)
+
+// deque holds the jobs assigned to a worker that haven't started yet. The
+// owner takes from the front, oldest first; thieves take from the back so
+// they rarely contend with the owner for the same job.
+type deque struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []*job.Job
+	closed bool
+	gen    uint64 // bumped on every change that might let a consumer proceed
+}
+
+func newDeque() *deque {
+	d := &deque{}
+	d.cond = sync.NewCond(&d.mu)
+	return d
+}
+
+func (d *deque) pushBack(j *job.Job) {
+	d.mu.Lock()
+	d.items = append(d.items, j)
+	d.gen++
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+// takeFront removes the oldest job ok accepts; caller holds d.mu
+func (d *deque) takeFront(ok func(*job.Job) bool) *job.Job {
+	for i, j := range d.items {
+		if ok(j) {
+			d.items = append(d.items[:i], d.items[i+1:]...)
+			return j
+		}
+	}
+	return nil
+}
+
+// stealBack removes the newest job ok accepts
+func (d *deque) stealBack(ok func(*job.Job) bool) *job.Job {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := len(d.items) - 1; i >= 0; i-- {
+		if j := d.items[i]; ok(j) {
+			d.items = append(d.items[:i], d.items[i+1:]...)
+			return j
+		}
+	}
+	return nil
+}
+
+// wake tells waiting consumers to look again
+func (d *deque) wake() {
+	d.mu.Lock()
+	d.gen++
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+func (d *deque) close() {
+	d.mu.Lock()
+	d.closed = true
+	d.gen++
+	d.mu.Unlock()
+	d.cond.Broadcast()
+}
+
+func (d *deque) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.items)
+}
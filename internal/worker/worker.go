@@ -1,17 +1,46 @@
 package worker
 
 import (
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
+	"github.com/samrichell-smith/distributed-job-scheduler/internal/tracing"
 )
 
 type Worker struct {
-	ID          string
-	JobQueue    chan *job.Job
-	NumThreads  int
-	FreeThreads chan struct{}
-	WaitGroup   sync.WaitGroup
+	ID         string
+	JobQueue   chan *job.Job
+	NumThreads int
+	MemoryMB   int               // 0 means memory isn't tracked on this worker
+	Resources  map[string]int    // custom resources, e.g. "gpu-license": 1
+	Labels     map[string]string // e.g. "tier": "fast", matched by job constraints
+	WaitGroup  sync.WaitGroup
+	crashes    atomic.Int64 // handler panics recovered on this worker
+	steals     atomic.Int64 // jobs this worker took from a peer's queue
+	stolen     atomic.Int64 // jobs peers took from this worker's queue
+	busy       atomic.Int32 // consumers running a job
+	heartbeat  atomic.Int64 // unix nanos the intake loop last ticked, 0 once stopped
+
+	queue      *deque                    // jobs from JobQueue waiting to start
+	peers      atomic.Pointer[[]*Worker] // workers this one may steal from
+	capacity   *admission
+	reservedMu sync.Mutex
+	reserved   map[*job.Job]job.Resources // claimed by TryReserve, not yet running
+	onFreed    func(j *job.Job, freed job.Resources)
+	onSteal    func(j *job.Job, thief *Worker, held job.Resources)
+	state      atomic.Int32 // a State
+
+	poolMu    sync.Mutex
+	started   bool
+	consumers int // consumer goroutines meant to be running
+	retiring  int // consumers asked to exit by a shrinking Resize
 }
 
 func NewWorker(id string, numThreads int) *Worker {
@@ -19,71 +48,482 @@ func NewWorker(id string, numThreads int) *Worker {
 }
 
 func NewWorkerWithQueueSize(id string, numThreads int, queueSize int) *Worker {
-	w := &Worker{
-		ID:          id,
-		JobQueue:    make(chan *job.Job, queueSize),
-		NumThreads:  numThreads,
-		FreeThreads: make(chan struct{}, numThreads),
-	}
+	return NewWorkerWithResources(id, job.Resources{Threads: numThreads}, queueSize)
+}
 
-	// Initialize all threads as free
-	for i := 0; i < numThreads; i++ {
-		w.FreeThreads <- struct{}{}
+// NewWorkerWithResources creates a worker advertising the given capacity
+func NewWorkerWithResources(id string, capacity job.Resources, queueSize int) *Worker {
+	return &Worker{
+		ID:         id,
+		JobQueue:   make(chan *job.Job, queueSize),
+		NumThreads: capacity.Threads,
+		MemoryMB:   capacity.MemoryMB,
+		Resources:  capacity.Custom,
+		queue:      newDeque(),
+		capacity:   newAdmission(capacity),
+		reserved:   make(map[*job.Job]job.Resources),
 	}
-
-	return w
 }
 
+// Start moves jobs from JobQueue onto the worker's deque and runs one
+// consumer per thread. Every running job holds at least one thread, so there
+// is never a job waiting on a consumer while threads are free.
 func (w *Worker) Start() {
-	for i := 0; i < w.NumThreads; i++ {
+	w.heartbeat.Store(time.Now().UnixNano())
+	w.WaitGroup.Add(1)
+	go func() {
+		defer w.WaitGroup.Done()
+		tick := time.NewTicker(HeartbeatInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case j, ok := <-w.JobQueue:
+				if !ok {
+					w.heartbeat.Store(0)
+					w.queue.close()
+					return
+				}
+				w.queue.pushBack(j)
+				for _, p := range w.peerList() {
+					p.queue.wake() // an idle peer may want it
+				}
+			case now := <-tick.C:
+				w.heartbeat.Store(now.UnixNano())
+			}
+		}
+	}()
+
+	w.poolMu.Lock()
+	w.started = true
+	w.consumers = w.Capacity().Threads
+	w.startConsumers(w.consumers)
+	w.poolMu.Unlock()
+}
+
+// startConsumers runs n more consumers; caller holds w.poolMu
+func (w *Worker) startConsumers(n int) {
+	for i := 0; i < n; i++ {
 		w.WaitGroup.Add(1)
-		go func(threadID int) {
+		go func() {
 			defer w.WaitGroup.Done()
 
-			for job := range w.JobQueue {
-				w.processJob(job)
+			for {
+				j, held, ok := w.next()
+				if !ok {
+					return
+				}
+				w.busy.Add(1)
+				w.run(j, held)
+				w.busy.Add(-1)
 			}
-		}(i)
+		}()
 	}
 }
 
-func (w *Worker) processJob(j *job.Job) {
-	j.Status = job.Running
-
-	threadsToUse := j.ThreadDemand
-	if threadsToUse <= 1 {
-		// Single-threaded job
-		j.Execute()
-	} else {
-		// Acquire the requested number of threads (blocks until available)
-		for i := 0; i < threadsToUse; i++ {
-			<-w.FreeThreads
+// HeartbeatInterval is how often a started worker's intake loop records
+// that it's alive
+var HeartbeatInterval = time.Second
+
+// LastHeartbeat is when the worker last showed it was running, zero if it
+// hasn't started or has stopped
+func (w *Worker) LastHeartbeat() time.Time {
+	if n := w.heartbeat.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// Connect lets every worker steal queued jobs from the others. It can be
+// called again, e.g. after a worker is added or removed.
+func Connect(workers []*Worker) {
+	for _, w := range workers {
+		var peers []*Worker
+		for _, p := range workers {
+			if p != w {
+				peers = append(peers, p)
+			}
 		}
+		w.peers.Store(&peers)
+	}
+}
+
+// Disconnect stops w stealing from other workers
+func Disconnect(w *Worker) {
+	w.peers.Store(nil)
+}
 
-		// Execute in multiple goroutines
-		var wg sync.WaitGroup
-		for i := 0; i < threadsToUse; i++ {
-			wg.Add(1)
-			go func(threadID int) {
-				defer wg.Done()
-				j.ExecuteChunk(threadID, threadsToUse)
-			}(i)
+func (w *Worker) peerList() []*Worker {
+	if p := w.peers.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// next blocks until there is a job this worker can start right now and
+// returns it with the resources it holds. Jobs the scheduler reserved here
+// always can; jobs pushed without a reservation wait for room. When its own
+// queue has nothing startable, the worker steals from its peers. Draining
+// workers don't steal and paused ones start nothing. ok is false once
+// JobQueue is closed and drained, or when Resize has retired this consumer.
+func (w *Worker) next() (j *job.Job, held job.Resources, ok bool) {
+	q := w.queue
+	for {
+		if w.retire() {
+			return nil, job.Resources{}, false
 		}
+		state := w.State()
+		q.mu.Lock()
+		if state != Paused {
+			if j = q.takeFront(w.startable); j != nil {
+				q.mu.Unlock()
+				return j, w.claim(j), true
+			}
+		}
+		if q.closed && len(q.items) == 0 {
+			q.mu.Unlock()
+			return nil, job.Resources{}, false
+		}
+		gen := q.gen
+		q.mu.Unlock()
 
-		wg.Wait() // wait for all threads to finish
+		if state == Active {
+			if j, held = w.steal(); j != nil {
+				return j, held, true
+			}
+		}
+
+		q.mu.Lock()
+		if q.gen == gen {
+			q.cond.Wait()
+		}
+		q.mu.Unlock()
+	}
+}
 
-		// Release threads back to the pool
-		for i := 0; i < threadsToUse; i++ {
-			w.FreeThreads <- struct{}{}
+// startable reports whether j can start here now, taking its resources if it
+// wasn't reserved. Called with the deque locked.
+func (w *Worker) startable(j *job.Job) bool {
+	w.reservedMu.Lock()
+	_, ok := w.reserved[j]
+	w.reservedMu.Unlock()
+	if ok {
+		return true
+	}
+	n := clamp(w.DemandFor(j), w.Capacity())
+	if !w.capacity.tryAcquire(n) {
+		return false
+	}
+	w.reservedMu.Lock()
+	w.reserved[j] = n
+	w.reservedMu.Unlock()
+	return true
+}
+
+// steal takes the newest job from a peer that the peer can't start yet but
+// this worker can. A job the scheduler reserved on the peer only moves while
+// the peer can't start anything, and its reservation moves with it: the
+// peer gets the resources back and this worker holds them instead.
+func (w *Worker) steal() (*job.Job, job.Resources) {
+	for _, p := range w.peerList() {
+		var held, given job.Resources
+		var reserved bool
+		j := p.queue.stealBack(func(j *job.Job) bool {
+			given, reserved = p.reservation(j)
+			if reserved && !p.stalled() {
+				return false
+			}
+			if !reserved && p.State() != Paused && p.DemandFor(j).Fits(p.Available()) {
+				return false
+			}
+			if ok, _ := j.Constraints.Allows(w.Labels); !ok || !w.CanEverRun(j) {
+				return false
+			}
+			held = w.DemandFor(j)
+			if !w.capacity.tryAcquire(held) {
+				return false
+			}
+			if reserved {
+				p.reservedMu.Lock()
+				delete(p.reserved, j)
+				p.reservedMu.Unlock()
+			}
+			return true
+		})
+		if j == nil {
+			continue
+		}
+		if reserved {
+			p.capacity.release(given)
+			p.queue.wake()
 		}
+		j.Log().Debug("stolen by idle worker", "from", p.ID, "worker", w.ID)
+		w.steals.Add(1)
+		p.stolen.Add(1)
+		if w.onSteal != nil {
+			w.onSteal(j, w, held)
+		}
+		return j, held
+	}
+	return nil, job.Resources{}
+}
+
+// reservation is what TryReserve claimed for j, if it did
+func (w *Worker) reservation(j *job.Job) (job.Resources, bool) {
+	w.reservedMu.Lock()
+	defer w.reservedMu.Unlock()
+	n, ok := w.reserved[j]
+	return n, ok
+}
+
+// stalled reports whether the worker can't start a job right now: it is
+// paused, or every consumer it has is busy (none at all before Start)
+func (w *Worker) stalled() bool {
+	if w.State() == Paused {
+		return true
+	}
+	w.poolMu.Lock()
+	defer w.poolMu.Unlock()
+	return int(w.busy.Load()) >= w.consumers
+}
+
+// OnSteal registers fn to be called when this worker steals j from a peer,
+// before it runs it. The scheduler uses it to follow jobs it placed.
+func (w *Worker) OnSteal(fn func(j *job.Job, thief *Worker, held job.Resources)) {
+	w.onSteal = fn
+}
+
+// Capacity is everything this worker can offer when idle
+func (w *Worker) Capacity() job.Resources {
+	return w.capacity.total()
+}
+
+// DemandFor is what j would hold on this worker. Workers that don't track
+// memory ignore the job's memory demand.
+func (w *Worker) DemandFor(j *job.Job) job.Resources {
+	d := j.Demand()
+	if w.MemoryMB == 0 {
+		d.MemoryMB = 0
+	}
+	return d
+}
+
+// CanEverRun reports whether j would fit on this worker when it is idle
+func (w *Worker) CanEverRun(j *job.Job) bool {
+	return w.DemandFor(j).Fits(w.Capacity())
+}
+
+// TryReserve claims j's resources without blocking. The claim is held until
+// the worker has finished running j, so Available already accounts for jobs
+// that are assigned but still sitting in JobQueue.
+func (w *Worker) TryReserve(j *job.Job) bool {
+	n := w.DemandFor(j)
+	if !w.capacity.tryAcquire(n) {
+		return false
+	}
+	w.reservedMu.Lock()
+	w.reserved[j] = n
+	w.reservedMu.Unlock()
+	return true
+}
+
+// CancelReservation gives back the resources claimed for j if it never ran
+func (w *Worker) CancelReservation(j *job.Job) {
+	w.reservedMu.Lock()
+	n, ok := w.reserved[j]
+	delete(w.reserved, j)
+	w.reservedMu.Unlock()
+	if ok {
+		w.release(j, n)
+	}
+}
+
+// OnCapacityFreed registers fn to be called whenever a job hands its
+// resources back, whether it finished or its reservation was cancelled. The
+// scheduler uses it to retry jobs that were waiting.
+func (w *Worker) OnCapacityFreed(fn func(j *job.Job, freed job.Resources)) {
+	w.onFreed = fn
+}
+
+// claim returns the resources held for j, taking them now if the job was
+// pushed onto JobQueue without a reservation. Such jobs are clamped to the
+// worker's capacity rather than left blocking forever.
+func (w *Worker) claim(j *job.Job) job.Resources {
+	w.reservedMu.Lock()
+	n, ok := w.reserved[j]
+	delete(w.reserved, j)
+	w.reservedMu.Unlock()
+	if ok {
+		return n
+	}
+	n = clamp(w.DemandFor(j), w.Capacity())
+	w.capacity.acquire(n)
+	return n
+}
+
+func (w *Worker) release(j *job.Job, n job.Resources) {
+	w.capacity.release(n)
+	w.queue.wake()
+	if w.onFreed != nil {
+		w.onFreed(j, n)
 	}
+}
+
+func (w *Worker) processJob(j *job.Job) {
+	w.run(j, w.claim(j))
+}
 
-	j.Status = job.Completed
+func (w *Worker) run(j *job.Job, held job.Resources) {
+	j.MarkRunning()
+
+	w.execute(j, held, j.ExecuteChunk)
 }
 
-// Helper to see how many threads are currently free
+// execute runs j on the resources it holds and hands them back afterwards,
+// even if the handler panics. The job's final status is settled before the
+// resources are released, so the scheduler sees it when notified.
+func (w *Worker) execute(j *job.Job, held job.Resources, chunk func(threadID, totalThreads int)) {
+	defer w.release(j, held)
+	ctx, span := tracing.Tracer.Start(j.TraceContext(), "worker.execute", trace.WithAttributes(
+		attribute.String("job.id", j.ID),
+		attribute.String("job.type", string(j.Type)),
+		attribute.String("worker.id", w.ID),
+		attribute.Int("threads", held.Threads),
+		attribute.Int("job.attempt", j.Attempts),
+	))
+	j.SetTraceContext(ctx)
+	defer endExecuteSpan(j, span)
+	defer finish(j)
+	// Log before finish marks the job done, so the line is captured before
+	// the API persists the job's log
+	defer logOutcome(j, time.Now())
+
+	if j.Preempted() {
+		return // preempted while still waiting in JobQueue
+	}
+	j.Log().Info("job started", "worker", w.ID, "threads", held.Threads, "attempt", j.Attempts)
+	threads := held.Threads
+	if threads <= 1 || !j.Chunked() {
+		// Single-threaded job, or one that can't be split into chunks. It
+		// keeps every thread it holds but runs on one of them.
+		w.safeRun(j, j.Execute)
+		return
+	}
+	w.runChunks(j, threads, chunk)
+}
+
+// finish marks j Completed unless it failed or was preempted before it got
+// to the end. Chunked jobs can fail part way through; don't paper over that.
+func finish(j *job.Job) {
+	if j.Preempted() && j.CurrentStatus() != job.Completed {
+		return
+	}
+	j.MarkCompleted()
+}
+
+// logOutcome logs how an attempt that began at start ended
+func logOutcome(j *job.Job, start time.Time) {
+	took := time.Since(start)
+	status, jerr := j.Outcome()
+	switch {
+	case status == job.Failed && jerr != nil:
+		j.Log().Error("job failed", "code", jerr.Code, "error", jerr.Message, "retryable", jerr.Retryable, "took", took)
+	case j.Preempted() && status != job.Completed:
+		j.Log().Info("job preempted", "took", took)
+	default:
+		j.Log().Info("job finished", "took", took)
+	}
+}
+
+// endExecuteSpan records how the attempt ended. Later spans for j (result
+// writes) go back to hanging off the submitting request.
+func endExecuteSpan(j *job.Job, span trace.Span) {
+	j.SetTraceContext(nil)
+	status, jerr := j.Outcome()
+	span.SetAttributes(attribute.String("job.status", string(status)))
+	switch {
+	case status == job.Failed && jerr != nil:
+		span.SetStatus(codes.Error, jerr.Error())
+	case j.Preempted() && status != job.Completed:
+		span.AddEvent("preempted")
+	}
+	span.End()
+}
+
+// runChunks runs chunk once per thread, each in its own goroutine
+func (w *Worker) runChunks(j *job.Job, threads int, chunk func(threadID, totalThreads int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func(threadID int) {
+			defer wg.Done()
+			w.safeRun(j, func() { chunk(threadID, threads) })
+		}(i)
+	}
+
+	wg.Wait() // wait for all threads to finish
+}
+
+// safeRun runs fn and turns a panic into a Failed job rather than letting it
+// crash the whole process.
+func (w *Worker) safeRun(j *job.Job, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.crashes.Add(1)
+			j.Log().Error("handler panicked", "worker", w.ID, "panic", r)
+			j.FailOnce(job.PanicError(r, debug.Stack()))
+		}
+	}()
+	fn()
+}
+
+// Steals returns how many jobs this worker has taken from its peers
+func (w *Worker) Steals() int64 {
+	return w.steals.Load()
+}
+
+// Stolen returns how many jobs peers have taken from this worker
+func (w *Worker) Stolen() int64 {
+	return w.stolen.Load()
+}
+
+// Queued is the number of jobs assigned to this worker that haven't started
+func (w *Worker) Queued() int {
+	return w.queue.len()
+}
+
+// Crashes returns how many handler panics this worker has recovered from
+func (w *Worker) Crashes() int64 {
+	return w.crashes.Load()
+}
+
+// Available is the capacity not held by any assigned or running job
+func (w *Worker) Available() job.Resources {
+	return w.capacity.free()
+}
+
+// AvailableThreads is the number of threads not held by any assigned or
+// running job
 func (w *Worker) AvailableThreads() int {
-	return len(w.FreeThreads)
+	return w.Available().Threads
+}
+
+// clamp caps every dimension of r at the worker's capacity c
+func clamp(r, c job.Resources) job.Resources {
+	if r.Threads > c.Threads {
+		r.Threads = c.Threads
+	}
+	if r.MemoryMB > c.MemoryMB {
+		r.MemoryMB = c.MemoryMB
+	}
+	var custom map[string]int
+	for name, v := range r.Custom {
+		if custom == nil {
+			custom = make(map[string]int, len(r.Custom))
+		}
+		custom[name] = min(v, c.Custom[name])
+	}
+	r.Custom = custom
+	return r
 }
 
 func (w *Worker) Stop() {
@@ -2,10 +2,42 @@ package worker
 
 import (
 	"testing"
+	"time"
 
 	"github.com/samrichell-smith/distributed-job-scheduler/internal/job"
 )
 
+// sleepJob is a single-chunk job that just takes a while
+const sleepJob job.JobType = "Sleep"
+
+func init() {
+	job.RegisterMapReduce(sleepJob, job.MapReduce[time.Duration, struct{}]{
+		Split: func(d time.Duration, n int) []time.Duration { return []time.Duration{d} },
+		Map: func(d time.Duration) (struct{}, error) {
+			time.Sleep(d)
+			return struct{}{}, nil
+		},
+		Reduce: func([]struct{}) (interface{}, error) { return nil, nil },
+	})
+	job.RegisterMapReduce(gateJob, job.MapReduce[gate, struct{}]{
+		Split: func(g gate, n int) []gate { return []gate{g} },
+		Map: func(g gate) (struct{}, error) {
+			close(g.started)
+			<-g.release
+			return struct{}{}, nil
+		},
+		Reduce: func([]struct{}) (interface{}, error) { return nil, nil },
+	})
+}
+
+// gateJob is a single-chunk job that closes started once it is running and
+// then holds its thread until release is closed
+const gateJob job.JobType = "Gate"
+
+type gate struct {
+	started, release chan struct{}
+}
+
 func TestWorkerProcessesJob(t *testing.T) {
 	worker := NewWorker("w1", 4)
 	worker.Start()
@@ -28,3 +60,249 @@ func TestWorkerProcessesJob(t *testing.T) {
 		t.Errorf("expected %d, got %d", expected, result.Sum)
 	}
 }
+
+func TestWorkerHeartbeat(t *testing.T) {
+	w := NewWorker("w1", 1)
+	if !w.LastHeartbeat().IsZero() {
+		t.Fatal("expected no heartbeat before Start")
+	}
+	w.Start()
+	if time.Since(w.LastHeartbeat()) > time.Second {
+		t.Errorf("expected a fresh heartbeat after Start, got %s", w.LastHeartbeat())
+	}
+	w.Stop()
+	if !w.LastHeartbeat().IsZero() {
+		t.Error("expected the heartbeat to clear on Stop")
+	}
+}
+
+func TestWorkerRecoversFromPanic(t *testing.T) {
+	worker := NewWorker("w1", 2)
+	j := job.NewJob("p1", "PanicJob", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+
+	worker.safeRun(j, func() { panic("boom") })
+
+	if j.Status != job.Failed {
+		t.Fatalf("expected status Failed, got %s", j.Status)
+	}
+	if j.Error == nil || j.Error.Code != job.ErrPanic {
+		t.Fatalf("expected %s error, got %+v", job.ErrPanic, j.Error)
+	}
+	if j.Error.Stack == "" {
+		t.Errorf("expected stack trace to be captured")
+	}
+	if worker.Crashes() != 1 {
+		t.Errorf("expected crash count 1, got %d", worker.Crashes())
+	}
+}
+
+func TestWorkerReleasesThreadsAfterChunkPanic(t *testing.T) {
+	worker := NewWorker("w1", 4)
+	j := job.NewJob("p2", "PanicChunks", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3, 4}})
+
+	worker.capacity.acquire(job.Resources{Threads: 3})
+	worker.execute(j, job.Resources{Threads: 3}, func(threadID, totalThreads int) {
+		if threadID == 1 {
+			panic("chunk failed")
+		}
+	})
+
+	if got := worker.AvailableThreads(); got != 4 {
+		t.Errorf("expected all 4 threads free after panic, got %d", got)
+	}
+	if j.Status != job.Failed || j.Error == nil || j.Error.Code != job.ErrPanic {
+		t.Errorf("expected job to fail with %s, got status %s error %+v", job.ErrPanic, j.Status, j.Error)
+	}
+	if worker.Crashes() != 1 {
+		t.Errorf("expected crash count 1, got %d", worker.Crashes())
+	}
+}
+
+func TestSingleThreadedJobsConsumeCapacity(t *testing.T) {
+	worker := NewWorker("w1", 2)
+
+	j1 := job.NewJob("s1", "Single1", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 1})
+	j2 := job.NewJob("s2", "Single2", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 2, Y: 2})
+	j3 := job.NewJob("s3", "Single3", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 3, Y: 3})
+
+	if !worker.TryReserve(j1) || !worker.TryReserve(j2) {
+		t.Fatal("expected two single-threaded jobs to fit on a 2-thread worker")
+	}
+	if got := worker.AvailableThreads(); got != 0 {
+		t.Errorf("expected 0 free threads, got %d", got)
+	}
+	if worker.TryReserve(j3) {
+		t.Error("expected third reservation to be rejected")
+	}
+
+	worker.CancelReservation(j1)
+	if got := worker.AvailableThreads(); got != 1 {
+		t.Errorf("expected 1 free thread after cancelling, got %d", got)
+	}
+}
+
+func TestWorkerNoDeadlockOnOverlappingMultiThreadJobs(t *testing.T) {
+	worker := NewWorker("w1", 4)
+	worker.Start()
+
+	// Each job needs 3 of 4 threads. Acquiring threads one at a time let two
+	// of these hold 2 each and wait on each other forever.
+	jobs := make([]*job.Job, 0, 8)
+	for i := 0; i < 8; i++ {
+		j := job.NewJob("d", "Overlap", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3, 4, 5, 6}})
+		j.ThreadDemand = 3
+		jobs = append(jobs, j)
+		worker.JobQueue <- j
+	}
+
+	done := make(chan struct{})
+	go func() {
+		worker.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker deadlocked on overlapping multi-thread jobs")
+	}
+
+	for i, j := range jobs {
+		if j.Status != job.Completed {
+			t.Errorf("job %d: expected Completed, got %s", i, j.Status)
+		}
+	}
+	if got := worker.AvailableThreads(); got != 4 {
+		t.Errorf("expected all threads free afterwards, got %d", got)
+	}
+}
+
+func TestWorkerHandsBackPreemptedJob(t *testing.T) {
+	worker := NewWorker("w1", 2)
+	var freed *job.Job
+	worker.OnCapacityFreed(func(j *job.Job, _ job.Resources) { freed = j })
+
+	j := job.NewJob("j1", "Sum", job.LargeArraySumJob, 1, job.LargeArraySumPayload{Array: []int{1, 2, 3}})
+	j.Preemptible = true
+	j.Preempt()
+	worker.processJob(j)
+
+	if j.Status == job.Completed || j.Result != nil {
+		t.Errorf("preempted job must not complete, got %s with result %v", j.Status, j.Result)
+	}
+	if j.Attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", j.Attempts)
+	}
+	if freed != j || worker.AvailableThreads() != 2 {
+		t.Errorf("expected resources handed back, %d threads free", worker.AvailableThreads())
+	}
+}
+
+func TestWorkStealingCutsTailLatency(t *testing.T) {
+	const n = 3
+	busy, idle := NewWorker("busy", 1), NewWorker("idle", n)
+	Connect([]*Worker{busy, idle})
+	defer idle.Stop()
+	defer busy.Stop()
+
+	// Tie up busy's only thread, then queue more work behind it
+	g := gate{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(g.release)
+	busy.Start()
+	busy.JobQueue <- job.NewJob("blocker", "Gate", gateJob, 1, g)
+	select {
+	case <-g.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocker did not start")
+	}
+	jobs := make([]*job.Job, n)
+	for i := range jobs {
+		jobs[i] = job.NewJob("queued", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: i, Y: 1})
+		busy.queue.pushBack(jobs[i])
+	}
+
+	// With the blocker still running, only stealing can finish the rest
+	done := make(chan *job.Job, n)
+	idle.OnCapacityFreed(func(j *job.Job, _ job.Resources) { done <- j })
+	idle.Start()
+	for range jobs {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("queued jobs were not stolen")
+		}
+	}
+	for _, j := range jobs {
+		if s := j.CurrentStatus(); s != job.Completed {
+			t.Errorf("expected stolen job to complete, got %s", s)
+		}
+	}
+	if idle.Steals() != n || busy.Stolen() != n {
+		t.Fatalf("expected %d steals to be counted, got steals=%d stolen=%d", n, idle.Steals(), busy.Stolen())
+	}
+}
+
+func TestWorkersDontStealReservedJobs(t *testing.T) {
+	owner, thief := NewWorker("owner", 2), NewWorker("thief", 2)
+	Connect([]*Worker{owner, thief})
+	owner.consumers = 2 // as if started, with every consumer idle
+
+	j := job.NewJob("1", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	if !owner.TryReserve(j) {
+		t.Fatal("reservation failed")
+	}
+	owner.queue.pushBack(j)
+
+	if got, _ := thief.steal(); got != nil {
+		t.Error("thief took a reserved job its owner was about to start")
+	}
+}
+
+func TestStealMovesReservation(t *testing.T) {
+	owner, thief := NewWorker("owner", 2), NewWorker("thief", 2)
+	Connect([]*Worker{owner, thief})
+	owner.Pause()
+	var moved *job.Job
+	thief.OnSteal(func(j *job.Job, w *Worker, _ job.Resources) {
+		if w == thief {
+			moved = j
+		}
+	})
+
+	j := job.NewJob("1", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 1, Y: 2})
+	j.ThreadDemand = 2
+	if !owner.TryReserve(j) {
+		t.Fatal("reservation failed")
+	}
+	owner.queue.pushBack(j)
+
+	got, held := thief.steal()
+	if got != j || moved != j || held.Threads != 2 {
+		t.Fatalf("expected the paused owner's job to be stolen, got %v holding %+v", got, held)
+	}
+	if n := owner.AvailableThreads(); n != 2 {
+		t.Errorf("expected the owner's reservation to be given back, %d threads free", n)
+	}
+	if n := thief.AvailableThreads(); n != 0 {
+		t.Errorf("expected the thief to hold the job's threads, %d free", n)
+	}
+	if _, ok := owner.reservation(j); ok {
+		t.Error("owner still has the job reserved")
+	}
+}
+
+func TestMultiThreadNonChunkedJobGetsResult(t *testing.T) {
+	worker := NewWorker("w1", 4)
+	j := job.NewJob("a", "Add", job.AddNumbersJob, 1, job.AddNumbersPayload{X: 2, Y: 3})
+	j.ThreadDemand = 3
+	worker.processJob(j)
+
+	if j.Status != job.Completed {
+		t.Fatalf("expected Completed, got %s", j.Status)
+	}
+	if res, ok := j.Result.(job.AddNumbersResult); !ok || res.Sum != 5 {
+		t.Errorf("expected sum 5, got %v", j.Result)
+	}
+	if got := worker.AvailableThreads(); got != 4 {
+		t.Errorf("expected all threads back, got %d free", got)
+	}
+}